@@ -5,6 +5,7 @@
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -16,14 +17,28 @@
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/config"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/event"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/identity"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/jwks"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/server"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
+	"github.com/redis/go-redis/v9"
+)
+
+// Build information, set at link time via
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"     // Release version
+	commit    = "unknown" // Git commit the binary was built from
+	buildDate = "unknown" // Build timestamp
 )
 
 // main is the entry point for the CDV service.
 // It initializes all components, starts the HTTP server, and handles graceful shutdown.
 func main() {
+	validateOnly := flag.Bool("validate-config", false, "check configuration and dependencies, then exit")
+	flag.Parse()
+
 	// Load configuration from environment variables
 	cfg, err := config.Load()
 	if err != nil {
@@ -31,6 +46,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Pre-flight mode: report dependency connectivity and exit non-zero on any failure
+	if *validateOnly || cfg.ValidateOnly {
+		if !validateDependencies(cfg, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Configure structured logging for the application
 	logLevel := slog.LevelInfo
 	if cfg.Env == "dev" {
@@ -40,9 +63,17 @@ func main() {
 		Level: logLevel,
 	}))
 	slog.SetDefault(logger)
+	logger.Info("effective configuration", "config", cfg)
 
-	// Initialize OpenTelemetry
-	_, err = telemetry.InitTracer("cdv-service")
+	// Initialize OpenTelemetry with build version and deployment attributes
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	_, err = telemetry.InitTracer("cdv-service", version,
+		telemetry.DeploymentEnvironment(cfg.Env),
+		telemetry.InstanceID(instanceID),
+	)
 	if err != nil {
 		logger.Error("failed to initialize OpenTelemetry tracer", "error", err)
 		os.Exit(1)
@@ -54,11 +85,14 @@ func main() {
 		telemetry.ShutdownTracer(ctx)
 	}()
 
+	// Apply histogram bucket overrides before any metrics are registered
+	metrics.SetBuckets(cfg.MetricsLatencyBuckets, cfg.MetricsMediaBuckets)
+
 	// Initialize storage backend (PostgreSQL or in-memory)
 	var store storage.Store
 	if cfg.DatabaseDSN != "" {
 		// Use PostgreSQL storage for production
-		store, err = storage.NewPostgres(cfg.DatabaseDSN)
+		store, err = storage.NewPostgres(cfg.DatabaseDSN, storage.WithSlowQueryThreshold(cfg.SlowQueryThreshold))
 		if err != nil {
 			logger.Error("failed to initialize postgres storage", "error", err)
 			os.Exit(1)
@@ -66,11 +100,32 @@ func main() {
 	} else {
 		// Use in-memory storage for development/testing
 		store = storage.NewMemory()
+		if cfg.Env != "dev" {
+			// Idempotency state lives in process memory and is not shared between replicas
+			logger.Warn("using in-memory storage outside dev; idempotency is per-instance, run a single replica or set CDV_DB_DSN", "env", cfg.Env)
+		}
 	}
 
-	// Initialize event publisher (NATS JetStream or no-op)
-	pub := event.NewPublisherFromEnv()
-	defer pub.Close() // Ensure publisher is closed on exit
+	// Keep idempotency records and rate-limit buckets in Redis when configured, so replicas
+	// share them without growing the database; otherwise idempotency records stay in the
+	// store and each replica enforces rate limits on its own
+	var idempotency storage.IdempotencyStore = storage.StoreIdempotency(store)
+	var rateLimitRedis redis.Scripter
+	if cfg.RedisURL != "" {
+		redisStore, err := storage.NewRedisIdempotency(context.Background(), cfg.RedisURL)
+		if err != nil {
+			logger.Error("failed to initialize redis idempotency store", "error", err)
+			os.Exit(1)
+		}
+		defer redisStore.Close()
+		idempotency = redisStore
+		rateLimitRedis = redisStore.Client()
+	}
+
+	// Initialize event publisher (NATS JetStream or no-op), behind a bounded queue
+	// so request latency does not depend on the event backend
+	pub := event.NewQueuedPublisher(event.NewPublisher(cfg.NATSURL, event.WithDedupWindow(cfg.EventDedupWindow), event.WithAutoCreateStreams(cfg.NATSAutoCreateStreams), event.WithMaxPending(cfg.NATSMaxPending)), cfg.EventQueueSize, cfg.EventWorkers, cfg.EventDrainTimeout)
+	defer pub.Close() // Drain queued events and close the publisher on exit
 
 	// Initialize identity client for DID validation
 	var idClient *identity.Client
@@ -78,8 +133,53 @@ func main() {
 		idClient = identity.New(cfg.IdentityURL)
 	}
 
+	// Initialize JWKS client for JWT validation
+	jwksClient := jwks.NewClient(fmt.Sprintf("%s/.well-known/jwks.json", cfg.JWTIssuer), jwks.WithCacheTTL(cfg.JWKSCacheTTL),
+		jwks.WithRefreshInterval(cfg.JWKSRefreshInterval),
+		jwks.WithCircuitBreaker(cfg.JWKSBreakerThreshold, cfg.JWKSBreakerCooldown),
+		jwks.WithAllowedTypes(cfg.JWTAllowedTypes...),
+		jwks.WithLeeway(cfg.JWTLeeway),
+	)
+
+	if cfg.CursorSecret == "" {
+		logger.Warn("CDV_CURSOR_SECRET is not set; pagination cursors are unsigned and clients can edit their issue time and filters")
+	}
+
+	// Policy settings are reloaded on SIGHUP; everything else requires a restart
+	policy := server.NewPolicyHolder(policyFromConfig(cfg))
+
 	// Create HTTP mux with all handlers and middleware
-	mux := server.NewMux(store, pub, idClient, cfg.JWTIssuer, cfg.JWTAudience, cfg.MaxMediaSize, cfg.AllowedMimeTypes, nil, cfg.SpecsURL, cfg.RejectDeprecatedSchemas)
+	mux := server.NewMux(store, pub, idClient, cfg.JWTIssuer, cfg.JWTAudience, cfg.MaxMediaSize, cfg.AllowedMimeTypes, jwksClient, cfg.SpecsURL, cfg.RejectDeprecatedSchemas,
+		server.WithSpecsReadinessProbe(cfg.ReadinessCheckSpecs),
+		server.WithReadinessErrorDetail(cfg.ReadinessErrorDetail),
+		server.WithUnvalidatedCollections(cfg.UnvalidatedCollections...),
+		server.WithAllowUnknownCollections(cfg.AllowUnknownCollections),
+		server.WithMaxJWTLength(cfg.JWTMaxLength),
+		server.WithAuthCookie(cfg.AuthCookieName),
+		server.WithTrustedAudiences(cfg.JWTTrustedAudiences...),
+		server.WithReplayProtection(cfg.JWTReplayProtection),
+		server.WithRequireAuthReads(cfg.RequireAuthReads),
+		server.WithAnonReadRateLimit(cfg.AnonReadRPS, cfg.AnonReadBurst),
+		server.WithRateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst),
+		server.WithRedisRateLimits(rateLimitRedis),
+		server.WithTrustedProxies(cfg.TrustedProxies...),
+		server.WithDPoP(cfg.DPoPEnabled, []byte(cfg.DPoPNonceSecret)),
+		server.WithAdminDIDs(cfg.AdminDIDs...),
+		server.WithAllowedContentTypes(cfg.AllowedContentTypes...),
+		server.WithMaxRequestBody(cfg.MaxRequestBody),
+		server.WithMaxConcurrentVerify(cfg.MaxConcurrentVerify),
+		server.WithMaxPendingUploads(cfg.MaxPendingUploads),
+		server.WithExportPageSize(cfg.ExportPageSize),
+		server.WithMaxConcurrentExports(cfg.MaxConcurrentExports),
+		server.WithMaxQueryWindow(cfg.MaxQueryWindow),
+		server.WithCursorTTL(cfg.CursorTTL),
+		server.WithCursorSecret([]byte(cfg.CursorSecret)),
+		server.WithFeedLimits(cfg.FeedMaxFanout, cfg.FeedMaxPerAuthor),
+		server.WithPolicy(policy),
+		server.WithIdempotencyStore(idempotency),
+	)
+	go reloadPolicyOnHUP(logger, cfg, policy)
+	go pruneExpired(logger, store, pruneInterval)
 
 	// Create HTTP server with timeout configuration
 	addr := fmt.Sprintf(":%s", cfg.Port)
@@ -92,7 +192,7 @@ func main() {
 
 	// Start server in a separate goroutine
 	go func() {
-		logger.Info("server starting", "addr", addr, "env", cfg.Env)
+		logger.Info("server starting", "addr", addr, "env", cfg.Env, "version", version, "commit", commit, "build_date", buildDate)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("server failed to start", "error", err)
 			os.Exit(1)
@@ -115,6 +215,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Stop the JWKS background refresh
+	jwksClient.Close()
+
 	// Close PostgreSQL storage if used
 	if postgresStore, ok := store.(interface{ Close() }); ok {
 		postgresStore.Close()
@@ -123,3 +226,63 @@ func main() {
 	// Note: pub.Close() is deferred above
 	logger.Info("server exited")
 }
+
+// pruneInterval is how often expired entries are deleted from the store
+const pruneInterval = 10 * time.Minute
+
+// pruneExpired deletes expired idempotency entries and used token IDs every interval,
+// so the store does not grow with entries that can no longer match. Failures are logged
+// and retried on the next tick; requests never depend on the cleanup.
+func pruneExpired(logger *slog.Logger, store storage.Store, interval time.Duration) {
+	prunes := []struct {
+		name   string
+		delete func(context.Context) (int64, error)
+	}{
+		{"idempotency entries", store.DeleteExpiredIdempotentResponses},
+		{"used tokens", store.DeleteExpiredTokens},
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, prune := range prunes {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			deleted, err := prune.delete(ctx)
+			cancel()
+			if err != nil {
+				logger.Warn("failed to delete expired "+prune.name, "error", err)
+				continue
+			}
+			logger.Debug("deleted expired "+prune.name, "count", deleted)
+		}
+	}
+}
+
+// policyFromConfig extracts the settings that can change without a restart
+func policyFromConfig(cfg config.Config) server.Policy {
+	return server.Policy{
+		MaxMediaSize:            cfg.MaxMediaSize,
+		AllowedMimeTypes:        cfg.AllowedMimeTypes,
+		CORSAllowedOrigins:      cfg.CORSAllowedOrigins,
+		RejectDeprecatedSchemas: cfg.RejectDeprecatedSchemas,
+	}
+}
+
+// reloadPolicyOnHUP reloads configuration on SIGHUP and applies the policy settings.
+// An invalid configuration is logged and the current policy kept.
+func reloadPolicyOnHUP(logger *slog.Logger, initial config.Config, policy *server.PolicyHolder) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		cfg, err := config.Load()
+		if err != nil {
+			logger.Error("config reload failed; keeping current policy", "error", err)
+			continue
+		}
+		if cfg.DatabaseDSN != initial.DatabaseDSN || cfg.NATSURL != initial.NATSURL || cfg.RedisURL != initial.RedisURL {
+			logger.Warn("database, NATS and Redis settings changed but only take effect after a restart")
+		}
+		policy.Store(policyFromConfig(cfg))
+		logger.Info("config reloaded", "max_media_size", cfg.MaxMediaSize, "allowed_mime_types", cfg.AllowedMimeTypes,
+			"cors_allowed_origins", cfg.CORSAllowedOrigins, "reject_deprecated_schemas", cfg.RejectDeprecatedSchemas)
+	}
+}
@@ -0,0 +1,95 @@
+// cmd/cdvd/validate.go
+// Pre-flight mode: check configuration and dependency connectivity without serving.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/config"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/event"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/jwks"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/media"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/schema"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+)
+
+// validateTimeout bounds each dependency check
+const validateTimeout = 10 * time.Second
+
+// Connectivity checks of the database, NATS and Redis; replaced by stubs in tests
+var (
+	pingPostgres = storage.PingPostgres
+	pingNATS     = event.PingNATS
+	pingRedis    = storage.PingRedis
+)
+
+// dependencyCheck is one pre-flight check; a nil run means the dependency is not configured
+type dependencyCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// validateDependencies checks every configured dependency, reports each result to out,
+// and returns false if any check failed
+func validateDependencies(cfg config.Config, out io.Writer) bool {
+	checks := []dependencyCheck{
+		{"database", nil},
+		{"nats", nil},
+		{"redis", nil},
+		{"s3", nil},
+		{"jwks", func(ctx context.Context) error {
+			client := jwks.NewClient(fmt.Sprintf("%s/.well-known/jwks.json", cfg.JWTIssuer))
+			defer client.Close()
+			return client.Ping(ctx)
+		}},
+		{"specs", func(ctx context.Context) error {
+			return schema.NewResolver(cfg.SpecsURL, "").Ping(ctx)
+		}},
+	}
+	if cfg.DatabaseDSN != "" {
+		checks[0].run = func(ctx context.Context) error {
+			return pingPostgres(ctx, cfg.DatabaseDSN)
+		}
+	}
+	if cfg.NATSURL != "" {
+		checks[1].run = func(ctx context.Context) error {
+			return pingNATS(cfg.NATSURL, validateTimeout)
+		}
+	}
+	if cfg.RedisURL != "" {
+		checks[2].run = func(ctx context.Context) error {
+			return pingRedis(ctx, cfg.RedisURL)
+		}
+	}
+	if cfg.S3Endpoint != "" && cfg.S3Bucket != "" {
+		checks[3].run = func(ctx context.Context) error {
+			client, err := media.NewS3Client(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+			if err != nil {
+				return err
+			}
+			return client.Ping(ctx)
+		}
+	}
+
+	fmt.Fprintf(out, "%-8s ok\n", "config")
+	ok := true
+	for _, check := range checks {
+		if check.run == nil {
+			fmt.Fprintf(out, "%-8s skipped (not configured)\n", check.name)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+		err := check.run(ctx)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(out, "%-8s FAILED: %v\n", check.name, err)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(out, "%-8s ok\n", check.name)
+	}
+	return ok
+}
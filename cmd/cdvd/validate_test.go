@@ -0,0 +1,78 @@
+// cmd/cdvd/validate_test.go
+// Package main provides tests for the pre-flight dependency checks.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/config"
+)
+
+// TestValidateDependencies tests the printed report and the overall result with reachable,
+// unreachable and unconfigured dependencies.
+func TestValidateDependencies(t *testing.T) {
+	// The JWKS and specs checks reach this server; S3 stays unconfigured
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/jwks.json" {
+			_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"AA"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	reachable := func(url string) error { return nil }
+	unreachable := func(url string) error { return errors.New("connection refused to " + url) }
+	origPostgres, origNATS, origRedis := pingPostgres, pingNATS, pingRedis
+	t.Cleanup(func() {
+		pingPostgres, pingNATS, pingRedis = origPostgres, origNATS, origRedis
+	})
+
+	tests := []struct {
+		name                   string
+		db, nats, redis        func(url string) error
+		dsn, natsURL, redisURL string
+		want                   string
+		ok                     bool
+	}{
+		{
+			name: "all reachable", db: reachable, nats: reachable, redis: reachable,
+			dsn: "postgres://db", natsURL: "nats://nats", redisURL: "redis://redis",
+			want: "config   ok\ndatabase ok\nnats     ok\nredis    ok\ns3       skipped (not configured)\njwks     ok\nspecs    ok\n",
+			ok:   true,
+		},
+		{
+			name: "database and redis unreachable", db: unreachable, nats: reachable, redis: unreachable,
+			dsn: "postgres://db", natsURL: "nats://nats", redisURL: "redis://redis",
+			want: "config   ok\ndatabase FAILED: connection refused to postgres://db\nnats     ok\nredis    FAILED: connection refused to redis://redis\ns3       skipped (not configured)\njwks     ok\nspecs    ok\n",
+		},
+		{
+			name: "nats unreachable", db: reachable, nats: unreachable, redis: reachable,
+			dsn: "postgres://db", natsURL: "nats://nats", redisURL: "redis://redis",
+			want: "config   ok\ndatabase ok\nnats     FAILED: connection refused to nats://nats\nredis    ok\ns3       skipped (not configured)\njwks     ok\nspecs    ok\n",
+		},
+		{
+			name: "unconfigured", db: unreachable, nats: unreachable, redis: unreachable,
+			want: "config   ok\ndatabase skipped (not configured)\nnats     skipped (not configured)\nredis    skipped (not configured)\ns3       skipped (not configured)\njwks     ok\nspecs    ok\n",
+			ok:   true,
+		},
+	}
+	for _, tt := range tests {
+		pingPostgres = func(ctx context.Context, dsn string) error { return tt.db(dsn) }
+		pingNATS = func(url string, timeout time.Duration) error { return tt.nats(url) }
+		pingRedis = func(ctx context.Context, url string) error { return tt.redis(url) }
+
+		cfg := config.Config{DatabaseDSN: tt.dsn, NATSURL: tt.natsURL, RedisURL: tt.redisURL, JWTIssuer: srv.URL, SpecsURL: srv.URL}
+		var out bytes.Buffer
+		if ok := validateDependencies(cfg, &out); ok != tt.ok {
+			t.Errorf("%s: got %v want %v", tt.name, ok, tt.ok)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got report\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+}
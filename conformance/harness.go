@@ -117,6 +117,22 @@ func (n *noopPublisher) PublishRecordCreated(ctx context.Context, collection str
 	return nil
 }
 
+func (n *noopPublisher) PublishRecordsCreated(ctx context.Context, records []model.Record) error {
+	return nil
+}
+
+func (n *noopPublisher) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+func (n *noopPublisher) PublishRecordDeleted(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+func (n *noopPublisher) PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error {
+	return nil
+}
+
 func (n *noopPublisher) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error {
 	return nil
 }
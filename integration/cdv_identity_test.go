@@ -33,6 +33,27 @@ func (p *integrationTestPublisher) PublishRecordCreated(ctx context.Context, col
 	return nil
 }
 
+// PublishRecordsCreated implements event.Publisher for integration testing.
+func (p *integrationTestPublisher) PublishRecordsCreated(ctx context.Context, records []model.Record) error {
+	p.recordEvents = append(p.recordEvents, records...)
+	return nil
+}
+
+// PublishRecordUpdated implements event.Publisher for integration testing.
+func (p *integrationTestPublisher) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+// PublishRecordDeleted implements event.Publisher for integration testing.
+func (p *integrationTestPublisher) PublishRecordDeleted(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+// PublishMediaUploadInitiated implements event.Publisher for integration testing.
+func (p *integrationTestPublisher) PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error {
+	return nil
+}
+
 // PublishMediaFinalized implements event.Publisher for integration testing.
 func (p *integrationTestPublisher) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error {
 	p.mediaEvents = append(p.mediaEvents, asset)
@@ -4,9 +4,11 @@
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -39,9 +41,19 @@ func init() {
 // It contains all configuration parameters needed to run the CDV service.
 type Config struct {
 	Env          string // Deployment environment (dev, staging, prod)
+	InstanceID   string // Service instance identifier for telemetry (default: hostname)
 	Port         string // HTTP server port
+	ValidateOnly bool   // Check configuration and dependencies, then exit without serving
 	DatabaseDSN  string // Database connection string (PostgreSQL)
+	SlowQueryThreshold time.Duration // Minimum duration of a database query logged as slow (0 disables)
 	NATSURL      string // NATS server URL
+	NATSAutoCreateStreams bool // Whether the event streams are created or updated on startup
+	NATSMaxPending int // Maximum unacknowledged asynchronous event publishes
+	RedisURL     string // Redis URL for idempotency records and rate-limit buckets (empty keeps them in the database and in process)
+	EventQueueSize    int           // Capacity of the internal event publish queue
+	EventWorkers      int           // Workers draining the event publish queue
+	EventDrainTimeout time.Duration // How long shutdown waits for queued events to be published
+	EventDedupWindow  time.Duration // JetStream window in which republished events are dropped
 	S3Endpoint   string // S3-compatible storage endpoint
 	S3Region     string // S3 region
 	S3Bucket     string // S3 bucket name
@@ -49,18 +61,63 @@ type Config struct {
 	S3SecretKey  string // S3 secret key
 	JWTIssuer    string // Expected issuer for JWT validation
 	JWTAudience  string // Expected audience for JWT validation
+	JWTTrustedAudiences []string // Additional audiences accepted for JWT validation
+	JWTAllowedTypes     []string // Accepted JWT typ header values ("" accepts tokens without typ)
+	JWTLeeway           time.Duration // Clock skew tolerated when checking JWT exp and nbf
+	DPoPEnabled         bool     // Whether DPoP-bound access tokens are supported
+	AdminDIDs           []string // DIDs allowed to call /v1/admin/ endpoints
+	DPoPNonceSecret     string   // Key for DPoP nonces, shared by all instances (empty generates one per instance)
+	JWTMaxLength int    // Maximum accepted bearer token length in bytes
+	JWTReplayProtection bool // Whether JWT IDs (jti) are tracked and reused tokens rejected
+	RequireAuthReads bool // Whether read endpoints (getRecord, listRecords, getRecords, countRecords, describeRepo, backlinks, feed/following) require a JWT
+	AuthCookieName string // Cookie to read the JWT from when no Authorization header is sent (empty disables)
 	IdentityURL  string // Identity service URL for DID validation
 	SpecsURL     string // URL to the specs repository for schema resolution
+	SchemaDir    string // Directory of <collection>.json schemas loaded on top of the built-in ones
+	JWKSCacheTTL time.Duration // How long fetched JWKS keys are considered fresh
+	JWKSRefreshInterval time.Duration // How often JWKS keys are refetched in the background
+	JWKSBreakerThreshold int           // Consecutive JWKS fetch failures before fetching is suspended
+	JWKSBreakerCooldown  time.Duration // How long JWKS fetching stays suspended
 	
 	// Media limits
 	MaxMediaSize int64    // Maximum media size in bytes (default 10MB)
 	AllowedMimeTypes []string // Allowed MIME types for media uploads
+	MaxConcurrentVerify int   // Maximum concurrent media verifications on finalize (0 means unlimited)
+	MaxPendingUploads   int   // Maximum unfinalized uploads per DID (0 means unlimited)
+	
+	// Export limits
+	ExportPageSize         int // Records fetched per storage page during export (1-100)
+	MaxConcurrentExports   int // Maximum concurrent exports per instance (0 means unlimited)
+	MaxQueryWindow time.Duration // Maximum since/until span of a listRecords query without a cursor (0 means unlimited)
+	CursorTTL time.Duration // How long pagination cursors stay valid (0 means no expiry)
+	CursorSecret string // Key for signing pagination cursors, shared by all instances (empty leaves cursors unsigned)
+	AllowedContentTypes []string // Accepted request body Content-Types (default: application/json)
+	MaxRequestBody int64 // Maximum body size of mutating requests in bytes (default 1 MiB)
+	
+	// Feed limits
+	FeedMaxFanout    int // Followed DIDs read per following feed request
+	FeedMaxPerAuthor int // Records read per followed DID per feed page (0 means the page limit)
 	
 	// Schema policy
 	RejectDeprecatedSchemas bool // Whether to reject deprecated schemas
+	ReadinessCheckSpecs     bool // Whether /readyz probes the specs repository (degraded, not fatal)
+	ReadinessErrorDetail    bool // Whether /readyz failures include each dependency's error message
+	UnvalidatedCollections  []string // Collections (or "prefix.*" patterns) stored without schema validation
+	AllowUnknownCollections bool // Whether records for collections without a schema are stored instead of rejected
 	
 	// CORS configuration
 	CORSAllowedOrigins []string // Allowed origins for CORS (empty means deny all)
+	
+	// Rate limiting
+	AnonReadRPS    float64        // Unauthenticated read requests per second per client IP (0 disables)
+	AnonReadBurst  int            // Burst size for unauthenticated reads (0 derives it from AnonReadRPS)
+	RateLimitRPS   float64        // Requests per second per authenticated DID (0 disables)
+	RateLimitBurst int            // Burst size per DID (0 derives it from RateLimitRPS)
+	TrustedProxies []netip.Prefix // Proxies whose X-Forwarded-For header is trusted for the client IP
+	
+	// Metrics configuration
+	MetricsLatencyBuckets []float64 // Histogram buckets (seconds) for HTTP/storage latency (empty means defaults)
+	MetricsMediaBuckets   []float64 // Histogram buckets (seconds) for media operations (empty means defaults)
 }
 
 // Default configuration values used when environment variables are not set
@@ -68,6 +125,24 @@ type Config struct {
 	defaultPort       = "8080"              // Default HTTP server port
 	defaultS3Region   = "us-east-1"         // Default S3 region
 	defaultEnv        = "dev"               // Default environment
+	defaultEventQueueSize = 1024            // Default event publish queue capacity
+	defaultNATSMaxPending = 256             // Default maximum unacknowledged async event publishes
+	defaultFeedMaxFanout  = 1000            // Default followed DIDs read per feed request
+	defaultMaxRequestBody = 1 << 20         // Default maximum request body size (1 MiB)
+	defaultEventWorkers = 4                 // Default event publish workers
+	defaultEventDrainTimeout = 10 * time.Second // Default shutdown drain timeout for queued events
+	defaultEventDedupWindow = 5 * time.Minute // Default JetStream duplicate-detection window
+	maxEventDedupWindow = 24 * time.Hour    // Dedup window limit: the event streams keep events for 24h
+	defaultSlowQueryThreshold = 250 * time.Millisecond // Default minimum duration of a slow query log
+	defaultMaxConcurrentVerify = 8          // Default concurrent media verifications
+	defaultExportPageSize = 100             // Default records per export page
+	defaultMaxConcurrentExports = 4         // Default concurrent exports per instance
+	defaultJWTMaxLength = 8192              // Default maximum bearer token length in bytes
+	defaultJWKSCacheTTL = 5 * time.Minute   // Default JWKS cache freshness
+	defaultJWTLeeway = 60 * time.Second     // Default clock skew tolerated for JWT exp and nbf
+	defaultJWKSRefreshInterval = 5 * time.Minute // Default JWKS background refresh interval
+	defaultJWKSBreakerThreshold = 3         // Default consecutive JWKS failures before the breaker opens
+	defaultJWKSBreakerCooldown = 30 * time.Second // Default JWKS breaker cooldown
 )
 
 // Load reads environment variables and produces a Config suitable for wiring the service.
@@ -76,6 +151,11 @@ type Config struct {
 func Load() (Config, error) {
 	cfg := Config{}
 
+	// Fill in variables not set in the environment from the config file, if any
+	if err := loadConfigFile(); err != nil {
+		return cfg, err
+	}
+
 	// Handle environment variable
 	if env, exists := os.LookupEnv("CDV_ENV"); exists {
 		cfg.Env = env
@@ -83,12 +163,20 @@ func Load() (Config, error) {
 		cfg.Env = defaultEnv
 	}
 
+	// Handle instance ID
+	if instanceID, exists := os.LookupEnv("CDV_INSTANCE_ID"); exists {
+		cfg.InstanceID = instanceID
+	}
+
 	// Handle port
 	if port, exists := os.LookupEnv("CDV_PORT"); exists {
 		cfg.Port = port
 	} else {
 		cfg.Port = defaultPort
 	}
+	if validateOnly, exists := os.LookupEnv("CDV_VALIDATE_ONLY"); exists {
+		cfg.ValidateOnly = parseBool(validateOnly)
+	}
 
 	// Handle optional variables
 	if dsn, exists := os.LookupEnv("CDV_DB_DSN"); exists {
@@ -99,6 +187,37 @@ func Load() (Config, error) {
 		cfg.NATSURL = natsURL
 	}
 
+	cfg.NATSAutoCreateStreams = true
+	if autoCreate, exists := os.LookupEnv("CDV_NATS_AUTO_CREATE_STREAMS"); exists {
+		parsed, err := strconv.ParseBool(autoCreate)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CDV_NATS_AUTO_CREATE_STREAMS: %q", autoCreate)
+		}
+		cfg.NATSAutoCreateStreams = parsed
+	}
+
+	cfg.NATSMaxPending = defaultNATSMaxPending
+	if maxPending, exists := os.LookupEnv("CDV_NATS_MAX_PENDING"); exists {
+		parsed, err := strconv.Atoi(maxPending)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_NATS_MAX_PENDING: %q", maxPending)
+		}
+		cfg.NATSMaxPending = parsed
+	}
+
+	cfg.SlowQueryThreshold = defaultSlowQueryThreshold
+	if ms, exists := os.LookupEnv("CDV_SLOW_QUERY_MS"); exists {
+		parsed, err := strconv.Atoi(ms)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_SLOW_QUERY_MS: %q", ms)
+		}
+		cfg.SlowQueryThreshold = time.Duration(parsed) * time.Millisecond
+	}
+
+	if redisURL, exists := os.LookupEnv("CDV_REDIS_URL"); exists {
+		cfg.RedisURL = redisURL
+	}
+
 	if s3Endpoint, exists := os.LookupEnv("CDV_S3_ENDPOINT"); exists {
 		cfg.S3Endpoint = s3Endpoint
 	}
@@ -129,6 +248,45 @@ func Load() (Config, error) {
 		cfg.JWTAudience = jwtAudience
 	}
 
+	if trusted, exists := os.LookupEnv("CDV_JWT_TRUSTED_AUDIENCES"); exists {
+		for _, audience := range strings.Split(trusted, ",") {
+			if audience = strings.TrimSpace(audience); audience != "" {
+				cfg.JWTTrustedAudiences = append(cfg.JWTTrustedAudiences, audience)
+			}
+		}
+	}
+
+	// An empty entry (e.g. "JWT,") accepts tokens without a typ header
+	if types, exists := os.LookupEnv("CDV_JWT_ALLOWED_TYPES"); exists && strings.TrimSpace(types) != "" {
+		for _, typ := range strings.Split(types, ",") {
+			cfg.JWTAllowedTypes = append(cfg.JWTAllowedTypes, strings.TrimSpace(typ))
+		}
+	}
+
+	if admins, exists := os.LookupEnv("CDV_ADMIN_DIDS"); exists {
+		for _, did := range strings.Split(admins, ",") {
+			if did = strings.TrimSpace(did); did != "" {
+				cfg.AdminDIDs = append(cfg.AdminDIDs, did)
+			}
+		}
+	}
+
+	if dpop, exists := os.LookupEnv("CDV_DPOP_ENABLED"); exists {
+		cfg.DPoPEnabled = parseBool(dpop)
+	}
+	cfg.DPoPNonceSecret = os.Getenv("CDV_DPOP_NONCE_SECRET")
+
+	if replay, exists := os.LookupEnv("CDV_JWT_REPLAY_PROTECTION"); exists {
+		cfg.JWTReplayProtection = parseBool(replay)
+	}
+	if requireAuthReads, exists := os.LookupEnv("CDV_REQUIRE_AUTH_READS"); exists {
+		cfg.RequireAuthReads = parseBool(requireAuthReads)
+	}
+
+	if cookieName, exists := os.LookupEnv("CDV_AUTH_COOKIE_NAME"); exists {
+		cfg.AuthCookieName = cookieName
+	}
+
 	if identityURL, exists := os.LookupEnv("IDENTITY_URL"); exists {
 		cfg.IdentityURL = identityURL
 	}
@@ -138,6 +296,9 @@ func Load() (Config, error) {
 	} else {
 		cfg.SpecsURL = "https://raw.githubusercontent.com/RegistryAccord/registryaccord-specs/main/schemas"
 	}
+	if schemaDir, exists := os.LookupEnv("CDV_SCHEMA_DIR"); exists {
+		cfg.SchemaDir = schemaDir
+	}
 	
 	// Handle media limits
 	if maxMediaSize, exists := os.LookupEnv("CDV_MAX_MEDIA_SIZE"); exists {
@@ -160,10 +321,111 @@ func Load() (Config, error) {
 		cfg.AllowedMimeTypes = []string{"image/jpeg", "image/png", "image/gif", "video/mp4"}
 	}
 	
+	cfg.MaxConcurrentVerify = defaultMaxConcurrentVerify
+	if maxVerify, exists := os.LookupEnv("CDV_MAX_CONCURRENT_VERIFY"); exists {
+		parsed, err := strconv.Atoi(maxVerify)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_MAX_CONCURRENT_VERIFY: %q", maxVerify)
+		}
+		cfg.MaxConcurrentVerify = parsed
+	}
+	if maxPending, exists := os.LookupEnv("CDV_MAX_PENDING_UPLOADS"); exists {
+		parsed, err := strconv.Atoi(maxPending)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_MAX_PENDING_UPLOADS: %q", maxPending)
+		}
+		cfg.MaxPendingUploads = parsed
+	}
+	
+	// Handle export limits
+	cfg.ExportPageSize = defaultExportPageSize
+	if pageSize, exists := os.LookupEnv("CDV_EXPORT_PAGE_SIZE"); exists {
+		parsed, err := strconv.Atoi(pageSize)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			return cfg, fmt.Errorf("invalid CDV_EXPORT_PAGE_SIZE: %q (must be 1-100)", pageSize)
+		}
+		cfg.ExportPageSize = parsed
+	}
+	cfg.MaxConcurrentExports = defaultMaxConcurrentExports
+	if maxExports, exists := os.LookupEnv("CDV_MAX_CONCURRENT_EXPORTS"); exists {
+		parsed, err := strconv.Atoi(maxExports)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_MAX_CONCURRENT_EXPORTS: %q", maxExports)
+		}
+		cfg.MaxConcurrentExports = parsed
+	}
+	if window, exists := os.LookupEnv("CDV_MAX_QUERY_WINDOW"); exists {
+		parsed, err := time.ParseDuration(window)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_MAX_QUERY_WINDOW: %q", window)
+		}
+		cfg.MaxQueryWindow = parsed
+	}
+	if ttl, exists := os.LookupEnv("CDV_CURSOR_TTL"); exists {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_CURSOR_TTL: %q", ttl)
+		}
+		cfg.CursorTTL = parsed
+	}
+	cfg.CursorSecret = os.Getenv("CDV_CURSOR_SECRET")
+	
+	// Handle feed limits
+	cfg.FeedMaxFanout = defaultFeedMaxFanout
+	if fanout, exists := os.LookupEnv("CDV_FEED_MAX_FANOUT"); exists {
+		parsed, err := strconv.Atoi(fanout)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_FEED_MAX_FANOUT: %q", fanout)
+		}
+		cfg.FeedMaxFanout = parsed
+	}
+	if perAuthor, exists := os.LookupEnv("CDV_FEED_MAX_PER_AUTHOR"); exists {
+		parsed, err := strconv.Atoi(perAuthor)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_FEED_MAX_PER_AUTHOR: %q", perAuthor)
+		}
+		cfg.FeedMaxPerAuthor = parsed
+	}
+	
+	if contentTypes, exists := os.LookupEnv("CDV_ALLOWED_CONTENT_TYPES"); exists {
+		for _, contentType := range strings.Split(contentTypes, ",") {
+			if contentType = strings.TrimSpace(contentType); contentType != "" {
+				cfg.AllowedContentTypes = append(cfg.AllowedContentTypes, contentType)
+			}
+		}
+	} else {
+		cfg.AllowedContentTypes = []string{"application/json"}
+	}
+	cfg.MaxRequestBody = defaultMaxRequestBody
+	if maxBody, exists := os.LookupEnv("CDV_MAX_REQUEST_BODY"); exists {
+		parsed, err := strconv.ParseInt(maxBody, 10, 64)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_MAX_REQUEST_BODY: %q", maxBody)
+		}
+		cfg.MaxRequestBody = parsed
+	}
+	
 	// Handle deprecation policy
 	if rejectDeprecated, exists := os.LookupEnv("CDV_REJECT_DEPRECATED_SCHEMAS"); exists {
 		cfg.RejectDeprecatedSchemas = parseBool(rejectDeprecated)
 	}
+	if checkSpecs, exists := os.LookupEnv("CDV_READINESS_CHECK_SPECS"); exists {
+		cfg.ReadinessCheckSpecs = parseBool(checkSpecs)
+	}
+	if unvalidated, exists := os.LookupEnv("CDV_UNVALIDATED_COLLECTIONS"); exists {
+		for _, collection := range strings.Split(unvalidated, ",") {
+			if collection = strings.TrimSpace(collection); collection != "" {
+				cfg.UnvalidatedCollections = append(cfg.UnvalidatedCollections, collection)
+			}
+		}
+	}
+	if allowUnknown, exists := os.LookupEnv("CDV_ALLOW_UNKNOWN_COLLECTIONS"); exists {
+		cfg.AllowUnknownCollections = parseBool(allowUnknown)
+	}
+	cfg.ReadinessErrorDetail = true
+	if errorDetail, exists := os.LookupEnv("CDV_READINESS_ERROR_DETAIL"); exists {
+		cfg.ReadinessErrorDetail = parseBool(errorDetail)
+	}
 	
 	// Handle CORS configuration
 	if corsOrigins, exists := os.LookupEnv("CDV_CORS_ALLOWED_ORIGINS"); exists {
@@ -174,6 +436,155 @@ func Load() (Config, error) {
 		}
 	}
 
+	// Handle rate limiting
+	if rps, exists := os.LookupEnv("CDV_ANON_READ_RPS"); exists {
+		parsed, err := strconv.ParseFloat(rps, 64)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_ANON_READ_RPS: %q", rps)
+		}
+		cfg.AnonReadRPS = parsed
+	}
+	if burst, exists := os.LookupEnv("CDV_ANON_READ_BURST"); exists {
+		parsed, err := strconv.Atoi(burst)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_ANON_READ_BURST: %q", burst)
+		}
+		cfg.AnonReadBurst = parsed
+	}
+	if rps, exists := os.LookupEnv("CDV_RATE_LIMIT_RPS"); exists {
+		parsed, err := strconv.ParseFloat(rps, 64)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_RATE_LIMIT_RPS: %q", rps)
+		}
+		cfg.RateLimitRPS = parsed
+	}
+	if burst, exists := os.LookupEnv("CDV_RATE_LIMIT_BURST"); exists {
+		parsed, err := strconv.Atoi(burst)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_RATE_LIMIT_BURST: %q", burst)
+		}
+		cfg.RateLimitBurst = parsed
+	}
+	if proxies, exists := os.LookupEnv("CDV_TRUSTED_PROXIES"); exists {
+		for _, proxy := range strings.Split(proxies, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy == "" {
+				continue
+			}
+			prefix, err := parsePrefix(proxy)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid CDV_TRUSTED_PROXIES: %q", proxy)
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
+		}
+	}
+
+	// Handle metrics histogram buckets
+	if buckets, exists := os.LookupEnv("CDV_METRICS_LATENCY_BUCKETS"); exists {
+		parsed, err := parseFloatList(buckets)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CDV_METRICS_LATENCY_BUCKETS: %w", err)
+		}
+		cfg.MetricsLatencyBuckets = parsed
+	}
+	
+	if buckets, exists := os.LookupEnv("CDV_METRICS_MEDIA_BUCKETS"); exists {
+		parsed, err := parseFloatList(buckets)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CDV_METRICS_MEDIA_BUCKETS: %w", err)
+		}
+		cfg.MetricsMediaBuckets = parsed
+	}
+
+	// Handle event publish queue
+	cfg.EventQueueSize = defaultEventQueueSize
+	if size, exists := os.LookupEnv("CDV_EVENT_QUEUE_SIZE"); exists {
+		parsed, err := strconv.Atoi(size)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_EVENT_QUEUE_SIZE: %q", size)
+		}
+		cfg.EventQueueSize = parsed
+	}
+	cfg.EventWorkers = defaultEventWorkers
+	if workers, exists := os.LookupEnv("CDV_EVENT_WORKERS"); exists {
+		parsed, err := strconv.Atoi(workers)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_EVENT_WORKERS: %q", workers)
+		}
+		cfg.EventWorkers = parsed
+	}
+	cfg.EventDrainTimeout = defaultEventDrainTimeout
+	if timeout, exists := os.LookupEnv("CDV_EVENT_DRAIN_TIMEOUT"); exists {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_EVENT_DRAIN_TIMEOUT: %q", timeout)
+		}
+		cfg.EventDrainTimeout = parsed
+	}
+	cfg.EventDedupWindow = defaultEventDedupWindow
+	if window, exists := os.LookupEnv("CDV_EVENT_DEDUP_WINDOW"); exists {
+		parsed, err := time.ParseDuration(window)
+		if err != nil || parsed <= 0 || parsed > maxEventDedupWindow {
+			return cfg, fmt.Errorf("invalid CDV_EVENT_DEDUP_WINDOW: %q", window)
+		}
+		cfg.EventDedupWindow = parsed
+	}
+
+	// Handle maximum JWT length
+	cfg.JWTMaxLength = defaultJWTMaxLength
+	if maxLen, exists := os.LookupEnv("CDV_JWT_MAX_LENGTH"); exists {
+		parsed, err := strconv.Atoi(maxLen)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWT_MAX_LENGTH: %q", maxLen)
+		}
+		cfg.JWTMaxLength = parsed
+	}
+
+	// Handle JWKS cache TTL
+	cfg.JWKSCacheTTL = defaultJWKSCacheTTL
+	if ttl, exists := os.LookupEnv("CDV_JWKS_CACHE_TTL"); exists {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWKS_CACHE_TTL: %q", ttl)
+		}
+		cfg.JWKSCacheTTL = parsed
+	}
+	cfg.JWKSRefreshInterval = defaultJWKSRefreshInterval
+	if interval, exists := os.LookupEnv("CDV_JWKS_REFRESH_INTERVAL"); exists {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWKS_REFRESH_INTERVAL: %q", interval)
+		}
+		cfg.JWKSRefreshInterval = parsed
+	}
+
+	// Handle JWKS fetch circuit breaker
+	cfg.JWKSBreakerThreshold = defaultJWKSBreakerThreshold
+	if threshold, exists := os.LookupEnv("CDV_JWKS_BREAKER_THRESHOLD"); exists {
+		parsed, err := strconv.Atoi(threshold)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWKS_BREAKER_THRESHOLD: %q", threshold)
+		}
+		cfg.JWKSBreakerThreshold = parsed
+	}
+	cfg.JWKSBreakerCooldown = defaultJWKSBreakerCooldown
+	if cooldown, exists := os.LookupEnv("CDV_JWKS_BREAKER_COOLDOWN"); exists {
+		parsed, err := time.ParseDuration(cooldown)
+		if err != nil || parsed <= 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWKS_BREAKER_COOLDOWN: %q", cooldown)
+		}
+		cfg.JWKSBreakerCooldown = parsed
+	}
+
+	// Handle JWT clock skew leeway
+	cfg.JWTLeeway = defaultJWTLeeway
+	if leeway, exists := os.LookupEnv("CDV_JWT_LEEWAY"); exists {
+		parsed, err := time.ParseDuration(leeway)
+		if err != nil || parsed < 0 {
+			return cfg, fmt.Errorf("invalid CDV_JWT_LEEWAY: %q", leeway)
+		}
+		cfg.JWTLeeway = parsed
+	}
+
 	// Validate required parameters
 	if cfg.JWTIssuer == "" {
 		return cfg, fmt.Errorf("CDV_JWT_ISSUER is required")
@@ -202,3 +613,39 @@ func parseBool(v string) bool {
 	}
 	return b
 }
+
+// parseFloatList parses a comma-separated list of floats in strictly increasing order,
+// as required for histogram bucket boundaries
+func parseFloatList(v string) ([]float64, error) {
+	var values []float64
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q: %w", part, err)
+		}
+		if len(values) > 0 && f <= values[len(values)-1] {
+			return nil, fmt.Errorf("values must be strictly increasing")
+		}
+		values = append(values, f)
+	}
+	return values, nil
+}
+
+// parsePrefix parses a CIDR block or a single IP address, which is treated as a
+// block containing only that address.
+func parsePrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		prefix, err := netip.ParsePrefix(v)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
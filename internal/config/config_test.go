@@ -2,8 +2,15 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestLoad tests the Load function with default values.
@@ -124,3 +131,504 @@ func TestLoadWithEnv(t *testing.T) {
 		t.Errorf("Load() IdentityURL = %v, want %v", cfg.IdentityURL, "http://localhost:8081")
 	}
 }
+
+// TestLoadMetricsBuckets tests parsing of histogram bucket overrides.
+func TestLoadMetricsBuckets(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+	os.Setenv("CDV_METRICS_LATENCY_BUCKETS", "0.005, 0.05,0.5")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_METRICS_LATENCY_BUCKETS")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.MetricsLatencyBuckets) != 3 || cfg.MetricsLatencyBuckets[1] != 0.05 {
+		t.Errorf("Load() MetricsLatencyBuckets = %v, want %v", cfg.MetricsLatencyBuckets, []float64{0.005, 0.05, 0.5})
+	}
+	if len(cfg.MetricsMediaBuckets) != 0 {
+		t.Errorf("Load() MetricsMediaBuckets = %v, want empty", cfg.MetricsMediaBuckets)
+	}
+
+	// Buckets must be strictly increasing
+	os.Setenv("CDV_METRICS_LATENCY_BUCKETS", "0.5,0.05")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for non-increasing buckets")
+	}
+}
+
+// TestLoadJWKSCacheTTL tests the JWKS cache TTL and refresh interval defaults and overrides.
+func TestLoadJWKSCacheTTL(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_JWKS_CACHE_TTL")
+		os.Unsetenv("CDV_JWKS_REFRESH_INTERVAL")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWKSCacheTTL != 5*time.Minute {
+		t.Errorf("Load() JWKSCacheTTL = %v, want %v", cfg.JWKSCacheTTL, 5*time.Minute)
+	}
+
+	os.Setenv("CDV_JWKS_CACHE_TTL", "90s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWKSCacheTTL != 90*time.Second {
+		t.Errorf("Load() JWKSCacheTTL = %v, want %v", cfg.JWKSCacheTTL, 90*time.Second)
+	}
+
+	os.Setenv("CDV_JWKS_CACHE_TTL", "soon")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for invalid duration")
+	}
+	os.Unsetenv("CDV_JWKS_CACHE_TTL")
+
+	if cfg.JWKSRefreshInterval != 5*time.Minute {
+		t.Errorf("Load() JWKSRefreshInterval = %v, want %v", cfg.JWKSRefreshInterval, 5*time.Minute)
+	}
+	os.Setenv("CDV_JWKS_REFRESH_INTERVAL", "1m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWKSRefreshInterval != time.Minute {
+		t.Errorf("Load() JWKSRefreshInterval = %v, want %v", cfg.JWKSRefreshInterval, time.Minute)
+	}
+	os.Setenv("CDV_JWKS_REFRESH_INTERVAL", "0s")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for zero refresh interval")
+	}
+}
+
+// TestLoadSlowQueryThreshold tests parsing of the slow query threshold.
+func TestLoadSlowQueryThreshold(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_SLOW_QUERY_MS")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.SlowQueryThreshold != 250*time.Millisecond {
+		t.Errorf("Load() SlowQueryThreshold = %v, want %v", cfg.SlowQueryThreshold, 250*time.Millisecond)
+	}
+
+	os.Setenv("CDV_SLOW_QUERY_MS", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.SlowQueryThreshold != 0 {
+		t.Errorf("Load() SlowQueryThreshold = %v, want 0", cfg.SlowQueryThreshold)
+	}
+
+	for _, invalid := range []string{"-1", "1s"} {
+		os.Setenv("CDV_SLOW_QUERY_MS", invalid)
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() expected error for CDV_SLOW_QUERY_MS=%q", invalid)
+		}
+	}
+}
+
+// TestLoadJWTLeeway tests the JWT clock skew leeway default and override.
+func TestLoadJWTLeeway(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_JWT_LEEWAY")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWTLeeway != 60*time.Second {
+		t.Errorf("Load() JWTLeeway = %v, want %v", cfg.JWTLeeway, 60*time.Second)
+	}
+
+	os.Setenv("CDV_JWT_LEEWAY", "0s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWTLeeway != 0 {
+		t.Errorf("Load() JWTLeeway = %v, want 0", cfg.JWTLeeway)
+	}
+
+	os.Setenv("CDV_JWT_LEEWAY", "-1s")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for negative leeway")
+	}
+}
+
+// TestLoadNATSAutoCreateStreams tests that stream auto-creation defaults to on and rejects invalid values.
+func TestLoadNATSAutoCreateStreams(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_NATS_AUTO_CREATE_STREAMS")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.NATSAutoCreateStreams {
+		t.Error("Load() NATSAutoCreateStreams = false, want true by default")
+	}
+
+	os.Setenv("CDV_NATS_AUTO_CREATE_STREAMS", "false")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.NATSAutoCreateStreams {
+		t.Error("Load() NATSAutoCreateStreams = true, want false")
+	}
+
+	os.Setenv("CDV_NATS_AUTO_CREATE_STREAMS", "maybe")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for CDV_NATS_AUTO_CREATE_STREAMS=\"maybe\"")
+	}
+}
+
+// TestLoadNATSMaxPending tests the NATS pending publish bound default, override and validation.
+func TestLoadNATSMaxPending(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_NATS_MAX_PENDING")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.NATSMaxPending != 256 {
+		t.Errorf("Load() NATSMaxPending = %d, want 256", cfg.NATSMaxPending)
+	}
+
+	os.Setenv("CDV_NATS_MAX_PENDING", "1024")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.NATSMaxPending != 1024 {
+		t.Errorf("Load() NATSMaxPending = %d, want 1024", cfg.NATSMaxPending)
+	}
+
+	for _, invalid := range []string{"0", "-1", "many"} {
+		os.Setenv("CDV_NATS_MAX_PENDING", invalid)
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() expected error for CDV_NATS_MAX_PENDING=%q", invalid)
+		}
+	}
+}
+
+// TestLoadEventDedupWindow tests the event dedup window default, override and bounds.
+func TestLoadEventDedupWindow(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_EVENT_DEDUP_WINDOW")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.EventDedupWindow != 5*time.Minute {
+		t.Errorf("Load() EventDedupWindow = %v, want %v", cfg.EventDedupWindow, 5*time.Minute)
+	}
+
+	os.Setenv("CDV_EVENT_DEDUP_WINDOW", "24h")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.EventDedupWindow != 24*time.Hour {
+		t.Errorf("Load() EventDedupWindow = %v, want %v", cfg.EventDedupWindow, 24*time.Hour)
+	}
+
+	for _, invalid := range []string{"0s", "-1m", "25h", "soon"} {
+		os.Setenv("CDV_EVENT_DEDUP_WINDOW", invalid)
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() expected error for CDV_EVENT_DEDUP_WINDOW=%q", invalid)
+		}
+	}
+}
+
+// TestLoadJWTTrustedAudiences tests parsing of the trusted audience list.
+func TestLoadJWTTrustedAudiences(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+	os.Setenv("CDV_JWT_TRUSTED_AUDIENCES", "partner-app, mobile-app,,")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_JWT_TRUSTED_AUDIENCES")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"partner-app", "mobile-app"}
+	if !reflect.DeepEqual(cfg.JWTTrustedAudiences, want) {
+		t.Errorf("Load() JWTTrustedAudiences = %v, want %v", cfg.JWTTrustedAudiences, want)
+	}
+}
+
+// TestLoadReadinessErrorDetail tests that readiness error detail is on unless disabled.
+func TestLoadReadinessErrorDetail(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_READINESS_ERROR_DETAIL")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.ReadinessErrorDetail {
+		t.Error("Load() ReadinessErrorDetail = false, want true by default")
+	}
+
+	os.Setenv("CDV_READINESS_ERROR_DETAIL", "false")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ReadinessErrorDetail {
+		t.Error("Load() ReadinessErrorDetail = true, want false")
+	}
+}
+
+// TestLoadUnvalidatedCollections tests that unvalidated collections are trimmed and empty
+// entries dropped.
+func TestLoadUnvalidatedCollections(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+	os.Setenv("CDV_UNVALIDATED_COLLECTIONS", "app.bsky.*, com.example.note,,")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_UNVALIDATED_COLLECTIONS")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"app.bsky.*", "com.example.note"}
+	if !reflect.DeepEqual(cfg.UnvalidatedCollections, want) {
+		t.Errorf("Load() UnvalidatedCollections = %v, want %v", cfg.UnvalidatedCollections, want)
+	}
+}
+
+// TestLoadAllowUnknownCollections tests that unknown collections are rejected unless enabled.
+func TestLoadAllowUnknownCollections(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_ALLOW_UNKNOWN_COLLECTIONS")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.AllowUnknownCollections {
+		t.Error("Load() AllowUnknownCollections = true, want false by default")
+	}
+
+	os.Setenv("CDV_ALLOW_UNKNOWN_COLLECTIONS", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.AllowUnknownCollections {
+		t.Error("Load() AllowUnknownCollections = false, want true")
+	}
+}
+
+// TestLoadJWTAllowedTypes tests that an empty entry is kept to accept tokens without typ.
+func TestLoadJWTAllowedTypes(t *testing.T) {
+	os.Setenv("CDV_JWT_ISSUER", "test-issuer")
+	os.Setenv("CDV_JWT_AUDIENCE", "test-audience")
+	os.Setenv("CDV_JWT_ALLOWED_TYPES", "at+jwt, JWT,")
+
+	// Clean up environment variables after test
+	t.Cleanup(func() {
+		os.Unsetenv("CDV_JWT_ISSUER")
+		os.Unsetenv("CDV_JWT_AUDIENCE")
+		os.Unsetenv("CDV_JWT_ALLOWED_TYPES")
+	})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"at+jwt", "JWT", ""}
+	if !reflect.DeepEqual(cfg.JWTAllowedTypes, want) {
+		t.Errorf("Load() JWTAllowedTypes = %v, want %v", cfg.JWTAllowedTypes, want)
+	}
+}
+
+// TestLoadConfigFile tests that YAML and JSON config files fill in unset variables,
+// and that environment variables take precedence over the file.
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "CDV_JWT_ISSUER: file-issuer\nCDV_JWT_AUDIENCE: file-audience\nCDV_PORT: 9090\nCDV_JWT_TRUSTED_AUDIENCES: [partner-app, mobile-app]\n",
+		"config.json": `{"CDV_JWT_ISSUER": "file-issuer", "CDV_JWT_AUDIENCE": "file-audience", "CDV_PORT": 9090, "CDV_JWT_TRUSTED_AUDIENCES": ["partner-app", "mobile-app"]}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			os.Setenv("CDV_CONFIG_FILE", path)
+			os.Setenv("CDV_JWT_AUDIENCE", "env-audience")
+
+			// Clean up environment variables after test, including those applied from the file
+			t.Cleanup(func() {
+				for _, key := range []string{"CDV_CONFIG_FILE", "CDV_JWT_ISSUER", "CDV_JWT_AUDIENCE", "CDV_PORT", "CDV_JWT_TRUSTED_AUDIENCES"} {
+					os.Unsetenv(key)
+				}
+			})
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.JWTIssuer != "file-issuer" || cfg.Port != "9090" {
+				t.Errorf("Load() JWTIssuer = %q, Port = %q, want values from the file", cfg.JWTIssuer, cfg.Port)
+			}
+			if cfg.JWTAudience != "env-audience" {
+				t.Errorf("Load() JWTAudience = %q, want the environment value env-audience", cfg.JWTAudience)
+			}
+			if want := []string{"partner-app", "mobile-app"}; !reflect.DeepEqual(cfg.JWTTrustedAudiences, want) {
+				t.Errorf("Load() JWTTrustedAudiences = %v, want %v", cfg.JWTTrustedAudiences, want)
+			}
+		})
+	}
+
+	// Nested values cannot be expressed as environment variables
+	path := filepath.Join(dir, "nested.yaml")
+	if err := os.WriteFile(path, []byte("CDV_PORT:\n  value: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CDV_CONFIG_FILE", path)
+	t.Cleanup(func() { os.Unsetenv("CDV_CONFIG_FILE") })
+	if _, err := Load(); err == nil {
+		t.Error("Load() with a nested value succeeded, want error")
+	}
+}
+
+// TestReloadConfigFile tests that a second Load picks up changed file values.
+func TestReloadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.Setenv("CDV_CONFIG_FILE", path)
+	t.Cleanup(func() {
+		for _, key := range []string{"CDV_CONFIG_FILE", "CDV_JWT_ISSUER", "CDV_JWT_AUDIENCE", "CDV_MAX_MEDIA_SIZE"} {
+			os.Unsetenv(key)
+		}
+	})
+	
+	for _, size := range []int64{1024, 2048} {
+		content := fmt.Sprintf("CDV_JWT_ISSUER: issuer\nCDV_JWT_AUDIENCE: audience\nCDV_MAX_MEDIA_SIZE: %d\n", size)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.MaxMediaSize != size {
+			t.Errorf("Load() MaxMediaSize = %d, want %d", cfg.MaxMediaSize, size)
+		}
+	}
+}
+
+// TestConfigLogValue tests that logged configuration names the backends and redacts secrets.
+func TestConfigLogValue(t *testing.T) {
+	cfg := Config{
+		DatabaseDSN:     "postgres://cdv:dbpass@db:5432/cdv",
+		NATSURL:         "nats://localhost:4222",
+		S3SecretKey:     "s3secret",
+		DPoPNonceSecret: "noncesecret",
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("effective configuration", "config", cfg)
+	out := buf.String()
+	
+	for _, secret := range []string{"dbpass", "s3secret", "noncesecret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logged configuration contains secret %q: %s", secret, out)
+		}
+	}
+	for _, want := range []string{`"storage":"postgres"`, `"events":"nats"`, `"media":"none"`, `"db_dsn":"postgres://cdv:REDACTED@db:5432/cdv"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logged configuration missing %s: %s", want, out)
+		}
+	}
+	
+	if got := redactDSN("host=db user=cdv password=dbpass dbname=cdv"); got != "host=db user=cdv password=REDACTED dbname=cdv" {
+		t.Errorf("redactDSN() = %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+// internal/config/file.go
+// Optional configuration file support for deployments that mount config files.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Variables set from the config file, which a later Load (config reload) may update
+var (
+	fileKeys   = map[string]bool{}
+	fileKeysMu sync.Mutex
+)
+
+// loadConfigFile reads the YAML or JSON file named by CDV_CONFIG_FILE, a flat mapping of
+// environment variable names to values, and sets each variable that is not already set.
+// Environment variables therefore take precedence over the file, and settings read
+// directly from the environment elsewhere (S3, NATS) see file values too.
+func loadConfigFile() error {
+	path := os.Getenv("CDV_CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CDV_CONFIG_FILE: %w", err)
+	}
+	// YAML is a superset of JSON, so one decoder handles both
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse CDV_CONFIG_FILE %q: %w", path, err)
+	}
+
+	fileKeysMu.Lock()
+	defer fileKeysMu.Unlock()
+	for key, value := range values {
+		str, err := configFileValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s in CDV_CONFIG_FILE: %w", key, err)
+		}
+		// Values the file set earlier may be replaced; real environment variables may not
+		if _, exists := os.LookupEnv(key); exists && !fileKeys[key] {
+			continue
+		}
+		if err := os.Setenv(key, str); err != nil {
+			return fmt.Errorf("failed to apply %s from CDV_CONFIG_FILE: %w", key, err)
+		}
+		fileKeys[key] = true
+	}
+	return nil
+}
+
+// configFileValue renders a file value the way it would be written as an environment
+// variable; lists become comma-separated strings
+func configFileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configFileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
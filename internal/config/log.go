@@ -0,0 +1,133 @@
+// internal/config/log.go
+// Startup logging of the effective configuration, with secrets redacted.
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+)
+
+// redacted replaces secret values in logged configuration
+const redacted = "REDACTED"
+
+// dsnPasswordPattern matches the password in a keyword/value connection string
+var dsnPasswordPattern = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// LogValue implements slog.LogValuer so the effective configuration can be logged
+// at startup. Secrets are redacted; set-but-secret values log as REDACTED.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Group("backends",
+			slog.String("storage", c.storageBackend()),
+			slog.String("events", c.eventBackend()),
+			slog.String("media", c.mediaBackend()),
+		),
+		slog.String("env", c.Env),
+		slog.String("instance_id", c.InstanceID),
+		slog.String("port", c.Port),
+		slog.String("db_dsn", redactDSN(c.DatabaseDSN)),
+		slog.Duration("slow_query_threshold", c.SlowQueryThreshold),
+		slog.String("nats_url", redactDSN(c.NATSURL)),
+		slog.Bool("nats_auto_create_streams", c.NATSAutoCreateStreams),
+		slog.Int("nats_max_pending", c.NATSMaxPending),
+		slog.String("redis_url", redactDSN(c.RedisURL)),
+		slog.Int("event_queue_size", c.EventQueueSize),
+		slog.Int("event_workers", c.EventWorkers),
+		slog.Duration("event_drain_timeout", c.EventDrainTimeout),
+		slog.Duration("event_dedup_window", c.EventDedupWindow),
+		slog.String("s3_endpoint", c.S3Endpoint),
+		slog.String("s3_region", c.S3Region),
+		slog.String("s3_bucket", c.S3Bucket),
+		slog.String("s3_access_key", c.S3AccessKey),
+		slog.String("s3_secret_key", redactSecret(c.S3SecretKey)),
+		slog.String("jwt_issuer", c.JWTIssuer),
+		slog.String("jwt_audience", c.JWTAudience),
+		slog.Any("jwt_trusted_audiences", c.JWTTrustedAudiences),
+		slog.Any("jwt_allowed_types", c.JWTAllowedTypes),
+		slog.Duration("jwt_leeway", c.JWTLeeway),
+		slog.Int("jwt_max_length", c.JWTMaxLength),
+		slog.Bool("jwt_replay_protection", c.JWTReplayProtection),
+		slog.Bool("require_auth_reads", c.RequireAuthReads),
+		slog.Bool("dpop_enabled", c.DPoPEnabled),
+		slog.String("dpop_nonce_secret", redactSecret(c.DPoPNonceSecret)),
+		slog.Any("admin_dids", c.AdminDIDs),
+		slog.String("auth_cookie_name", c.AuthCookieName),
+		slog.String("identity_url", c.IdentityURL),
+		slog.String("specs_url", c.SpecsURL),
+		slog.String("schema_dir", c.SchemaDir),
+		slog.Duration("jwks_cache_ttl", c.JWKSCacheTTL),
+		slog.Duration("jwks_refresh_interval", c.JWKSRefreshInterval),
+		slog.Int("jwks_breaker_threshold", c.JWKSBreakerThreshold),
+		slog.Duration("jwks_breaker_cooldown", c.JWKSBreakerCooldown),
+		slog.Int64("max_media_size", c.MaxMediaSize),
+		slog.Any("allowed_mime_types", c.AllowedMimeTypes),
+		slog.Int("max_concurrent_verify", c.MaxConcurrentVerify),
+		slog.Int("max_pending_uploads", c.MaxPendingUploads),
+		slog.Int("export_page_size", c.ExportPageSize),
+		slog.Int("max_concurrent_exports", c.MaxConcurrentExports),
+		slog.Duration("max_query_window", c.MaxQueryWindow),
+		slog.Duration("cursor_ttl", c.CursorTTL),
+		slog.Any("allowed_content_types", c.AllowedContentTypes),
+		slog.Int64("max_request_body", c.MaxRequestBody),
+		slog.Int("feed_max_fanout", c.FeedMaxFanout),
+		slog.Int("feed_max_per_author", c.FeedMaxPerAuthor),
+		slog.Bool("reject_deprecated_schemas", c.RejectDeprecatedSchemas),
+		slog.Bool("readiness_check_specs", c.ReadinessCheckSpecs),
+		slog.Any("unvalidated_collections", c.UnvalidatedCollections),
+		slog.Bool("allow_unknown_collections", c.AllowUnknownCollections),
+		slog.Bool("readiness_error_detail", c.ReadinessErrorDetail),
+		slog.Any("cors_allowed_origins", c.CORSAllowedOrigins),
+		slog.Float64("anon_read_rps", c.AnonReadRPS),
+		slog.Int("anon_read_burst", c.AnonReadBurst),
+		slog.Float64("rate_limit_rps", c.RateLimitRPS),
+		slog.Int("rate_limit_burst", c.RateLimitBurst),
+		slog.Any("trusted_proxies", c.TrustedProxies),
+		slog.Any("metrics_latency_buckets", c.MetricsLatencyBuckets),
+		slog.Any("metrics_media_buckets", c.MetricsMediaBuckets),
+	)
+}
+
+// storageBackend names the storage backend the configuration selects
+func (c Config) storageBackend() string {
+	if c.DatabaseDSN != "" {
+		return "postgres"
+	}
+	return "memory"
+}
+
+// eventBackend names the event backend the configuration selects; the service
+// still falls back to noop if NATS is unreachable at startup
+func (c Config) eventBackend() string {
+	if c.NATSURL != "" {
+		return "nats"
+	}
+	return "noop"
+}
+
+// mediaBackend names the media backend the configuration selects
+func (c Config) mediaBackend() string {
+	if c.S3Endpoint != "" && c.S3Bucket != "" {
+		return "s3"
+	}
+	return "none"
+}
+
+// redactSecret hides a secret while still showing whether it is set
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactDSN hides the password in a URL or keyword/value connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}"+redacted)
+}
@@ -15,6 +15,7 @@
 	CDV_SCHEMA_REJECT  ErrorCode = "CDV_SCHEMA_REJECT"  // Schema validation failed
 	CDV_BAD_REQUEST    ErrorCode = "CDV_BAD_REQUEST"    // Bad request
 	CDV_CURSOR_INVALID ErrorCode = "CDV_CURSOR_INVALID" // Invalid cursor
+	CDV_BODY_TOO_LARGE ErrorCode = "CDV_BODY_TOO_LARGE" // Request body exceeds the size limit
 
 	// Authentication/Authorization errors
 	CDV_AUTHZ        ErrorCode = "CDV_AUTHZ"        // Authorization failed
@@ -33,6 +34,7 @@
 
 	// Rate limiting
 	CDV_RATE_LIMIT ErrorCode = "CDV_RATE_LIMIT" // Rate limit exceeded
+	CDV_QUOTA_EXCEEDED ErrorCode = "CDV_QUOTA_EXCEEDED" // Per-DID quota exceeded
 
 	// Server errors
 	CDV_INTERNAL     ErrorCode = "CDV_INTERNAL"     // Internal server error
@@ -40,6 +42,15 @@
 	CDV_NOT_IMPLEMENTED ErrorCode = "CDV_NOT_IMPLEMENTED" // Not implemented
 )
 
+// Codes lists every error code the service can return, for API documentation.
+var Codes = []ErrorCode{
+	CDV_VALIDATION, CDV_SCHEMA_REJECT, CDV_BAD_REQUEST, CDV_CURSOR_INVALID, CDV_BODY_TOO_LARGE,
+	CDV_AUTHZ, CDV_AUTHN, CDV_JWT_INVALID, CDV_JWT_EXPIRED, CDV_JWT_MALFORMED, CDV_DID_MISMATCH,
+	CDV_NOT_FOUND, CDV_CONFLICT, CDV_MEDIA_CHECKSUM, CDV_MEDIA_SIZE, CDV_MEDIA_TYPE,
+	CDV_RATE_LIMIT, CDV_QUOTA_EXCEEDED,
+	CDV_INTERNAL, CDV_UNAVAILABLE, CDV_NOT_IMPLEMENTED,
+}
+
 // Error represents a standardized error response.
 type Error struct {
 	Code         ErrorCode `json:"code"`
@@ -83,6 +94,8 @@ func httpStatusCodeForCode(code ErrorCode) int {
 	switch code {
 	case CDV_VALIDATION, CDV_SCHEMA_REJECT, CDV_BAD_REQUEST, CDV_CURSOR_INVALID:
 		return http.StatusBadRequest
+	case CDV_BODY_TOO_LARGE:
+		return http.StatusRequestEntityTooLarge
 	case CDV_AUTHZ, CDV_DID_MISMATCH:
 		return http.StatusForbidden
 	case CDV_AUTHN, CDV_JWT_INVALID, CDV_JWT_EXPIRED, CDV_JWT_MALFORMED:
@@ -93,7 +106,7 @@ func httpStatusCodeForCode(code ErrorCode) int {
 		return http.StatusConflict
 	case CDV_MEDIA_CHECKSUM, CDV_MEDIA_SIZE, CDV_MEDIA_TYPE:
 		return http.StatusBadRequest
-	case CDV_RATE_LIMIT:
+	case CDV_RATE_LIMIT, CDV_QUOTA_EXCEEDED:
 		return http.StatusTooManyRequests
 	case CDV_UNAVAILABLE:
 		return http.StatusServiceUnavailable
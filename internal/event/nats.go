@@ -5,25 +5,50 @@
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"sync"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 )
 
-// ContextKey is used for context values to avoid collisions
-// when storing values in request context
-type ContextKey string
-
 const (
 	// ContextKeyCorrelationID is the key for storing correlation ID in request context
-	ContextKeyCorrelationID ContextKey = "correlationId" // Unique ID for request tracking
+	ContextKeyCorrelationID = telemetry.CorrelationIDKey // Unique ID for request tracking, set by the server
+)
+
+// JetStream duplicate-detection window configured on the streams (WithDedupWindow).
+// Publishes carrying the same Nats-Msg-Id within this window are dropped server-side.
+// JetStream requires the window to fit within the stream's MaxAge.
+const (
+	defaultDedupWindow = 5 * time.Minute
+	eventStreamMaxAge  = 24 * time.Hour // How long RA_RECORDS and RA_MEDIA keep events
+)
+
+// Asynchronous publish settings
+const (
+	defaultMaxPending  = 256              // Default bound on unacknowledged async publishes
+	maxPublishAttempts = 3                // Publish attempts before an event is dead-lettered
+	retryBackoff       = 100 * time.Millisecond // Delay before the first retry, doubled for each further attempt
+	closeDrainTimeout  = 5 * time.Second  // How long Close waits for outstanding acknowledgments
+	dlqSubjectPrefix   = "cdv.dlq."       // Dead-lettered events are republished under this prefix
+
+	headerEventType       = "Cdv-Event-Type"       // Event kind, for metrics
+	headerAttempt         = "Cdv-Publish-Attempt"  // 1-based publish attempt number
+	headerOriginalSubject = "Cdv-Original-Subject" // Subject a dead-lettered event was published to
+	headerError           = "Cdv-Error"            // Last publish error of a dead-lettered event
 )
 
 // Publisher interface defines the event publishing operations required by the CDV service.
@@ -31,18 +56,58 @@
 type Publisher interface {
 	// Record events
 	PublishRecordCreated(ctx context.Context, collection string, record model.Record) error
+	PublishRecordsCreated(ctx context.Context, records []model.Record) error // Publish a batch, keyed by each record's collection
+	PublishRecordUpdated(ctx context.Context, record model.Record) error
+	PublishRecordDeleted(ctx context.Context, record model.Record) error
 	
 	// Media events
+	PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error
 	PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error
 	
 	// Close closes the publisher connection
 	Close() error
 }
 
+// ReadinessChecker is implemented by publishers that can report whether the event backend
+// is ready to accept events
+type ReadinessChecker interface {
+	Ready(ctx context.Context) error
+}
+
+// StatusReporter is implemented by publishers that report which event backend is in use
+type StatusReporter interface {
+	// Status returns the backend type ("nats" or "noop") and, when NATS was configured but
+	// could not be initialized, the error that caused the fallback to noop
+	Status() (string, error)
+}
+
+// Publisher backend types reported by Status and the event_publisher_active metric
+const (
+	PublisherTypeNATS = "nats"
+	PublisherTypeNoop = "noop"
+)
+
+// requiredStreams are the streams events are published to, each with subjects it must
+// capture; initStreams creates them. Record subjects carry the dotted collection NSID, so
+// the examples span several tokens.
+var requiredStreams = []struct {
+	name     string
+	subjects []string
+}{
+	{"RA_RECORDS", []string{"cdv.records.com.registryaccord.feed.post.created", "cdv.records.com.registryaccord.feed.post.updated", "cdv.records.com.registryaccord.feed.post.deleted"}},
+	{"RA_MEDIA", []string{"cdv.media.uploaded", "cdv.media.finalized"}},
+	{"RA_DLQ", []string{dlqSubjectPrefix + "cdv.records.com.registryaccord.feed.post.created", dlqSubjectPrefix + "cdv.media.finalized"}},
+}
+
 // noop is a no-op implementation of Publisher for when NATS is not configured.
 // It implements all Publisher methods but does nothing, allowing the service
 // to function without event streaming when NATS is not available.
-type noop struct{}
+type noop struct {
+	fallbackErr error // Why a configured NATS backend was replaced, nil when NATS is not configured
+}
+
+// Status implements StatusReporter
+func (n *noop) Status() (string, error) { return PublisherTypeNoop, n.fallbackErr }
 
 // Close implements Publisher
 // It does nothing and always returns nil.
@@ -54,6 +119,30 @@ func (n *noop) PublishRecordCreated(ctx context.Context, collection string, reco
 	return nil 
 }
 
+// PublishRecordsCreated implements Publisher
+// It does nothing and always returns nil.
+func (n *noop) PublishRecordsCreated(ctx context.Context, records []model.Record) error {
+	return nil
+}
+
+// PublishRecordUpdated implements Publisher
+// It does nothing and always returns nil.
+func (n *noop) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+// PublishRecordDeleted implements Publisher
+// It does nothing and always returns nil.
+func (n *noop) PublishRecordDeleted(ctx context.Context, record model.Record) error {
+	return nil
+}
+
+// PublishMediaUploadInitiated implements Publisher
+// It does nothing and always returns nil.
+func (n *noop) PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error {
+	return nil
+}
+
 // PublishMediaFinalized implements Publisher
 // It does nothing and always returns nil.
 func (n *noop) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error { 
@@ -62,24 +151,90 @@ func (n *noop) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset
 
 // natsPub is the NATS JetStream implementation of Publisher.
 // It connects to a NATS server and publishes events to JetStream streams.
+// Deduplication is delegated to JetStream via the Nats-Msg-Id header, so it
+// holds across restarts and multiple service instances.
+// Single events are published asynchronously: callers return once the event is
+// queued, acknowledgments are tracked in the background, and failed publishes are
+// retried and finally dead-lettered by handlePublishError.
 type natsPub struct {
 	nc *nats.Conn          // NATS connection
 	js nats.JetStreamContext // JetStream context for stream operations
-	
-	// Deduplication fields
-	recordDedup map[string]time.Time // Map of correlation IDs to last publish time for records
-	mediaDedup  map[string]time.Time // Map of correlation IDs to last publish time for media
-	mutex       sync.RWMutex         // Mutex for thread-safe access to dedup maps
+	metrics *metrics.Metrics // Publish outcome metrics
+	acks chan pendingAck     // Outstanding publishes awaiting acknowledgment
+	done chan struct{}       // Closed to stop the acknowledgment tracker
+	verifyStreams bool       // Whether streams are pre-provisioned and checked by Ready
+	closing chan struct{}    // Closed by Close so retries waiting out their backoff publish at once
+	retrying atomic.Int64    // Retries scheduled but not yet republished
 }
 
-// NewPublisherFromEnv creates a new publisher based on environment configuration.
-// It reads the CDV_NATS_URL environment variable to determine if NATS should be used.
-// If NATS is not configured or connection fails, it returns a no-op publisher.
+// pendingAck is an asynchronous publish awaiting its acknowledgment
+type pendingAck struct {
+	future    nats.PubAckFuture // Resolves when JetStream acks or rejects the message
+	eventType string            // Event kind, for metrics
+}
+
+// PublisherOption configures NewPublisher
+type PublisherOption func(*publisherOptions)
+
+// publisherOptions holds the settings of NewPublisher
+type publisherOptions struct {
+	dedupWindow       time.Duration // Duplicate-detection window of the streams
+	autoCreateStreams bool          // Whether the streams are created or updated on startup
+	maxPending        int           // Bound on unacknowledged async publishes
+}
+
+// WithDedupWindow sets the JetStream duplicate-detection window of the streams; it must fit
+// within the event retention of 24h (default 5m)
+func WithDedupWindow(d time.Duration) PublisherOption {
+	return func(o *publisherOptions) {
+		o.dedupWindow = d
+	}
+}
+
+// WithAutoCreateStreams sets whether the streams are created or updated on startup (default
+// true). Without it the streams must be pre-provisioned; until they exist readiness fails.
+func WithAutoCreateStreams(enabled bool) PublisherOption {
+	return func(o *publisherOptions) {
+		o.autoCreateStreams = enabled
+	}
+}
+
+// WithMaxPending bounds the number of unacknowledged asynchronous publishes (default 256)
+func WithMaxPending(n int) PublisherOption {
+	return func(o *publisherOptions) {
+		o.maxPending = n
+	}
+}
+
+// NewPublisher creates a new event publisher.
+// If url is empty or NATS cannot be initialized, it returns a no-op publisher.
+// The backend in use is reported by the event_publisher_active metric.
+// Parameters:
+//   - url: NATS server URL (empty disables publishing)
+//   - opts: Optional settings applied over the defaults
 // Returns:
 //   - Publisher: Either a NATS publisher or a no-op publisher
-func NewPublisherFromEnv() Publisher {
+func NewPublisher(url string, opts ...PublisherOption) Publisher {
+	o := publisherOptions{dedupWindow: defaultDedupWindow, autoCreateStreams: true, maxPending: defaultMaxPending}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	p := newPublisher(url, o)
+	kind, _ := p.(StatusReporter).Status()
+	active := metrics.NewMetrics().EventPublisherActive
+	for _, t := range []string{PublisherTypeNATS, PublisherTypeNoop} {
+		if t == kind {
+			active.WithLabelValues(t).Set(1)
+		} else {
+			active.WithLabelValues(t).Set(0)
+		}
+	}
+	return p
+}
+
+// newPublisher creates the publisher for NewPublisher
+func newPublisher(url string, o publisherOptions) Publisher {
 	// Check if NATS is configured
-	url := os.Getenv("CDV_NATS_URL")
 	if url == "" {
 		return &noop{}
 	}
@@ -88,45 +243,152 @@ func NewPublisherFromEnv() Publisher {
 	nc, err := nats.Connect(url)
 	if err != nil {
 		slog.Warn("NATS connect failed, using noop publisher", "error", err)
-		return &noop{}
+		return &noop{fallbackErr: fmt.Errorf("NATS connect failed: %w", err)}
 	}
 	
-	// Create JetStream context for stream operations
-	js, err := nc.JetStream()
+	p := &natsPub{
+		nc:      nc,
+		metrics: metrics.NewMetrics(),
+		acks:    make(chan pendingAck, o.maxPending),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	
+	// Create JetStream context for stream operations, bounding unacknowledged async publishes
+	js, err := nc.JetStream(nats.PublishAsyncMaxPending(o.maxPending), nats.PublishAsyncErrHandler(p.handlePublishError))
 	if err != nil {
 		slog.Warn("NATS JetStream context creation failed, using noop publisher", "error", err)
 		nc.Close()
-		return &noop{}
+		return &noop{fallbackErr: fmt.Errorf("NATS JetStream context creation failed: %w", err)}
+	}
+	
+	p.js = js
+	
+	// With pre-provisioned streams the service may lack permission to create them, so their
+	// absence fails readiness instead of degrading to noop
+	if !o.autoCreateStreams {
+		p.verifyStreams = true
+		if err := p.Ready(context.Background()); err != nil {
+			slog.Warn("NATS streams are not provisioned; readiness fails until they exist", "error", err)
+		}
+		go p.trackAcks()
+		return p
 	}
 	
 	// Initialize required streams
-	if err := initStreams(js); err != nil {
+	if err := initStreams(js, o.dedupWindow); err != nil {
 		slog.Warn("NATS stream initialization failed, using noop publisher", "error", err)
 		nc.Close()
-		return &noop{}
+		return &noop{fallbackErr: fmt.Errorf("NATS stream initialization failed: %w", err)}
 	}
 	
-	return &natsPub{
-		nc:          nc,
-		js:          js,
-		recordDedup: make(map[string]time.Time),
-		mediaDedup:  make(map[string]time.Time),
+	go p.trackAcks()
+	return p
+}
+
+// Status implements StatusReporter
+func (p *natsPub) Status() (string, error) { return PublisherTypeNATS, nil }
+
+// Ready implements ReadinessChecker. When streams are pre-provisioned it checks that each
+// required stream exists and captures the subjects events are published to, so streams
+// created or fixed after startup are picked up.
+func (p *natsPub) Ready(ctx context.Context) error {
+	if !p.verifyStreams {
+		return nil
+	}
+	var missing []string
+	var errs []error
+	for _, stream := range requiredStreams {
+		info, err := p.js.StreamInfo(stream.name, nats.Context(ctx))
+		if err != nil {
+			if !errors.Is(err, nats.ErrStreamNotFound) {
+				return fmt.Errorf("failed to check stream %s: %w", stream.name, err)
+			}
+			missing = append(missing, stream.name)
+			continue
+		}
+		for _, subject := range stream.subjects {
+			if !slices.ContainsFunc(info.Config.Subjects, func(pattern string) bool { return subjectMatches(pattern, subject) }) {
+				errs = append(errs, fmt.Errorf("NATS stream %s subjects %v do not capture %s", stream.name, info.Config.Subjects, subject))
+				break
+			}
+		}
+	}
+	if len(missing) > 0 {
+		errs = append([]error{fmt.Errorf("missing NATS streams: %s", strings.Join(missing, ", "))}, errs...)
+	}
+	return errors.Join(errs...)
+}
+
+// subjectMatches reports whether subject matches the NATS subject pattern, where * matches
+// one token and a trailing > matches one or more
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return i == len(patternTokens)-1 && len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
 	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// addOrUpdateStream creates a stream, or updates it in place when it already exists with
+// a different configuration (e.g. after the dedup window was changed). If the update is
+// rejected (e.g. a change to an immutable field) the existing stream is kept and a warning
+// logged, since it still accepts events and falling back to noop would drop them all.
+func addOrUpdateStream(js nats.JetStreamContext, cfg *nats.StreamConfig) error {
+	_, err := js.AddStream(cfg)
+	if !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return err
+	}
+	info, err := js.StreamInfo(cfg.Name)
+	if err != nil {
+		return err
+	}
+	if streamConfigMatches(info.Config, *cfg) {
+		return nil
+	}
+	if _, err := js.UpdateStream(cfg); err != nil {
+		slog.Warn("NATS stream configuration differs and could not be updated, using the existing stream",
+			"stream", cfg.Name, "error", err,
+			"subjects", info.Config.Subjects, "wantSubjects", cfg.Subjects,
+			"storage", info.Config.Storage, "wantStorage", cfg.Storage,
+			"maxAge", info.Config.MaxAge, "wantMaxAge", cfg.MaxAge,
+			"duplicates", info.Config.Duplicates, "wantDuplicates", cfg.Duplicates)
+		return nil
+	}
+	slog.Info("NATS stream configuration updated", "stream", cfg.Name)
+	return nil
+}
+
+// streamConfigMatches reports whether an existing stream has the settings initStreams manages
+func streamConfigMatches(have, want nats.StreamConfig) bool {
+	return slices.Equal(have.Subjects, want.Subjects) &&
+		have.Retention == want.Retention &&
+		have.MaxAge == want.MaxAge &&
+		have.Discard == want.Discard &&
+		have.Storage == want.Storage &&
+		have.Duplicates == want.Duplicates
 }
 
 // initStreams initializes the required NATS streams.
 // It creates the RA_RECORDS and RA_MEDIA streams with appropriate configurations.
 // These streams are used for event streaming and audit trails.
-func initStreams(js nats.JetStreamContext) error {
+func initStreams(js nats.JetStreamContext, dedupWindow time.Duration) error {
 	// Create RA_RECORDS stream for record-related events
 	// This stream handles all record creation and modification events
-	_, err := js.AddStream(&nats.StreamConfig{
+	err := addOrUpdateStream(js, &nats.StreamConfig{
 		Name:      "RA_RECORDS",               // Stream name
-		Subjects:  []string{"cdv.records.*"},  // Subjects pattern for record events
+		Subjects:  []string{"cdv.records.>"},  // cdv.records.<collection>.<action>; collections are dotted
 		Retention: nats.LimitsPolicy,          // Retention policy
-		MaxAge:    24 * time.Hour,             // Keep events for 24 hours
+		MaxAge:    eventStreamMaxAge,          // Keep events for 24 hours
 		Discard:   nats.DiscardOld,            // Discard old messages when limits reached
 		Storage:   nats.FileStorage,           // Use file storage for persistence
+		Duplicates: dedupWindow,               // Server-side dedup window for Nats-Msg-Id
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create RA_RECORDS stream: %w", err)
@@ -134,18 +396,33 @@ func initStreams(js nats.JetStreamContext) error {
 	
 	// Create RA_MEDIA stream for media-related events
 	// This stream handles all media upload and processing events
-	_, err = js.AddStream(&nats.StreamConfig{
+	err = addOrUpdateStream(js, &nats.StreamConfig{
 		Name:      "RA_MEDIA",                 // Stream name
 		Subjects:  []string{"cdv.media.*"},    // Subjects pattern for media events
 		Retention: nats.LimitsPolicy,          // Retention policy
-		MaxAge:    24 * time.Hour,             // Keep events for 24 hours
+		MaxAge:    eventStreamMaxAge,          // Keep events for 24 hours
 		Discard:   nats.DiscardOld,            // Discard old messages when limits reached
 		Storage:   nats.FileStorage,           // Use file storage for persistence
+		Duplicates: dedupWindow,               // Server-side dedup window for Nats-Msg-Id
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create RA_MEDIA stream: %w", err)
 	}
 	
+	// Create RA_DLQ stream for events that could not be published after retries
+	err = addOrUpdateStream(js, &nats.StreamConfig{
+		Name:      "RA_DLQ",                   // Stream name
+		Subjects:  []string{dlqSubjectPrefix + ">"}, // Original subject under the DLQ prefix
+		Retention: nats.LimitsPolicy,          // Retention policy
+		MaxAge:    7 * 24 * time.Hour,         // Keep dead letters long enough to investigate
+		Discard:   nats.DiscardOld,            // Discard old messages when limits reached
+		Storage:   nats.FileStorage,           // Use file storage for persistence
+		Duplicates: dedupWindow,               // Server-side dedup window for Nats-Msg-Id
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create RA_DLQ stream: %w", err)
+	}
+	
 	return nil
 }
 
@@ -159,46 +436,211 @@ type EventEnvelope struct {
 	Payload      interface{} `json:"payload"`      // Event-specific data
 }
 
+// PingNATS checks that the NATS server at url is reachable and has JetStream enabled
+func PingNATS(url string, timeout time.Duration) error {
+	nc, err := nats.Connect(url, nats.Timeout(timeout))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	if _, err := js.AccountInfo(nats.MaxWait(timeout)); err != nil {
+		return fmt.Errorf("JetStream unavailable: %w", err)
+	}
+	return nil
+}
+
 // Close closes the NATS connection.
-// It gracefully closes the connection to the NATS server.
+// It waits (bounded by closeDrainTimeout) for outstanding acknowledgments and retries first,
+// so events accepted just before shutdown are not lost.
 func (p *natsPub) Close() error {
+	if p.closing != nil {
+		close(p.closing)
+	}
+	deadline := time.After(closeDrainTimeout)
+drain:
+	for p.js != nil {
+		select {
+		case <-p.js.PublishAsyncComplete():
+		case <-deadline:
+			slog.Warn("timed out waiting for event acknowledgments", "pending", p.js.PublishAsyncPending(), "retrying", p.retrying.Load())
+			break drain
+		}
+		if p.retrying.Load() == 0 && p.js.PublishAsyncPending() == 0 {
+			break
+		}
+		
+		// A failed publish is being retried or dead-lettered; wait for it to be republished
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			slog.Warn("timed out waiting for event retries", "retrying", p.retrying.Load())
+			break drain
+		}
+	}
+	if p.done != nil {
+		close(p.done)
+	}
 	if p.nc != nil {
 		p.nc.Close()
 	}
 	return nil
 }
 
-// shouldDedup checks if an event should be deduplicated based on the 5-minute window.
-// It takes a correlation ID and the dedup map, and returns true
-// if the event should be deduplicated (i.e., it was published within the last 5 minutes).
-func (p *natsPub) shouldDedup(correlationID string, dedupMap map[string]time.Time) bool {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-	
-	if lastTime, exists := dedupMap[correlationID]; exists {
-		// Check if the last event was within the 5-minute dedup window
-		return time.Since(lastTime) < 5*time.Minute
+// publishAsync queues an event for publishing without waiting for the acknowledgment,
+// which trackAcks records in the background.
+func (p *natsPub) publishAsync(subject string, data []byte, eventType, msgID string) (nats.PubAckFuture, error) {
+	future, err := p.publishUntracked(subject, data, eventType, msgID)
+	if err != nil {
+		return nil, err
 	}
 	
-	return false
+	// Never block the caller on tracking; the ack still happens, only the metric is skipped
+	select {
+	case p.acks <- pendingAck{future: future, eventType: eventType}:
+	default:
+	}
+	return future, nil
 }
 
-// updateDedup updates the deduplication map with the current time for a given correlation ID.
-// This should be called after successfully publishing an event.
-func (p *natsPub) updateDedup(correlationID string, dedupMap map[string]time.Time) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// publishUntracked queues an event for publishing and leaves its acknowledgment to the
+// caller. A future resolves only once, so it must not also be handed to trackAcks.
+func (p *natsPub) publishUntracked(subject string, data []byte, eventType, msgID string) (nats.PubAckFuture, error) {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	msg.Header.Set(headerEventType, eventType)
+	msg.Header.Set(headerAttempt, "1")
 	
-	// Clean up old entries to prevent memory leaks
-	cutoff := time.Now().Add(-10 * time.Minute) // Keep entries for 10 minutes
-	for k, t := range dedupMap {
-		if t.Before(cutoff) {
-			delete(dedupMap, k)
+	future, err := p.js.PublishMsgAsync(msg, nats.MsgId(msgID))
+	if err != nil {
+		p.metrics.EventPublishTotal.WithLabelValues(eventType, "error").Inc()
+		return nil, err
+	}
+	return future, nil
+}
+
+// trackAcks records acknowledged publishes until Close is called.
+// Failures are handled by handlePublishError, which JetStream invokes directly.
+func (p *natsPub) trackAcks() {
+	for {
+		select {
+		case pending := <-p.acks:
+			select {
+			case <-pending.future.Ok():
+				p.metrics.EventPublishTotal.WithLabelValues(pending.eventType, "ack").Inc()
+			case <-pending.future.Err():
+			case <-p.done:
+				return
+			}
+		case <-p.done:
+			return
 		}
 	}
+}
+
+// handlePublishError is the JetStream async error handler. It retries a failed publish
+// up to maxPublishAttempts with exponential backoff (keeping its Nats-Msg-Id, so a late ack
+// cannot cause a duplicate) and then republishes the event to the dead-letter stream.
+func (p *natsPub) handlePublishError(js nats.JetStream, msg *nats.Msg, err error) {
+	eventType := msg.Header.Get(headerEventType)
+	
+	// A dead letter that cannot be stored is logged and dropped rather than retried forever
+	if strings.HasPrefix(msg.Subject, dlqSubjectPrefix) {
+		p.metrics.EventPublishTotal.WithLabelValues(eventType, "dropped").Inc()
+		slog.Error("failed to dead-letter event, dropping it", "subject", msg.Header.Get(headerOriginalSubject), "error", err)
+		return
+	}
 	
-	// Update the current correlation ID with the current time
-	dedupMap[correlationID] = time.Now()
+	attempt, _ := strconv.Atoi(msg.Header.Get(headerAttempt))
+	if attempt < maxPublishAttempts {
+		// The handler runs on the connection's reply goroutine, so the retry waits elsewhere;
+		// it is counted before returning so Close sees it once the failed publish completes
+		delay := retryBackoff << max(attempt-1, 0)
+		msg.Header.Set(headerAttempt, strconv.Itoa(attempt+1))
+		p.retrying.Add(1)
+		p.metrics.EventPublishTotal.WithLabelValues(eventType, "retry").Inc()
+		slog.Warn("retrying event publish", "subject", msg.Subject, "attempt", attempt+1, "delay", delay, "error", err)
+		go p.retry(js, msg, delay)
+		return
+	}
+	p.deadLetter(js, msg, attempt, err)
+}
+
+// retry republishes a failed event after delay, or at once when the publisher is closing,
+// and dead-letters it if it cannot be republished
+func (p *natsPub) retry(js nats.JetStream, msg *nats.Msg, delay time.Duration) {
+	defer p.retrying.Add(-1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-p.closing:
+	}
+	if _, err := js.PublishMsgAsync(msg); err != nil {
+		attempt, _ := strconv.Atoi(msg.Header.Get(headerAttempt))
+		p.deadLetter(js, msg, attempt, err)
+	}
+}
+
+// deadLetter republishes an event that failed after the given number of attempts to the
+// dead-letter stream, dropping it if that fails too
+func (p *natsPub) deadLetter(js nats.JetStream, msg *nats.Msg, attempt int, err error) {
+	eventType := msg.Header.Get(headerEventType)
+	dlq := nats.NewMsg(dlqSubjectPrefix + msg.Subject)
+	dlq.Data = msg.Data
+	for key, values := range msg.Header {
+		dlq.Header[key] = values
+	}
+	dlq.Header.Set(headerOriginalSubject, msg.Subject)
+	dlq.Header.Set(headerError, err.Error())
+	
+	p.metrics.EventPublishTotal.WithLabelValues(eventType, "dead_letter").Inc()
+	slog.Error("event publish failed, dead-lettering", "subject", msg.Subject, "attempts", attempt, "error", err)
+	if _, dlqErr := js.PublishMsgAsync(dlq); dlqErr != nil {
+		p.metrics.EventPublishTotal.WithLabelValues(eventType, "dropped").Inc()
+		slog.Error("failed to dead-letter event, dropping it", "subject", msg.Subject, "error", dlqErr)
+	}
+}
+
+// eventID derives a stable message ID from the given parts.
+// The same logical event always produces the same ID, which JetStream uses
+// as the Nats-Msg-Id to drop duplicate publishes within the dedup window.
+func eventID(kind string, parts ...string) string {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	for _, part := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordEventID returns the dedup ID for a record created event (URI + CID).
+func recordEventID(record model.Record) string {
+	return eventID("record.created", record.URI, record.CID)
+}
+
+// recordUpdatedEventID returns the dedup ID for a record updated event (URI + new CID).
+func recordUpdatedEventID(record model.Record) string {
+	return eventID("record.updated", record.URI, record.CID)
+}
+
+// recordDeletedEventID returns the dedup ID for a record deleted event (URI + CID).
+func recordDeletedEventID(record model.Record) string {
+	return eventID("record.deleted", record.URI, record.CID)
+}
+
+// mediaEventID returns the dedup ID for a media finalized event (asset ID + checksum).
+func mediaEventID(asset model.MediaAsset) string {
+	return eventID("media.finalized", asset.AssetID, asset.Checksum)
+}
+
+// mediaUploadedEventID returns the dedup ID for a media upload initiated event (asset ID).
+func mediaUploadedEventID(asset model.MediaAsset) string {
+	return eventID("media.uploaded", asset.AssetID)
 }
 
 // PublishRecordCreated publishes a record created event.
@@ -210,6 +652,73 @@ func (p *natsPub) updateDedup(correlationID string, dedupMap map[string]time.Tim
 // Returns:
 //   - error: Any error that occurred during publishing
 func (p *natsPub) PublishRecordCreated(ctx context.Context, collection string, record model.Record) error {
+	subject, b, err := recordMessage(ctx, collection, "created", record)
+	if err != nil {
+		return err
+	}
+	
+	// Queue the event without waiting for the ack; JetStream drops duplicates with the same message ID
+	_, err = p.publishAsync(subject, b, "record.created", recordEventID(record))
+	return err
+}
+
+// PublishRecordUpdated publishes a record updated event to cdv.records.<collection>.updated.
+func (p *natsPub) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	subject, b, err := recordMessage(ctx, record.Collection, "updated", record)
+	if err != nil {
+		return err
+	}
+	_, err = p.publishAsync(subject, b, "record.updated", recordUpdatedEventID(record))
+	return err
+}
+
+// PublishRecordDeleted publishes a record deleted event to cdv.records.<collection>.deleted.
+// The payload identifies the removed record; its value is not included.
+func (p *natsPub) PublishRecordDeleted(ctx context.Context, record model.Record) error {
+	subject, b, err := recordMessage(ctx, record.Collection, "deleted", record)
+	if err != nil {
+		return err
+	}
+	_, err = p.publishAsync(subject, b, "record.deleted", recordDeletedEventID(record))
+	return err
+}
+
+// PublishRecordsCreated publishes record created events for a batch of records.
+// Messages are published asynchronously and only this batch's acknowledgments are
+// awaited, so a batch costs one wait instead of a round trip per record. Each message
+// keeps its per-record Nats-Msg-Id, so retrying a partially acknowledged batch does not
+// duplicate events. Rejected messages are still retried by handlePublishError.
+func (p *natsPub) PublishRecordsCreated(ctx context.Context, records []model.Record) error {
+	futures := make([]nats.PubAckFuture, 0, len(records))
+	for _, record := range records {
+		subject, b, err := recordMessage(ctx, record.Collection, "created", record)
+		if err != nil {
+			return err
+		}
+		future, err := p.publishUntracked(subject, b, "record.created", recordEventID(record))
+		if err != nil {
+			return fmt.Errorf("failed to publish record %s: %w", record.URI, err)
+		}
+		futures = append(futures, future)
+	}
+	
+	var errs []error
+	for i, future := range futures {
+		select {
+		case <-future.Ok():
+			p.metrics.EventPublishTotal.WithLabelValues("record.created", "ack").Inc()
+		case err := <-future.Err():
+			errs = append(errs, fmt.Errorf("failed to publish record %s: %w", records[i].URI, err))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordMessage builds the subject and encoded envelope for a record event; action is
+// the last subject token (created, updated or deleted)
+func recordMessage(ctx context.Context, collection, action string, record model.Record) (string, []byte, error) {
 	// Extract correlation ID from context if available
 	correlationID := ""
 	if ctx.Value(ContextKeyCorrelationID) != nil {
@@ -223,14 +732,8 @@ func (p *natsPub) PublishRecordCreated(ctx context.Context, collection string, r
 		correlationID = uuid.New().String()
 	}
 	
-	// Check if this event should be deduplicated based on correlation ID
-	if p.shouldDedup(correlationID, p.recordDedup) {
-		// Event was published recently, skip it
-		return nil
-	}
-	
 	// Create the subject name based on the collection
-	subject := fmt.Sprintf("cdv.records.%s.created", collection)
+	subject := fmt.Sprintf("cdv.records.%s.%s", collection, action)
 	
 	// Create the event envelope with metadata
 	// Create a specific payload with the required fields including schema version
@@ -238,11 +741,12 @@ func (p *natsPub) PublishRecordCreated(ctx context.Context, collection string, r
 		"uri":          record.URI,
 		"cid":          record.CID,
 		"schema_version": record.SchemaVersion,
+		"visibility":   record.Visibility,
 		"correlationId": correlationID,
 	}
 
 	envelope := EventEnvelope{
-		Type:         fmt.Sprintf("cdv.records.%s.created", collection), // Event type
+		Type:         subject,                                           // Event type
 		Version:      "1.0.0",                                           // Event schema version
 		OccurredAt:   time.Now().UTC(),                                  // Event timestamp
 		CorrelationID: correlationID,                                    // Use request correlation ID
@@ -252,19 +756,29 @@ func (p *natsPub) PublishRecordCreated(ctx context.Context, collection string, r
 	// Marshal the envelope to JSON
 	b, err := json.Marshal(envelope)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 	
-	// Publish the event to the stream
-	_, err = p.js.Publish(subject, b)
+	return subject, b, nil
+}
+
+// PublishMediaUploadInitiated publishes a media upload initiated event, so consumers such as
+// scanners or quota trackers can react before the asset is finalized.
+// It wraps the media asset in an event envelope and publishes it to the RA_MEDIA stream.
+// Parameters:
+//   - ctx: Context for the operation
+//   - asset: The media asset an upload was initiated for
+// Returns:
+//   - error: Any error that occurred during publishing
+func (p *natsPub) PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error {
+	subject, b, err := mediaMessage(ctx, "uploaded", asset)
 	if err != nil {
 		return err
 	}
 	
-	// Update deduplication map on successful publish using correlation ID
-	p.updateDedup(correlationID, p.recordDedup)
-	
-	return nil
+	// Queue the event without waiting for the ack; JetStream drops duplicates with the same message ID
+	_, err = p.publishAsync(subject, b, "media.uploaded", mediaUploadedEventID(asset))
+	return err
 }
 
 // PublishMediaFinalized publishes a media finalized event.
@@ -275,6 +789,18 @@ func (p *natsPub) PublishRecordCreated(ctx context.Context, collection string, r
 // Returns:
 //   - error: Any error that occurred during publishing
 func (p *natsPub) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error {
+	subject, b, err := mediaMessage(ctx, "finalized", asset)
+	if err != nil {
+		return err
+	}
+	
+	// Queue the event without waiting for the ack; JetStream drops duplicates with the same message ID
+	_, err = p.publishAsync(subject, b, "media.finalized", mediaEventID(asset))
+	return err
+}
+
+// mediaMessage builds the subject and JSON envelope for a media event
+func mediaMessage(ctx context.Context, action string, asset model.MediaAsset) (string, []byte, error) {
 	// Extract correlation ID from context if available
 	correlationID := ""
 	if ctx.Value(ContextKeyCorrelationID) != nil {
@@ -288,17 +814,12 @@ func (p *natsPub) PublishMediaFinalized(ctx context.Context, asset model.MediaAs
 		correlationID = uuid.New().String()
 	}
 	
-	// Check if this event should be deduplicated based on correlation ID
-	if p.shouldDedup(correlationID, p.mediaDedup) {
-		// Event was published recently, skip it
-		return nil
-	}
-	
-	// Subject for media finalized events
-	subject := "cdv.media.finalized"
+	// Subject for media events
+	subject := "cdv.media." + action
 	
 	// Create the event envelope with metadata
-	// Create a specific payload with only the required fields
+	// Create a specific payload with only the required fields; uri and checksum are
+	// empty until the asset is finalized
 	payload := map[string]interface{}{
 		"assetId":      asset.AssetID,
 		"uri":          asset.URI,
@@ -309,7 +830,7 @@ func (p *natsPub) PublishMediaFinalized(ctx context.Context, asset model.MediaAs
 	}
 
 	envelope := EventEnvelope{
-		Type:         "cdv.media.finalized",      // Event type
+		Type:         subject,                   // Event type
 		Version:      "1.0.0",                   // Event schema version
 		OccurredAt:   time.Now().UTC(),          // Event timestamp
 		CorrelationID: correlationID,            // Use request correlation ID
@@ -319,17 +840,8 @@ func (p *natsPub) PublishMediaFinalized(ctx context.Context, asset model.MediaAs
 	// Marshal the envelope to JSON
 	b, err := json.Marshal(envelope)
 	if err != nil {
-		return err
-	}
-	
-	// Publish the event to the stream
-	_, err = p.js.Publish(subject, b)
-	if err != nil {
-		return err
+		return "", nil, err
 	}
 	
-	// Update deduplication map on successful publish using correlation ID
-	p.updateDedup(correlationID, p.mediaDedup)
-	
-	return nil
+	return subject, b, nil
 }
@@ -0,0 +1,356 @@
+// internal/event/nats_test.go
+// Package event provides tests for publisher settings, tests against an embedded JetStream
+// server, and benchmarks for event publishing against a live NATS server.
+package event
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runJetStream starts an embedded JetStream-enabled NATS server for the test and returns its URL
+func runJetStream(t *testing.T) string {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("embedded NATS server did not start")
+	}
+	t.Cleanup(s.Shutdown)
+	return s.ClientURL()
+}
+
+// BenchmarkPublishRecordCreated compares the per-event latency seen by the caller when
+// waiting for each JetStream ack versus queuing asynchronously. It needs a JetStream-enabled
+// server: CDV_NATS_URL=nats://localhost:4222 go test -bench PublishRecordCreated ./internal/event
+func BenchmarkPublishRecordCreated(b *testing.B) {
+	if os.Getenv("CDV_NATS_URL") == "" {
+		b.Skip("CDV_NATS_URL not set")
+	}
+	pub, ok := NewPublisher(os.Getenv("CDV_NATS_URL")).(*natsPub)
+	if !ok {
+		b.Skip("NATS publisher unavailable")
+	}
+	defer pub.Close()
+	ctx := context.Background()
+
+	record := func(run string, i int) model.Record {
+		return model.Record{URI: fmt.Sprintf("at://did:example:bench/post/%s-%d", run, i), CID: "bench"}
+	}
+
+	b.Run("sync", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r := record(b.Name(), i)
+			subject, data, err := recordMessage(ctx, "post", "created", r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := pub.js.Publish(subject, data, nats.MsgId(recordEventID(r))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("async", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := pub.PublishRecordCreated(ctx, "post", record(b.Name(), i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		<-pub.js.PublishAsyncComplete()
+	})
+}
+
+// TestNewPublisherStatus tests that the publisher reports whether it fell back to
+// noop because a configured NATS server was unreachable.
+func TestNewPublisherStatus(t *testing.T) {
+	if kind, err := NewPublisher("").(StatusReporter).Status(); kind != PublisherTypeNoop || err != nil {
+		t.Errorf("not configured: got %q, %v want noop without error", kind, err)
+	}
+	
+	pub := NewQueuedPublisher(NewPublisher("nats://127.0.0.1:1"), 1, 1, time.Second)
+	defer pub.Close()
+	if kind, err := pub.(StatusReporter).Status(); kind != PublisherTypeNoop || err == nil {
+		t.Errorf("unreachable: got %q, %v want noop with fallback error", kind, err)
+	}
+}
+
+// TestEventIDIgnoresCorrelationID tests that a retried request, which carries a fresh
+// correlation ID, produces the same message ID so JetStream drops the duplicate.
+func TestEventIDIgnoresCorrelationID(t *testing.T) {
+	record := model.Record{URI: "at://did:example:123/com.registryaccord.feed.post/a", CID: "cid1"}
+	first := context.WithValue(context.Background(), ContextKeyCorrelationID, "request-1")
+	retry := context.WithValue(context.Background(), ContextKeyCorrelationID, "request-2")
+	
+	_, a, _ := recordMessage(first, "com.registryaccord.feed.post", "created", record)
+	_, b, _ := recordMessage(retry, "com.registryaccord.feed.post", "created", record)
+	if string(a) == string(b) {
+		t.Fatal("expected envelopes to differ by correlation ID")
+	}
+	if recordEventID(record) != eventID("record.created", record.URI, record.CID) {
+		t.Error("record created ID is not derived from the URI and CID")
+	}
+	
+	updated := record
+	updated.CID = "cid2"
+	if recordEventID(record) == recordUpdatedEventID(record) || recordUpdatedEventID(record) == recordUpdatedEventID(updated) {
+		t.Error("distinct events share a message ID")
+	}
+}
+
+// TestPublishRecordEventsStored tests that created, updated and deleted events for a dotted
+// collection are stored in RA_RECORDS rather than failing and being dead-lettered.
+func TestPublishRecordEventsStored(t *testing.T) {
+	pub, ok := NewPublisher(runJetStream(t)).(*natsPub)
+	if !ok {
+		t.Fatal("expected a NATS publisher")
+	}
+	defer pub.Close()
+	
+	ctx := context.Background()
+	record := model.Record{URI: "at://did:example:123/com.registryaccord.feed.post/a", CID: "cid1", Collection: "com.registryaccord.feed.post"}
+	if err := pub.PublishRecordCreated(ctx, record.Collection, record); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.PublishRecordUpdated(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.PublishRecordDeleted(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-pub.js.PublishAsyncComplete():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for acknowledgments")
+	}
+	
+	for stream, want := range map[string]uint64{"RA_RECORDS": 3, "RA_DLQ": 0} {
+		info, err := pub.js.StreamInfo(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.State.Msgs != want {
+			t.Errorf("%s: got %d messages want %d", stream, info.State.Msgs, want)
+		}
+	}
+}
+
+// TestPublishRetryBackoff tests that a publish no stream accepts is retried with backoff
+// before it is dead-lettered, and that Close flushes retries still waiting out their backoff.
+func TestPublishRetryBackoff(t *testing.T) {
+	url := runJetStream(t)
+	pub, ok := NewPublisher(url).(*natsPub)
+	if !ok {
+		t.Fatal("expected a NATS publisher")
+	}
+	
+	dlqMsgs := func(js nats.JetStreamContext) uint64 {
+		info, err := js.StreamInfo("RA_DLQ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.State.Msgs
+	}
+	
+	start := time.Now()
+	if _, err := pub.publishAsync("cdv.unrouted", []byte("{}"), "record.created", "retry-1"); err != nil {
+		t.Fatal(err)
+	}
+	for dlqMsgs(pub.js) == 0 {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timed out waiting for the event to be dead-lettered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed, backoff := time.Since(start), retryBackoff+2*retryBackoff; elapsed < backoff {
+		t.Errorf("dead-lettered after %v, want at least the %v retry backoff", elapsed, backoff)
+	}
+	
+	// Closing during the backoff still dead-letters the event before the connection closes
+	if _, err := pub.publishAsync("cdv.unrouted", []byte("{}"), "record.created", "retry-2"); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if err := pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= retryBackoff+2*retryBackoff {
+		t.Errorf("Close took %v, want retries published without waiting out their backoff", elapsed)
+	}
+	nc, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dlqMsgs(js); got != 2 {
+		t.Errorf("RA_DLQ: got %d messages want 2", got)
+	}
+}
+
+// TestSubjectMatches tests NATS subject wildcard matching.
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"cdv.records.>", "cdv.records.com.registryaccord.feed.post.created", true},
+		{"cdv.records.*", "cdv.records.com.registryaccord.feed.post.created", false},
+		{"cdv.records.*", "cdv.records.post", true},
+		{"cdv.media.*", "cdv.media.finalized", true},
+		{"cdv.media.>", "cdv.media", false},
+		{"cdv.*.finalized", "cdv.media.finalized", true},
+		{"cdv.media.finalized", "cdv.media.finalized", true},
+		{"cdv.media.finalized", "cdv.media.uploaded", false},
+		{"cdv.>.x", "cdv.a.x", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+// TestReadyChecksStreamSubjects tests that pre-provisioned streams fail readiness while
+// their subjects do not capture the published subjects, and pass once they do.
+func TestReadyChecksStreamSubjects(t *testing.T) {
+	pub, ok := NewPublisher(runJetStream(t), WithAutoCreateStreams(false)).(*natsPub)
+	if !ok {
+		t.Fatal("expected a NATS publisher")
+	}
+	defer pub.Close()
+	
+	records := &nats.StreamConfig{Name: "RA_RECORDS", Subjects: []string{"cdv.records.*"}, Storage: nats.MemoryStorage}
+	for _, cfg := range []*nats.StreamConfig{
+		records,
+		{Name: "RA_MEDIA", Subjects: []string{"cdv.media.*"}, Storage: nats.MemoryStorage},
+		{Name: "RA_DLQ", Subjects: []string{dlqSubjectPrefix + ">"}, Storage: nats.MemoryStorage},
+	} {
+		if _, err := pub.js.AddStream(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pub.Ready(context.Background()); err == nil || !strings.Contains(err.Error(), "RA_RECORDS") {
+		t.Errorf("single-token record subjects: got %v want an error naming RA_RECORDS", err)
+	}
+	
+	records.Subjects = []string{"cdv.records.>"}
+	if _, err := pub.js.UpdateStream(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Ready(context.Background()); err != nil {
+		t.Errorf("matching subjects: got %v want ready", err)
+	}
+}
+
+// TestPublishDedup tests that publishing the same record or asset twice from requests
+// with different correlation IDs stores one message per stream.
+func TestPublishDedup(t *testing.T) {
+	pub, ok := NewPublisher(runJetStream(t)).(*natsPub)
+	if !ok {
+		t.Fatal("expected a NATS publisher")
+	}
+	defer pub.Close()
+	
+	record := model.Record{URI: "at://did:example:123/com.registryaccord.feed.post/a", CID: "cid1", Collection: "com.registryaccord.feed.post"}
+	asset := model.MediaAsset{AssetID: "asset-1", Checksum: "sha256:test"}
+	for _, cid := range []string{"request-1", "request-2"} {
+		ctx := context.WithValue(context.Background(), ContextKeyCorrelationID, cid)
+		if err := pub.PublishRecordCreated(ctx, record.Collection, record); err != nil {
+			t.Fatal(err)
+		}
+		if err := pub.PublishMediaFinalized(ctx, asset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-pub.js.PublishAsyncComplete():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for acknowledgments")
+	}
+	
+	for _, stream := range []string{"RA_RECORDS", "RA_MEDIA"} {
+		info, err := pub.js.StreamInfo(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.State.Msgs != 1 {
+			t.Errorf("%s: got %d stored messages want 1", stream, info.State.Msgs)
+		}
+	}
+}
+
+// TestPublishRecordsCreated tests that a batch returns once its own messages are
+// acknowledged and that republishing it stores no duplicates.
+func TestPublishRecordsCreated(t *testing.T) {
+	pub, ok := NewPublisher(runJetStream(t)).(*natsPub)
+	if !ok {
+		t.Fatal("expected a NATS publisher")
+	}
+	defer pub.Close()
+	
+	records := []model.Record{
+		{URI: "at://did:example:123/com.registryaccord.feed.post/a", CID: "cid1", Collection: "com.registryaccord.feed.post"},
+		{URI: "at://did:example:123/com.registryaccord.feed.post/b", CID: "cid2", Collection: "com.registryaccord.feed.post"},
+	}
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := pub.PublishRecordsCreated(ctx, records)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	info, err := pub.js.StreamInfo("RA_RECORDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != uint64(len(records)) {
+		t.Errorf("got %d stored messages want %d", info.State.Msgs, len(records))
+	}
+}
+
+// TestStreamConfigMatches tests which differences in an existing stream call for an update.
+func TestStreamConfigMatches(t *testing.T) {
+	want := nats.StreamConfig{
+		Name: "RA_MEDIA", Subjects: []string{"cdv.media.*"}, Retention: nats.LimitsPolicy,
+		MaxAge: eventStreamMaxAge, Discard: nats.DiscardOld, Storage: nats.FileStorage, Duplicates: defaultDedupWindow,
+	}
+	// The server fills in defaults for settings initStreams leaves unset
+	same := want
+	same.MaxMsgs = -1
+	if !streamConfigMatches(same, want) {
+		t.Error("server defaults: expected a match")
+	}
+	
+	for name, change := range map[string]func(*nats.StreamConfig){
+		"subjects":   func(c *nats.StreamConfig) { c.Subjects = []string{"cdv.media.>"} },
+		"storage":    func(c *nats.StreamConfig) { c.Storage = nats.MemoryStorage },
+		"max age":    func(c *nats.StreamConfig) { c.MaxAge = time.Hour },
+		"duplicates": func(c *nats.StreamConfig) { c.Duplicates = time.Minute },
+	} {
+		have := want
+		have.Subjects = slices.Clone(want.Subjects)
+		change(&have)
+		if streamConfigMatches(have, want) {
+			t.Errorf("%s: expected a mismatch", name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// internal/event/queue.go
+// Package event provides an in-process queue that decouples request handlers from the event backend.
+package event
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+)
+
+var (
+	// ErrQueueFull is returned when the publish queue is at capacity; the event is not published
+	ErrQueueFull = errors.New("event queue full")
+	// ErrPublisherClosed is returned for events submitted after Close
+	ErrPublisherClosed = errors.New("event publisher closed")
+)
+
+// queuedEvent is an event waiting to be handed to the underlying publisher
+type queuedEvent struct {
+	ctx       context.Context                      // Request context values, detached from cancellation
+	eventType string                               // Event kind, for metrics
+	publish   func(ctx context.Context) error      // Publishes the event with the underlying publisher
+}
+
+// queuedPublisher implements Publisher by enqueueing events on a bounded channel that a
+// pool of workers drains into the underlying publisher. Handlers never wait on the event
+// backend: when the queue is full the event is rejected immediately with ErrQueueFull.
+type queuedPublisher struct {
+	next         Publisher        // Underlying publisher
+	queue        chan queuedEvent // Bounded event queue
+	drainTimeout time.Duration    // How long Close waits for queued events to be published
+	metrics      *metrics.Metrics // Queue depth and publish metrics
+
+	mu     sync.RWMutex   // Guards closed against concurrent enqueues
+	closed bool           // Whether Close has been called
+	wg     sync.WaitGroup // Tracks running workers
+
+	stop   context.Context    // Cancelled when draining times out, stopping the workers
+	cancel context.CancelFunc // Cancels stop
+}
+
+// NewQueuedPublisher wraps next with a bounded queue of the given size drained by the
+// given number of workers. Close drains the queue for up to drainTimeout, then stops the
+// workers and closes next.
+func NewQueuedPublisher(next Publisher, size, workers int, drainTimeout time.Duration) Publisher {
+	if size < 1 {
+		size = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	q := &queuedPublisher{
+		next:         next,
+		queue:        make(chan queuedEvent, size),
+		drainTimeout: drainTimeout,
+		metrics:      metrics.NewMetrics(),
+	}
+	q.stop, q.cancel = context.WithCancel(context.Background())
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Ready implements ReadinessChecker by asking the underlying publisher
+func (q *queuedPublisher) Ready(ctx context.Context) error {
+	if rc, ok := q.next.(ReadinessChecker); ok {
+		return rc.Ready(ctx)
+	}
+	return nil
+}
+
+// Status implements StatusReporter by asking the underlying publisher
+func (q *queuedPublisher) Status() (string, error) {
+	if sr, ok := q.next.(StatusReporter); ok {
+		return sr.Status()
+	}
+	return "", nil
+}
+
+// PublishRecordCreated implements Publisher by enqueueing the event
+func (q *queuedPublisher) PublishRecordCreated(ctx context.Context, collection string, record model.Record) error {
+	return q.enqueue(ctx, "record.created", func(ctx context.Context) error {
+		return q.next.PublishRecordCreated(ctx, collection, record)
+	})
+}
+
+// PublishRecordsCreated implements Publisher by enqueueing the batch as a single event
+func (q *queuedPublisher) PublishRecordsCreated(ctx context.Context, records []model.Record) error {
+	return q.enqueue(ctx, "record.created", func(ctx context.Context) error {
+		return q.next.PublishRecordsCreated(ctx, records)
+	})
+}
+
+// PublishRecordUpdated implements Publisher by enqueueing the event
+func (q *queuedPublisher) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	return q.enqueue(ctx, "record.updated", func(ctx context.Context) error {
+		return q.next.PublishRecordUpdated(ctx, record)
+	})
+}
+
+// PublishRecordDeleted implements Publisher by enqueueing the event
+func (q *queuedPublisher) PublishRecordDeleted(ctx context.Context, record model.Record) error {
+	return q.enqueue(ctx, "record.deleted", func(ctx context.Context) error {
+		return q.next.PublishRecordDeleted(ctx, record)
+	})
+}
+
+// PublishMediaUploadInitiated implements Publisher by enqueueing the event
+func (q *queuedPublisher) PublishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) error {
+	return q.enqueue(ctx, "media.uploaded", func(ctx context.Context) error {
+		return q.next.PublishMediaUploadInitiated(ctx, asset)
+	})
+}
+
+// PublishMediaFinalized implements Publisher by enqueueing the event
+func (q *queuedPublisher) PublishMediaFinalized(ctx context.Context, asset model.MediaAsset) error {
+	return q.enqueue(ctx, "media.finalized", func(ctx context.Context) error {
+		return q.next.PublishMediaFinalized(ctx, asset)
+	})
+}
+
+// enqueue adds an event to the queue without blocking
+func (q *queuedPublisher) enqueue(ctx context.Context, eventType string, publish func(ctx context.Context) error) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrPublisherClosed
+	}
+
+	// The request context is cancelled once the response is written, but its values
+	// (correlation ID, trace) are still wanted when the event is published
+	ev := queuedEvent{ctx: context.WithoutCancel(ctx), eventType: eventType, publish: publish}
+	select {
+	case q.queue <- ev:
+		q.metrics.EventQueueDepth.Inc()
+		return nil
+	default:
+		q.metrics.EventPublishTotal.WithLabelValues(eventType, "queue_full").Inc()
+		return ErrQueueFull
+	}
+}
+
+// work publishes queued events until the queue is closed and empty. Publish outcomes are
+// counted in event_publish_total by the underlying publisher; the queue only counts the
+// events it rejects or drops.
+func (q *queuedPublisher) work() {
+	defer q.wg.Done()
+	for ev := range q.queue {
+		q.metrics.EventQueueDepth.Dec()
+		if q.stop.Err() != nil {
+			q.drop(ev)
+			continue
+		}
+
+		// Draining timed out: abandon the publish in progress
+		ctx, cancel := context.WithCancel(ev.ctx)
+		stopPublish := context.AfterFunc(q.stop, cancel)
+		start := time.Now()
+		err := ev.publish(ctx)
+		stopPublish()
+		cancel()
+		status := "success"
+		if err != nil {
+			status = "error"
+			slog.Warn("failed to publish queued event", "event_type", ev.eventType, "error", err)
+		}
+		q.metrics.EventPublishDuration.WithLabelValues(ev.eventType, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// drop counts an event abandoned because draining timed out
+func (q *queuedPublisher) drop(ev queuedEvent) {
+	q.metrics.EventPublishTotal.WithLabelValues(ev.eventType, "dropped").Inc()
+}
+
+// Close stops accepting events, waits up to the drain timeout for queued events to be
+// published, and closes the underlying publisher. On timeout the workers drop the remaining
+// events and cancel publishes in progress; the underlying publisher is only closed once
+// every worker has returned.
+func (q *queuedPublisher) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(q.drainTimeout):
+		slog.Warn("timed out draining event queue, dropping remaining events", "remaining", len(q.queue))
+		q.cancel()
+		<-drained
+	}
+	q.cancel()
+
+	return q.next.Close()
+}
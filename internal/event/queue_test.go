@@ -0,0 +1,153 @@
+// internal/event/queue_test.go
+// Package event provides tests for the internal event publish queue.
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// blockingPublisher records published records and blocks each publish until released
+type blockingPublisher struct {
+	noop
+	started chan struct{} // Signalled when a publish begins
+	release chan struct{} // Closed to let publishes complete
+	mu      sync.Mutex
+	records []model.Record
+}
+
+func (b *blockingPublisher) PublishRecordCreated(ctx context.Context, collection string, record model.Record) error {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, record)
+	return nil
+}
+
+// TestQueuedPublisher tests that enqueueing never blocks on the backend, that a full
+// queue rejects events, and that Close drains queued events.
+func TestQueuedPublisher(t *testing.T) {
+	next := &blockingPublisher{started: make(chan struct{}, 1), release: make(chan struct{})}
+	q := NewQueuedPublisher(next, 2, 1, time.Second)
+	ctx := context.Background()
+
+	// One event is held by the worker and two fill the queue; none of these block
+	for i := 0; i < 3; i++ {
+		if err := q.PublishRecordCreated(ctx, "post", model.Record{URI: "r"}); err != nil {
+			t.Fatalf("PublishRecordCreated() error = %v", err)
+		}
+		if i == 0 {
+			// Let the worker take the first event so the queue capacity is free
+			<-next.started
+		}
+	}
+	if err := q.PublishRecordCreated(ctx, "post", model.Record{URI: "r"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("PublishRecordCreated() on full queue error = %v, want %v", err, ErrQueueFull)
+	}
+
+	close(next.release)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(next.records) != 3 {
+		t.Errorf("published %d events after drain, want 3", len(next.records))
+	}
+	if err := q.PublishRecordCreated(ctx, "post", model.Record{URI: "r"}); !errors.Is(err, ErrPublisherClosed) {
+		t.Errorf("PublishRecordCreated() after Close error = %v, want %v", err, ErrPublisherClosed)
+	}
+}
+
+// failingPublisher fails every record publish, as the underlying publisher would after
+// counting the failure itself
+type failingPublisher struct{ noop }
+
+func (f *failingPublisher) PublishRecordUpdated(ctx context.Context, record model.Record) error {
+	return errors.New("publish failed")
+}
+
+// TestQueuedPublisherLeavesPublishOutcomesToNext tests that the queue does not count
+// publish outcomes in event_publish_total a second time.
+func TestQueuedPublisherLeavesPublishOutcomesToNext(t *testing.T) {
+	total := metrics.NewMetrics().EventPublishTotal
+	q := NewQueuedPublisher(&failingPublisher{}, 1, 1, time.Second)
+	if err := q.PublishRecordUpdated(context.Background(), model.Record{URI: "r"}); err != nil {
+		t.Fatalf("PublishRecordUpdated() error = %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for _, status := range []string{"success", "error"} {
+		if got := testutil.ToFloat64(total.WithLabelValues("record.updated", status)); got != 0 {
+			t.Errorf("event_publish_total{status=%q} = %v, want 0", status, got)
+		}
+	}
+}
+
+// stuckPublisher blocks record publishes until their context is cancelled and records
+// whether Close was called while a publish was still running
+type stuckPublisher struct {
+	noop
+	started chan struct{} // Signalled when a publish begins
+	mu      sync.Mutex
+	busy    bool
+	closedWhileBusy bool
+}
+
+func (s *stuckPublisher) PublishRecordCreated(ctx context.Context, collection string, record model.Record) error {
+	s.mu.Lock()
+	s.busy = true
+	s.mu.Unlock()
+	s.started <- struct{}{}
+	<-ctx.Done()
+	s.mu.Lock()
+	s.busy = false
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+func (s *stuckPublisher) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedWhileBusy = s.busy
+	return nil
+}
+
+// TestQueuedPublisherCloseTimeout tests that when draining times out, Close cancels the
+// publish in progress, drops the queued events and only then closes the underlying publisher.
+func TestQueuedPublisherCloseTimeout(t *testing.T) {
+	dropped := metrics.NewMetrics().EventPublishTotal.WithLabelValues("record.created", "dropped")
+	before := testutil.ToFloat64(dropped)
+	
+	next := &stuckPublisher{started: make(chan struct{}, 1)}
+	q := NewQueuedPublisher(next, 2, 1, 50*time.Millisecond)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := q.PublishRecordCreated(ctx, "post", model.Record{URI: "r"}); err != nil {
+			t.Fatalf("PublishRecordCreated() error = %v", err)
+		}
+		if i == 0 {
+			<-next.started
+		}
+	}
+	
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if next.closedWhileBusy {
+		t.Error("underlying publisher was closed while a publish was in progress")
+	}
+	if got := testutil.ToFloat64(dropped) - before; got != 2 {
+		t.Errorf("dropped %v events, want 2", got)
+	}
+}
@@ -92,3 +92,12 @@ func (c *Client) Get(ctx context.Context, did string) (Record, error) {
 		return Record{}, fmt.Errorf("identity get failed: %s", resp.Status)
 	}
 }
+
+// Ping checks that the identity service is reachable by resolving a DID that does not
+// exist; a not-found answer means the service responded.
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := c.Get(ctx, "did:example:health-check"); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
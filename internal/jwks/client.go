@@ -5,12 +5,17 @@
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
 )
 
 // JWKS represents a JSON Web Key Set
@@ -27,29 +32,194 @@ type JWK struct {
 	Crv string `json:"crv"` // Curve
 	X   string `json:"x"`   // X coordinate
 }
+
+// Errors returned by ValidateJWT, wrapped with context; match them with errors.Is
+var (
+	ErrExpired          = errors.New("token expired")                        // exp claim missing or in the past
+	ErrNotYetValid      = errors.New("token not yet valid")                  // nbf claim in the future
+	ErrInvalidIssuer    = errors.New("invalid issuer")                       // iss claim does not match
+	ErrInvalidAudience  = errors.New("invalid audience")                     // aud claim does not match
+	ErrMissingKid       = errors.New("missing or invalid kid in JWT header") // No usable kid header
+	ErrKidNotFound      = errors.New("key not found for kid")                // kid is not in the JWKS
+	ErrKeyFetch         = errors.New("failed to fetch signing keys")         // JWKS could not be retrieved
+	ErrInvalidSignature = errors.New("invalid JWT signature")                // Signature verification failed
+	ErrMalformed        = errors.New("malformed JWT")                        // Token cannot be parsed
+	ErrInvalidClaims    = errors.New("invalid JWT claims")                   // Claims are not a JSON object
+	ErrUnsupportedAlg   = errors.New("unsupported key type or algorithm")    // Only EdDSA/Ed25519 is accepted
+	ErrInvalidType      = errors.New("unsupported JWT typ")                  // typ header is not an allowed type
+)
+
+// Default cache and circuit breaker settings
+const (
+	DefaultCacheTTL         = 5 * time.Minute  // How long fetched keys are considered fresh
+	DefaultRefreshInterval  = 5 * time.Minute  // How often keys are refetched in the background
+	DefaultLeeway           = 60 * time.Second // Clock skew tolerated when checking exp and nbf
+	DefaultBreakerThreshold = 3                // Consecutive fetch failures before the breaker opens
+	DefaultBreakerCooldown  = 30 * time.Second // How long the breaker stays open before retrying
+)
+
+// DefaultAllowedTypes are the accepted typ header values; the empty entry accepts tokens without typ
+var DefaultAllowedTypes = []string{"JWT", ""}
+
 // Client handles JWKS discovery and caching
 type Client struct {
 	jwksURL    string
 	httpClient *http.Client
 	cache      *jwksCache
+	cacheTTL   time.Duration
+	metrics    *metrics.Metrics
+	fetches    singleflight.Group // Shares one cold-cache fetch among concurrent callers
+
+	// Background refresh, stopped by Close
+	refreshInterval time.Duration      // How often keys are refetched
+	stopRefresh     context.CancelFunc // Stops the refresh loop (nil if none was started)
+	refreshDone     chan struct{}      // Closed when the refresh loop has exited
+
+	// Circuit breaker settings for fetchJWKS
+	breakerThreshold int           // Consecutive failures that open the breaker
+	breakerCooldown  time.Duration // How long fetches are suppressed once open
+	allowedTypes     []string      // Accepted typ header values (nil means DefaultAllowedTypes)
+	leeway           time.Duration // Clock skew tolerated when checking exp and nbf
 	testMode   bool
 	testKey    ed25519.PrivateKey
 }
 
-// jwksCache stores cached JWKS with expiration
+// jwksCache stores cached JWKS with expiration.
+// Expired keys are kept as last-known-good and served while a refresh runs.
 type jwksCache struct {
 	jwks       *JWKS
 	expiresAt  time.Time
+	refreshing bool // Whether a background refresh is in flight
 	mutex      sync.RWMutex
+
+	// Circuit breaker state, guarded by mutex
+	consecutiveFailures int       // Fetch failures since the last success
+	openUntil           time.Time // Fetches are suppressed until this time
 }
-// NewClient creates a new JWKS client
-func NewClient(jwksURL string) *Client {
-	return &Client{
+
+// ClientOption configures optional Client behavior
+type ClientOption func(*Client)
+
+// WithCacheTTL sets how long fetched keys are considered fresh
+func WithCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.cacheTTL = ttl
+		}
+	}
+}
+
+// WithRefreshInterval sets how often keys are refetched in the background
+func WithRefreshInterval(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		if interval > 0 {
+			c.refreshInterval = interval
+		}
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when checking the exp and nbf claims,
+// so clients whose clocks are slightly off are not rejected. 0 disables the tolerance.
+func WithLeeway(leeway time.Duration) ClientOption {
+	return func(c *Client) {
+		if leeway >= 0 {
+			c.leeway = leeway
+		}
+	}
+}
+
+// WithCircuitBreaker sets how many consecutive fetch failures open the breaker
+// and how long fetching stays suppressed before the next attempt
+func WithCircuitBreaker(threshold int, cooldown time.Duration) ClientOption {
+	return func(c *Client) {
+		if threshold > 0 {
+			c.breakerThreshold = threshold
+		}
+		if cooldown > 0 {
+			c.breakerCooldown = cooldown
+		}
+	}
+}
+
+// WithAllowedTypes sets the accepted typ header values, compared case-insensitively
+// with any "application/" prefix removed. Include "" to accept tokens without typ.
+func WithAllowedTypes(types ...string) ClientOption {
+	return func(c *Client) {
+		if len(types) > 0 {
+			c.allowedTypes = types
+		}
+	}
+}
+
+// NewClient creates a new JWKS client and starts refetching its keys in the background,
+// so requests are not blocked on a fetch when the cache expires. Call Close to stop it.
+func NewClient(jwksURL string, opts ...ClientOption) *Client {
+	c := &Client{
 		jwksURL: jwksURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		cache: &jwksCache{},
+		cache:    &jwksCache{},
+		cacheTTL: DefaultCacheTTL,
+		metrics:  metrics.NewMetrics(),
+		breakerThreshold: DefaultBreakerThreshold,
+		breakerCooldown:  DefaultBreakerCooldown,
+		refreshInterval:  DefaultRefreshInterval,
+		leeway:           DefaultLeeway,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopRefresh = cancel
+	c.refreshDone = make(chan struct{})
+	go c.refreshLoop(ctx)
+	return c
+}
+
+// Leeway returns the clock skew tolerated when checking exp and nbf; a token is accepted
+// until exp plus the leeway
+func (c *Client) Leeway() time.Duration {
+	return c.leeway
+}
+
+// Close stops the background refresh and waits for it to exit
+func (c *Client) Close() {
+	if c.stopRefresh == nil {
+		return
+	}
+	c.stopRefresh()
+	<-c.refreshDone
+}
+
+// refreshLoop refetches the keys every refreshInterval until ctx is canceled. A failed
+// fetch keeps the previous keys, and fetches are skipped while the breaker is open.
+func (c *Client) refreshLoop(ctx context.Context) {
+	defer close(c.refreshDone)
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.cache.mutex.Lock()
+		start := false
+		if !c.cache.refreshing {
+			if c.breakerOpen() {
+				c.shortCircuit()
+			} else {
+				c.cache.refreshing = true
+				start = true
+			}
+		}
+		c.cache.mutex.Unlock()
+		if start {
+			c.refresh(ctx)
+		}
 	}
 }
 
@@ -89,7 +259,22 @@ func (c *Client) fetchJWKS(ctx context.Context) (*JWKS, error) {
 	return &jwks, nil
 }
 
-// getJWKS retrieves JWKS from cache or fetches fresh if needed
+// Ping fetches the JWKS, bypassing the cache, to check that the endpoint serves keys
+func (c *Client) Ping(ctx context.Context) error {
+	jwks, err := c.fetchJWKS(ctx)
+	if err != nil {
+		return err
+	}
+	if len(jwks.Keys) == 0 {
+		return fmt.Errorf("JWKS contains no keys")
+	}
+	return nil
+}
+
+// getJWKS retrieves JWKS from cache or fetches fresh if needed.
+// Once keys have been fetched successfully, expired keys are served stale while
+// a background refresh runs, so a JWKS endpoint outage does not break validation.
+// It only fails if no fetch has ever succeeded. The cache lock is not held while fetching.
 func (c *Client) getJWKS(ctx context.Context) (*JWKS, error) {
 	c.cache.mutex.RLock()
 	if c.cache.jwks != nil && time.Now().Before(c.cache.expiresAt) {
@@ -99,31 +284,110 @@ func (c *Client) getJWKS(ctx context.Context) (*JWKS, error) {
 	}
 	c.cache.mutex.RUnlock()
 
-	// Need to fetch fresh JWKS
 	c.cache.mutex.Lock()
-	defer c.cache.mutex.Unlock()
-
 	// Double-check after acquiring write lock
 	if c.cache.jwks != nil && time.Now().Before(c.cache.expiresAt) {
-		return c.cache.jwks, nil
+		jwks := c.cache.jwks
+		c.cache.mutex.Unlock()
+		return jwks, nil
 	}
 
-	jwks, err := c.fetchJWKS(ctx)
+	// Serve last-known-good keys and revalidate in the background, unless the circuit
+	// breaker is suppressing fetches; the refresh loop counts the fetches it skips
+	if c.cache.jwks != nil {
+		if !c.cache.refreshing && !c.breakerOpen() {
+			c.cache.refreshing = true
+			go c.refresh(context.Background())
+		}
+		jwks := c.cache.jwks
+		c.cache.mutex.Unlock()
+		return jwks, nil
+	}
+
+	// Nothing cached yet: fail fast while the breaker is open, otherwise fetch synchronously
+	if c.breakerOpen() {
+		c.shortCircuit()
+		openUntil := c.cache.openUntil
+		c.cache.mutex.Unlock()
+		return nil, fmt.Errorf("JWKS fetch suppressed: circuit breaker open until %s", openUntil.Format(time.RFC3339))
+	}
+	c.cache.mutex.Unlock()
+
+	// Concurrent callers share one fetch, which outlives any one caller's cancellation
+	jwks, err, _ := c.fetches.Do("jwks", func() (interface{}, error) {
+		jwks, err := c.fetchJWKS(context.WithoutCancel(ctx))
+		c.cache.mutex.Lock()
+		defer c.cache.mutex.Unlock()
+		c.recordFetch(err)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.jwks = jwks
+		c.cache.expiresAt = time.Now().Add(c.cacheTTL)
+		return jwks, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return jwks.(*JWKS), nil
+}
+
+// refresh fetches the JWKS in the background and replaces the cached keys on success.
+// On failure the previous keys stay in place and are served until a fetch succeeds.
+func (c *Client) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.httpClient.Timeout)
+	defer cancel()
 
+	jwks, err := c.fetchJWKS(ctx)
+
+	c.cache.mutex.Lock()
+	defer c.cache.mutex.Unlock()
+	c.cache.refreshing = false
+	c.recordFetch(err)
+	if err != nil {
+		slog.Warn("JWKS refresh failed, keeping previous keys", "url", c.jwksURL, "error", err)
+		return
+	}
 	c.cache.jwks = jwks
-	c.cache.expiresAt = time.Now().Add(5 * time.Minute) // 5-minute cache
+	c.cache.expiresAt = time.Now().Add(c.cacheTTL)
+}
 
-	return jwks, nil
+// breakerOpen reports whether fetches are currently suppressed. The caller must hold
+// the cache lock.
+func (c *Client) breakerOpen() bool {
+	return time.Now().Before(c.cache.openUntil)
+}
+
+// shortCircuit counts a fetch skipped because the breaker is open
+func (c *Client) shortCircuit() {
+	c.metrics.JWKSFetchTotal.WithLabelValues("short_circuit").Inc()
+}
+
+// recordFetch updates the circuit breaker with the outcome of a fetch, opening it
+// after breakerThreshold consecutive failures. The caller must hold the cache write lock.
+func (c *Client) recordFetch(err error) {
+	if err == nil {
+		c.cache.consecutiveFailures = 0
+		c.cache.openUntil = time.Time{}
+		c.metrics.JWKSFetchTotal.WithLabelValues("success").Inc()
+		c.metrics.JWKSCircuitOpen.Set(0)
+		return
+	}
+
+	c.metrics.JWKSFetchTotal.WithLabelValues("failure").Inc()
+	c.cache.consecutiveFailures++
+	if c.cache.consecutiveFailures >= c.breakerThreshold {
+		c.cache.openUntil = time.Now().Add(c.breakerCooldown)
+		c.metrics.JWKSCircuitOpen.Set(1)
+		slog.Warn("JWKS circuit breaker open", "url", c.jwksURL, "failures", c.cache.consecutiveFailures, "cooldown", c.breakerCooldown)
+	}
 }
 
 // getKey retrieves a specific key from the JWKS by kid
 func (c *Client) getKey(ctx context.Context, kid string) (*JWK, error) {
 	jwks, err := c.getJWKS(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrKeyFetch, err)
 	}
 
 	for _, key := range jwks.Keys {
@@ -132,32 +396,93 @@ func (c *Client) getKey(ctx context.Context, kid string) (*JWK, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("key with kid %s not found", kid)
+	return nil, fmt.Errorf("%w: %s", ErrKidNotFound, kid)
+}
+
+// audienceMatches reports whether an aud claim, which may be a single string or an
+// array of strings, contains any of the expected audiences
+func audienceMatches(aud interface{}, expectedAudiences []string) bool {
+	var values []string
+	switch v := aud.(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, value := range values {
+		for _, expected := range expectedAudiences {
+			if value != "" && value == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeType canonicalizes a typ value per RFC 7515 section 4.1.9
+func normalizeType(typ string) string {
+	typ = strings.ToLower(typ)
+	return strings.TrimPrefix(typ, "application/")
 }
 
-// ValidateJWT validates a JWT using the JWKS
-func (c *Client) ValidateJWT(ctx context.Context, tokenString string, expectedIssuer, expectedAudience string) (jwt.MapClaims, error) {
+// typeAllowed reports whether the token's typ header is an accepted type,
+// rejecting e.g. DPoP proofs or refresh tokens presented as access tokens
+func (c *Client) typeAllowed(header map[string]interface{}) bool {
+	allowed := c.allowedTypes
+	if allowed == nil {
+		allowed = DefaultAllowedTypes
+	}
+	typ := ""
+	if v, ok := header["typ"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		typ = normalizeType(s)
+	}
+	for _, a := range allowed {
+		if normalizeType(a) == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateJWT validates a JWT using the JWKS. The token is accepted if its aud claim
+// contains any of the expected audiences.
+func (c *Client) ValidateJWT(ctx context.Context, tokenString string, expectedIssuer string, expectedAudiences ...string) (jwt.MapClaims, error) {
 	// If in test mode, use simplified validation
 	if c.testMode {
 		// Parse the token without verification to get the header
 		parsedToken, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse JWT: %w", err)
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+
+		if !c.typeAllowed(parsedToken.Header) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidType, parsedToken.Header["typ"])
 		}
 
 		claims, ok := parsedToken.Claims.(jwt.MapClaims)
 		if !ok {
-			return nil, fmt.Errorf("invalid JWT claims")
+			return nil, ErrInvalidClaims
 		}
 
 		// Verify issuer
 		if iss, ok := claims["iss"].(string); !ok || iss != expectedIssuer {
-			return nil, fmt.Errorf("invalid issuer")
+			return nil, ErrInvalidIssuer
 		}
 
 		// Verify audience
-		if aud, ok := claims["aud"].(string); !ok || aud != expectedAudience {
-			return nil, fmt.Errorf("invalid audience")
+		if !audienceMatches(claims["aud"], expectedAudiences) {
+			return nil, ErrInvalidAudience
 		}
 
 		// In test mode, skip expiration checking to avoid test token expiration issues
@@ -174,69 +499,94 @@ func (c *Client) ValidateJWT(ctx context.Context, tokenString string, expectedIs
 	// Parse the token without verification to get the header
 	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JWT: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	// Reject token types other than access tokens before fetching keys
+	if !c.typeAllowed(token.Header) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidType, token.Header["typ"])
 	}
 
 	// Get the key ID from the header
 	kid, ok := token.Header["kid"].(string)
 	if !ok || kid == "" {
-		return nil, fmt.Errorf("missing or invalid kid in JWT header")
+		return nil, ErrMissingKid
 	}
 
 	// Get the key from JWKS
 	jwk, err := c.getKey(ctx, kid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get key: %w", err)
+		return nil, err
 	}
 
 	// Verify key type and algorithm
 	if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" {
-		return nil, fmt.Errorf("unsupported key type or algorithm")
+		return nil, ErrUnsupportedAlg
 	}
 
 	// Decode the public key
 	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode public key: %w", err)
+		return nil, fmt.Errorf("%w: failed to decode public key: %v", ErrKeyFetch, err)
 	}
 
 	// Verify the token
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
 		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedAlg, token.Header["alg"])
 		}
 		return ed25519.PublicKey(xBytes), nil
 	}
 
 	// Parse and verify the token
-	parsedToken, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, keyFunc)
+	parsedToken, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, keyFunc, jwt.WithLeeway(c.leeway))
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify JWT: %w", err)
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, fmt.Errorf("%w: %v", ErrExpired, err)
+		case errors.Is(err, jwt.ErrTokenNotValidYet):
+			return nil, fmt.Errorf("%w: %v", ErrNotYetValid, err)
+		case errors.Is(err, ErrUnsupportedAlg):
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedAlg, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
 	}
 
 	if !parsedToken.Valid {
-		return nil, fmt.Errorf("invalid JWT")
+		return nil, ErrInvalidSignature
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, fmt.Errorf("invalid JWT claims")
+		return nil, ErrInvalidClaims
 	}
 
 	// Verify issuer
 	if iss, ok := claims["iss"].(string); !ok || iss != expectedIssuer {
-		return nil, fmt.Errorf("invalid issuer")
+		return nil, ErrInvalidIssuer
 	}
 
 	// Verify audience
-	if aud, ok := claims["aud"].(string); !ok || aud != expectedAudience {
-		return nil, fmt.Errorf("invalid audience")
+	if !audienceMatches(claims["aud"], expectedAudiences) {
+		return nil, ErrInvalidAudience
 	}
 
-	// Verify expiration
-	if exp, ok := claims["exp"].(float64); !ok || float64(time.Now().Unix()) > exp {
-		return nil, fmt.Errorf("token expired")
+	// Verify expiration and not-before, tolerating clock skew up to the leeway
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-c.leeway).After(time.Unix(int64(exp), 0)) {
+		return nil, ErrExpired
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		notBefore, isNumber := nbf.(float64)
+		if !isNumber {
+			return nil, ErrInvalidClaims
+		}
+		if now.Add(c.leeway).Before(time.Unix(int64(notBefore), 0)) {
+			return nil, ErrNotYetValid
+		}
 	}
 
 	return claims, nil
@@ -0,0 +1,483 @@
+// internal/jwks/client_test.go
+// Package jwks provides tests for JWKS caching and fetch failure handling.
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestCircuitBreaker tests that consecutive fetch failures open the breaker,
+// suppressing further fetches until the cooldown passes.
+func TestCircuitBreaker(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithCircuitBreaker(2, time.Hour))
+	defer c.Close()
+	ctx := context.Background()
+	shortCircuits := metrics.NewMetrics().JWKSFetchTotal.WithLabelValues("short_circuit")
+	before := testutil.ToFloat64(shortCircuits)
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.getJWKS(ctx); err == nil {
+			t.Fatal("getJWKS() expected error with failing endpoint")
+		}
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("endpoint fetched %d times, want 2 before the breaker opened", got)
+	}
+	if got := testutil.ToFloat64(shortCircuits) - before; got != 3 {
+		t.Errorf("short_circuit counted %v times, want 3 for the suppressed fetches", got)
+	}
+}
+
+// TestColdFetchSharedWithoutLock tests that concurrent requests on an empty cache share
+// one fetch, and that the cache lock is not held while it runs.
+func TestColdFetchSharedWithoutLock(t *testing.T) {
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"AA"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	defer c.Close()
+	ctx := context.Background()
+
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := c.getJWKS(ctx)
+			errs <- err
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fetches.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("fetch did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.cache.mutex.TryLock() {
+		t.Error("cache lock held during the fetch")
+	} else {
+		c.cache.mutex.Unlock()
+	}
+
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("getJWKS() error = %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("endpoint fetched %d times, want 1 shared fetch", got)
+	}
+}
+
+// TestServeStaleKeys tests that expired keys are served while the endpoint is failing.
+func TestServeStaleKeys(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"AA"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithCacheTTL(time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := c.getKey(ctx, "k1"); err != nil {
+		t.Fatalf("getKey() error = %v", err)
+	}
+
+	fail.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := c.getKey(ctx, "k1"); err != nil {
+		t.Errorf("getKey() with stale cache error = %v, want stale key", err)
+	}
+}
+
+// TestServeStaleKeysWithBreakerOpen tests that serving stale keys while the breaker is open
+// does not count a short circuit per request, since no fetch is skipped on their behalf.
+func TestServeStaleKeysWithBreakerOpen(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"AA"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithCacheTTL(time.Millisecond), WithCircuitBreaker(1, time.Hour))
+	defer c.Close()
+	ctx := context.Background()
+	if _, err := c.getJWKS(ctx); err != nil {
+		t.Fatalf("getJWKS() error = %v", err)
+	}
+
+	// The next request starts a background refresh that fails and opens the breaker
+	fail.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := c.getJWKS(ctx); err != nil {
+		t.Fatalf("getJWKS() with stale cache error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.cache.mutex.RLock()
+		open := c.breakerOpen() && !c.cache.refreshing
+		c.cache.mutex.RUnlock()
+		if open {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("breaker did not open")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	shortCircuits := metrics.NewMetrics().JWKSFetchTotal.WithLabelValues("short_circuit")
+	before := testutil.ToFloat64(shortCircuits)
+	for i := 0; i < 5; i++ {
+		if _, err := c.getJWKS(ctx); err != nil {
+			t.Fatalf("getJWKS() with stale cache error = %v", err)
+		}
+	}
+	if got := testutil.ToFloat64(shortCircuits) - before; got != 0 {
+		t.Errorf("short_circuit counted %v times for stale reads, want 0", got)
+	}
+}
+
+// TestBackgroundRefresh tests that keys are refetched in the background, that validation
+// keeps succeeding against the previous keys while the endpoint fails, and that Close stops
+// the refetching.
+func TestBackgroundRefresh(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	var fetches atomic.Int32
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"` + x + `"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithRefreshInterval(5*time.Millisecond), WithCircuitBreaker(100, time.Hour))
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "did:example:123", "iss": "iss", "aud": "aud", "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.ValidateJWT(ctx, signed, "iss", "aud"); err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+
+	// Wait for background refreshes to hit the failing endpoint
+	fail.Store(true)
+	failedFrom := fetches.Load()
+	deadline := time.Now().Add(5 * time.Second)
+	for fetches.Load() < failedFrom+3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("background refresh fetched %d times after failing, want at least 3", fetches.Load()-failedFrom)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := c.ValidateJWT(ctx, signed, "iss", "aud"); err != nil {
+		t.Errorf("ValidateJWT() with failing endpoint error = %v, want cached keys used", err)
+	}
+
+	c.Close()
+	stopped := fetches.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := fetches.Load(); got != stopped {
+		t.Errorf("endpoint fetched %d times after Close, want 0", got-stopped)
+	}
+}
+
+// TestValidateJWTErrors tests that each validation failure mode returns its typed error.
+func TestValidateJWTErrors(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"` + x + `"}]}`))
+	}))
+	defer srv.Close()
+
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	claims := func(iss, aud string, exp time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "did:example:123", "iss": iss, "aud": aud, "exp": exp.Unix()}
+	}
+	future := time.Now().Add(time.Hour)
+
+	hs256 := jwt.NewWithClaims(jwt.SigningMethodHS256, claims("iss", "aud", future))
+	hs256.Header["kid"] = "k1"
+	hsToken, err := hs256.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := sign("k1", claims("iss", "aud", future))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"malformed", "not-a-jwt", ErrMalformed},
+		{"missing kid", sign("", claims("iss", "aud", future)), ErrMissingKid},
+		{"unknown kid", sign("k2", claims("iss", "aud", future)), ErrKidNotFound},
+		{"expired", sign("k1", claims("iss", "aud", time.Now().Add(-time.Hour))), ErrExpired},
+		{"wrong issuer", sign("k1", claims("other", "aud", future)), ErrInvalidIssuer},
+		{"wrong audience", sign("k1", claims("iss", "other", future)), ErrInvalidAudience},
+		{"unsupported algorithm", hsToken, ErrUnsupportedAlg},
+		{"bad signature", valid[:len(valid)-4] + "AAAA", ErrInvalidSignature},
+	}
+
+	c := NewClient(srv.URL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.ValidateJWT(context.Background(), tt.token, "iss", "aud")
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("ValidateJWT() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ValidateJWT() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// An unreachable JWKS endpoint is a key fetch failure
+	down := NewClient("http://127.0.0.1:1/jwks.json")
+	if _, err := down.ValidateJWT(context.Background(), valid, "iss", "aud"); !errors.Is(err, ErrKeyFetch) {
+		t.Errorf("ValidateJWT() error = %v, want %v", err, ErrKeyFetch)
+	}
+}
+
+// TestValidateJWTLeeway tests that exp and nbf are checked with the configured clock skew
+// leeway: tokens just outside their validity window pass, tokens far outside it fail.
+func TestValidateJWTLeeway(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"` + x + `"}]}`))
+	}))
+	defer srv.Close()
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["sub"], claims["iss"], claims["aud"] = "did:example:123", "iss", "aud"
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = "k1"
+		s, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	now := time.Now()
+	future := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		leeway time.Duration
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"expired 30s ago within leeway", time.Minute, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}, nil},
+		{"expired 120s ago beyond leeway", time.Minute, jwt.MapClaims{"exp": now.Add(-120 * time.Second).Unix()}, ErrExpired},
+		{"expired 30s ago without leeway", 0, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}, ErrExpired},
+		{"not before 30s from now within leeway", time.Minute, jwt.MapClaims{"exp": future, "nbf": now.Add(30 * time.Second).Unix()}, nil},
+		{"not before 120s from now beyond leeway", time.Minute, jwt.MapClaims{"exp": future, "nbf": now.Add(120 * time.Second).Unix()}, ErrNotYetValid},
+		{"not before in the past", 0, jwt.MapClaims{"exp": future, "nbf": now.Add(-time.Minute).Unix()}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(srv.URL, WithLeeway(tt.leeway))
+			defer c.Close()
+			_, err := c.ValidateJWT(context.Background(), sign(tt.claims), "iss", "aud")
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("ValidateJWT() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ValidateJWT() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateJWTAudienceShapes tests that the aud claim is accepted both as a string
+// and as an array, passing when any value matches an expected audience.
+func TestValidateJWTAudienceShapes(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"` + x + `"}]}`))
+	}))
+	defer srv.Close()
+
+	sign := func(aud interface{}) string {
+		claims := jwt.MapClaims{"sub": "did:example:123", "iss": "iss", "exp": time.Now().Add(time.Hour).Unix()}
+		if aud != nil {
+			claims["aud"] = aud
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = "k1"
+		s, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		aud      interface{}
+		expected []string
+		want     error
+	}{
+		{"string", "aud", []string{"aud"}, nil},
+		{"string mismatch", "other", []string{"aud"}, ErrInvalidAudience},
+		{"array containing audience", []string{"other", "aud"}, []string{"aud"}, nil},
+		{"array without audience", []string{"other", "another"}, []string{"aud"}, ErrInvalidAudience},
+		{"empty array", []string{}, []string{"aud"}, ErrInvalidAudience},
+		{"array with non-string values", []interface{}{1, true}, []string{"aud"}, ErrInvalidAudience},
+		{"missing aud", nil, []string{"aud"}, ErrInvalidAudience},
+		{"trusted audience in array", []string{"partner"}, []string{"aud", "partner"}, nil},
+		{"trusted audience as string", "partner", []string{"aud", "partner"}, nil},
+	}
+
+	c := NewClient(srv.URL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.ValidateJWT(context.Background(), sign(tt.aud), "iss", tt.expected...)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("ValidateJWT() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ValidateJWT() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateJWTTypes tests that the typ header is checked against the allowed types.
+func TestValidateJWTTypes(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"OKP","kid":"k1","crv":"Ed25519","alg":"EdDSA","x":"` + x + `"}]}`))
+	}))
+	defer srv.Close()
+
+	sign := func(typ interface{}) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "did:example:123", "iss": "iss", "aud": "aud", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = "k1"
+		if typ == nil {
+			delete(token.Header, "typ")
+		} else {
+			token.Header["typ"] = typ
+		}
+		s, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		typ     interface{}
+		want    error
+	}{
+		{"default JWT", nil, "JWT", nil},
+		{"default missing typ", nil, nil, nil},
+		{"default lowercase", nil, "jwt", nil},
+		{"default rejects dpop", nil, "dpop+jwt", ErrInvalidType},
+		{"default rejects at+jwt", nil, "at+jwt", ErrInvalidType},
+		{"non-string typ", nil, 1, ErrInvalidType},
+		{"configured at+jwt", []string{"at+jwt"}, "application/at+JWT", nil},
+		{"configured rejects missing typ", []string{"at+jwt"}, nil, ErrInvalidType},
+		{"configured empty entry", []string{"at+jwt", ""}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(srv.URL, WithAllowedTypes(tt.allowed...))
+			_, err := c.ValidateJWT(context.Background(), sign(tt.typ), "iss", "aud")
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("ValidateJWT() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ValidateJWT() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// internal/jwks/dpop.go
+// DPoP (RFC 9449) proof validation for proof-of-possession bound access tokens.
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors returned by ValidateDPoPProof, wrapped with context; match them with errors.Is
+var (
+	ErrInvalidDPoP = errors.New("invalid DPoP proof") // Proof is malformed, unsigned, or not bound to the request
+)
+
+// DPoP proof freshness bounds
+const (
+	DPoPProofMaxAge    = 5 * time.Minute // How old a proof's iat may be
+	DPoPProofClockSkew = time.Minute     // How far in the future a proof's iat may be
+)
+
+// DPoPProof holds the validated contents of a DPoP proof the caller still has to check
+type DPoPProof struct {
+	JTI        string    // Unique proof ID, for replay detection
+	IssuedAt   time.Time // Proof creation time
+	Nonce      string    // Server-provided nonce echoed by the client (empty if absent)
+	Thumbprint string    // RFC 7638 SHA-256 thumbprint of the proof key, compared with cnf.jkt
+}
+
+// ValidateDPoPProof checks a DPoP proof JWT: its typ, signature by the embedded public key,
+// and its binding to the HTTP method, request URI and access token. Nonce and replay checks,
+// and comparing the thumbprint with the access token's cnf.jkt, are left to the caller.
+func ValidateDPoPProof(proof, method, requestURI, accessToken string, now time.Time) (*DPoPProof, error) {
+	var thumbprint string
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, "dpop+jwt") {
+			return nil, fmt.Errorf("typ must be dpop+jwt")
+		}
+		jwk, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("missing jwk header")
+		}
+		key, tp, err := dpopPublicKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = tp
+		return key, nil
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"EdDSA", "ES256"}))
+	token, err := parser.ParseWithClaims(proof, jwt.MapClaims{}, keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDPoP, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid claims", ErrInvalidDPoP)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidDPoP)
+	}
+	if htm, _ := claims["htm"].(string); htm != method {
+		return nil, fmt.Errorf("%w: htm does not match the request method", ErrInvalidDPoP)
+	}
+	if htu, _ := claims["htu"].(string); !sameHTU(htu, requestURI) {
+		return nil, fmt.Errorf("%w: htu does not match the request URI", ErrInvalidDPoP)
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing iat", ErrInvalidDPoP)
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	if issuedAt.Before(now.Add(-DPoPProofMaxAge)) || issuedAt.After(now.Add(DPoPProofClockSkew)) {
+		return nil, fmt.Errorf("%w: iat outside the accepted window", ErrInvalidDPoP)
+	}
+	athSum := sha256.Sum256([]byte(accessToken))
+	if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(athSum[:]) {
+		return nil, fmt.Errorf("%w: ath does not match the access token", ErrInvalidDPoP)
+	}
+	nonce, _ := claims["nonce"].(string)
+
+	return &DPoPProof{JTI: jti, IssuedAt: issuedAt, Nonce: nonce, Thumbprint: thumbprint}, nil
+}
+
+// dpopPublicKey converts the proof's jwk header into a public key and its RFC 7638 thumbprint.
+// Ed25519 (OKP) and P-256 (EC) keys are supported; private key material is rejected.
+func dpopPublicKey(jwk map[string]interface{}) (interface{}, string, error) {
+	if _, ok := jwk["d"]; ok {
+		return nil, "", fmt.Errorf("jwk must not contain a private key")
+	}
+	kty, _ := jwk["kty"].(string)
+	crv, _ := jwk["crv"].(string)
+	x, _ := jwk["x"].(string)
+	xBytes, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid jwk x: %v", err)
+	}
+
+	// Thumbprint members are the required ones, in lexicographic order
+	switch {
+	case kty == "OKP" && crv == "Ed25519":
+		if len(xBytes) != ed25519.PublicKeySize {
+			return nil, "", fmt.Errorf("invalid Ed25519 key length")
+		}
+		tp, err := thumbprint(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{crv, kty, x})
+		return ed25519.PublicKey(xBytes), tp, err
+	case kty == "EC" && crv == "P-256":
+		y, _ := jwk["y"].(string)
+		yBytes, err := base64.RawURLEncoding.DecodeString(y)
+		if err != nil || len(xBytes) != 32 || len(yBytes) != 32 {
+			return nil, "", fmt.Errorf("invalid P-256 key coordinates")
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{0x04}, xBytes...), yBytes...))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid P-256 key: %v", err)
+		}
+		tp, err := thumbprint(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{crv, kty, x, y})
+		return key, tp, err
+	default:
+		return nil, "", fmt.Errorf("unsupported jwk key type %q/%q", kty, crv)
+	}
+}
+
+// thumbprint returns the base64url SHA-256 of the canonical JWK members
+func thumbprint(members interface{}) (string, error) {
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// sameHTU compares a proof's htu with the request URI, ignoring query and fragment,
+// case in the scheme and host, and default ports
+func sameHTU(htu, requestURI string) bool {
+	a, err := url.Parse(htu)
+	if err != nil || a.Host == "" {
+		return false
+	}
+	b, err := url.Parse(requestURI)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(hostWithoutDefaultPort(a), hostWithoutDefaultPort(b)) &&
+		a.EscapedPath() == b.EscapedPath()
+}
+
+// hostWithoutDefaultPort drops :80 for http and :443 for https
+func hostWithoutDefaultPort(u *url.URL) string {
+	port := u.Port()
+	if (port == "80" && strings.EqualFold(u.Scheme, "http")) || (port == "443" && strings.EqualFold(u.Scheme, "https")) {
+		return u.Hostname()
+	}
+	return u.Host
+}
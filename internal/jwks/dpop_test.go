@@ -0,0 +1,126 @@
+// internal/jwks/dpop_test.go
+// Package jwks provides tests for DPoP proof validation.
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestValidateDPoPProof tests that proofs are bound to the key, method, URI and access token.
+func TestValidateDPoPProof(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(pub)}
+	accessToken := "header.payload.signature"
+	ath := sha256.Sum256([]byte(accessToken))
+	now := time.Now()
+
+	sign := func(mutate func(header, claims map[string]interface{})) string {
+		claims := jwt.MapClaims{
+			"jti": "proof-1", "htm": "POST", "htu": "https://cdv.example/v1/repo/record",
+			"iat": now.Unix(), "ath": base64.RawURLEncoding.EncodeToString(ath[:]), "nonce": "n1",
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["typ"] = "dpop+jwt"
+		token.Header["jwk"] = jwk
+		if mutate != nil {
+			mutate(token.Header, claims)
+		}
+		s, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	foreign := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"jti": "x", "htm": "POST", "htu": "https://cdv.example/v1/repo/record", "iat": now.Unix(), "ath": base64.RawURLEncoding.EncodeToString(ath[:])})
+	foreign.Header["typ"] = "dpop+jwt"
+	foreign.Header["jwk"] = jwk
+	foreignProof, err := foreign.SignedString(otherPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		proof string
+		uri   string
+		valid bool
+	}{
+		{"valid", sign(nil), "https://cdv.example/v1/repo/record", true},
+		{"default port and query ignored", sign(func(h, c map[string]interface{}) { c["htu"] = "HTTPS://CDV.example:443/v1/repo/record?x=1" }), "https://cdv.example/v1/repo/record", true},
+		{"wrong typ", sign(func(h, c map[string]interface{}) { h["typ"] = "JWT" }), "https://cdv.example/v1/repo/record", false},
+		{"missing jwk", sign(func(h, c map[string]interface{}) { delete(h, "jwk") }), "https://cdv.example/v1/repo/record", false},
+		{"private key in jwk", sign(func(h, c map[string]interface{}) { h["jwk"] = map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": jwk["x"], "d": "secret"} }), "https://cdv.example/v1/repo/record", false},
+		{"signed by another key", foreignProof, "https://cdv.example/v1/repo/record", false},
+		{"wrong method", sign(func(h, c map[string]interface{}) { c["htm"] = "GET" }), "https://cdv.example/v1/repo/record", false},
+		{"wrong URI", sign(nil), "https://cdv.example/v1/media/finalize", false},
+		{"stale iat", sign(func(h, c map[string]interface{}) { c["iat"] = now.Add(-time.Hour).Unix() }), "https://cdv.example/v1/repo/record", false},
+		{"future iat", sign(func(h, c map[string]interface{}) { c["iat"] = now.Add(time.Hour).Unix() }), "https://cdv.example/v1/repo/record", false},
+		{"wrong ath", sign(func(h, c map[string]interface{}) { c["ath"] = "nope" }), "https://cdv.example/v1/repo/record", false},
+		{"missing jti", sign(func(h, c map[string]interface{}) { delete(c, "jti") }), "https://cdv.example/v1/repo/record", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := ValidateDPoPProof(tt.proof, "POST", tt.uri, accessToken, now)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidDPoP) {
+					t.Errorf("ValidateDPoPProof() error = %v, want %v", err, ErrInvalidDPoP)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateDPoPProof() error = %v", err)
+			}
+			if proof.JTI != "proof-1" || proof.Nonce != "n1" {
+				t.Errorf("ValidateDPoPProof() = %+v, want jti proof-1 and nonce n1", proof)
+			}
+		})
+	}
+}
+
+// TestDPoPThumbprint tests the RFC 7638 thumbprint against the example in RFC 8037 appendix A.3,
+// and that P-256 proofs are accepted.
+func TestDPoPThumbprint(t *testing.T) {
+	_, tp, err := dpopPublicKey(map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; tp != want {
+		t.Errorf("thumbprint = %s, want %s", tp, want)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ath := sha256.Sum256([]byte("token"))
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"jti": "p", "htm": "GET", "htu": "http://localhost:8080/v1/media/list", "iat": time.Now().Unix(), "ath": base64.RawURLEncoding.EncodeToString(ath[:])})
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = map[string]interface{}{"kty": "EC", "crv": "P-256", "x": base64.RawURLEncoding.EncodeToString(raw[1:33]), "y": base64.RawURLEncoding.EncodeToString(raw[33:])}
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateDPoPProof(proof, "GET", "http://localhost:8080/v1/media/list", "token", time.Now()); err != nil {
+		t.Errorf("ValidateDPoPProof() with ES256 error = %v", err)
+	}
+}
@@ -6,15 +6,44 @@
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"time"
 
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
+// ErrInvalidUpload is returned when S3 rejects a multipart upload because of its parts or
+// upload ID, i.e. because of the client's request rather than a storage failure
+var ErrInvalidUpload = errors.New("invalid multipart upload")
+
+// correlationIDHeader carries the request correlation ID on S3 requests the service makes,
+// so S3 or MinIO request logs can be tied back to the request
+const correlationIDHeader = "X-Correlation-Id"
+
+// withCorrelationID sends the correlation ID in ctx with an S3 request. Presigned URLs do not
+// use it, since a signed header would have to be sent by the client too.
+func withCorrelationID(ctx context.Context) func(*s3.Options) {
+	id := telemetry.CorrelationID(ctx)
+	return func(o *s3.Options) {
+		if id != "" {
+			o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue(correlationIDHeader, id))
+		}
+	}
+}
+
+// CompletedPart is an uploaded part of a multipart upload
+type CompletedPart struct {
+	PartNumber int32  // 1-based part number
+	ETag       string // ETag S3 returned for the part
+}
+
 // S3Client wraps the AWS S3 client for media operations.
 // It provides methods for generating presigned URLs and verifying media objects.
 type S3Client struct {
@@ -62,6 +91,14 @@ func(ctx context.Context) (aws.Credentials, error) {
 	}, nil
 }
 
+// Ping checks that the bucket exists and the credentials can access it
+func (s *S3Client) Ping(ctx context.Context) error {
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)}, withCorrelationID(ctx)); err != nil {
+		return fmt.Errorf("bucket %s unavailable: %w", s.bucket, err)
+	}
+	return nil
+}
+
 // GenerateUploadURL generates a presigned URL for uploading media.
 // This allows clients to upload directly to S3 without streaming through the CDV service.
 // Parameters:
@@ -89,6 +126,36 @@ func (s *S3Client) GenerateUploadURL(ctx context.Context, key string, expires ti
 	return presignResult.URL, nil
 }
 
+// GenerateDownloadURL generates a presigned URL for downloading media.
+// The object is served with contentType so browsers render it as the stored media type.
+// Parameters:
+//   - ctx: Context for the operation
+//   - key: S3 object key to download
+//   - contentType: Content-Type S3 returns with the object (empty keeps the stored one)
+//   - expires: Duration until the presigned URL expires
+// Returns:
+//   - string: Presigned URL for downloading
+//   - error: Any error that occurred during URL generation
+func (s *S3Client) GenerateDownloadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket), // Source S3 bucket
+		Key:    aws.String(key),      // Object key in the bucket
+	}
+	if contentType != "" {
+		input.ResponseContentType = aws.String(contentType)
+	}
+	presignResult, err := presignClient.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = expires // URL expiration time
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return presignResult.URL, nil
+}
+
 // VerifyObject verifies that an object exists and matches the expected checksum.
 // This ensures data integrity after upload completion.
 // Parameters:
@@ -104,7 +171,7 @@ func (s *S3Client) VerifyObject(ctx context.Context, key, expectedChecksum strin
 	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket), // Target S3 bucket
 		Key:    aws.String(key),      // Object key in the bucket
-	})
+	}, withCorrelationID(ctx))
 	if err != nil {
 		return false, 0, fmt.Errorf("failed to get object metadata: %w", err)
 	}
@@ -113,7 +180,7 @@ func (s *S3Client) VerifyObject(ctx context.Context, key, expectedChecksum strin
 	getObjectOutput, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
-	})
+	}, withCorrelationID(ctx))
 	if err != nil {
 		return false, 0, fmt.Errorf("failed to download object: %w", err)
 	}
@@ -133,3 +200,86 @@ func (s *S3Client) VerifyObject(ctx context.Context, key, expectedChecksum strin
 
 	return true, *result.ContentLength, nil
 }
+
+// CreateMultipartUpload starts a multipart upload of the object at key.
+// Parameters:
+//   - ctx: Context for the operation
+//   - key: S3 object key where the file will be stored
+//   - contentType: Content-Type stored with the object
+// Returns:
+//   - string: Upload ID identifying the multipart upload
+//   - error: Any error that occurred starting the upload
+func (s *S3Client) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	result, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, withCorrelationID(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(result.UploadId), nil
+}
+
+// GeneratePartUploadURL generates a presigned URL for uploading one part of a multipart upload.
+func (s *S3Client) GeneratePartUploadURL(ctx context.Context, key, uploadID string, partNumber int32, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	
+	presignResult, err := presignClient.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expires // URL expiration time
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned part URL: %w", err)
+	}
+	return presignResult.URL, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts, in part number order, into the
+// object at key. Parts S3 rejects yield an error wrapping ErrInvalidUpload.
+func (s *S3Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = types.CompletedPart{PartNumber: aws.Int32(part.PartNumber), ETag: aws.String(part.ETag)}
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	}, withCorrelationID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", invalidUploadError(err))
+	}
+	return nil
+}
+
+// AbortMultipartUpload abandons a multipart upload so S3 frees its uploaded parts.
+// An upload S3 no longer knows yields an error wrapping ErrInvalidUpload.
+func (s *S3Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, withCorrelationID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", invalidUploadError(err))
+	}
+	return nil
+}
+
+// invalidUploadError marks S3 errors caused by the request's parts or upload ID with ErrInvalidUpload
+func invalidUploadError(err error) error {
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "InvalidPart", "InvalidPartOrder", "EntityTooSmall", "NoSuchUpload":
+			return fmt.Errorf("%w: %w", ErrInvalidUpload, err)
+		}
+	}
+	return err
+}
@@ -15,22 +15,67 @@ type Metrics struct {
 	// Storage operation metrics
 	StorageOperationTotal    *prometheus.CounterVec
 	StorageOperationDuration *prometheus.HistogramVec
+	CorruptRecordsTotal      *prometheus.CounterVec // Stored records skipped because they cannot be decoded
 
 	// Event publishing metrics
 	EventPublishTotal    *prometheus.CounterVec
 	EventPublishDuration *prometheus.HistogramVec
+	EventQueueDepth      prometheus.Gauge // Events waiting in the internal publish queue
+	EventPublisherActive *prometheus.GaugeVec // 1 for the event backend in use (nats, noop), 0 otherwise
 
 	// Schema validation metrics
 	SchemaValidationTotal    *prometheus.CounterVec
 	SchemaValidationDuration *prometheus.HistogramVec
+
+	// Media operation metrics (presign, verify)
+	MediaOperationTotal    *prometheus.CounterVec
+	MediaOperationDuration *prometheus.HistogramVec
+
+	// Export metrics
+	ExportRecordsTotal prometheus.Counter // Records streamed by exports
+	ExportsInProgress  prometheus.Gauge   // Exports currently running
+
+	// JWKS fetch metrics
+	JWKSFetchTotal  *prometheus.CounterVec // Fetch attempts by result (success, failure, short_circuit)
+	JWKSCircuitOpen prometheus.Gauge       // 1 while the JWKS fetch circuit breaker is open
 }
 
+// Default histogram buckets (in seconds) tuned for the CDV workload.
+var (
+	// DefaultLatencyBuckets covers 1ms to 2.5s with fine resolution below 100ms,
+	// where the bulk of API, storage, and validation calls land.
+	DefaultLatencyBuckets = []float64{.001, .0025, .005, .0075, .01, .015, .025, .035, .05, .075, .1, .25, .5, 1, 2.5}
+
+	// DefaultMediaBuckets covers slower media operations such as object
+	// verification, which downloads and hashes the uploaded object.
+	DefaultMediaBuckets = []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+)
+
 // Global metrics instance with mutex for thread safety
 var (
 	globalMetrics *Metrics
 	metricsMutex  sync.Mutex
+
+	// Buckets in effect when the metrics are first created
+	latencyBuckets = DefaultLatencyBuckets
+	mediaBuckets   = DefaultMediaBuckets
 )
 
+// SetBuckets overrides the histogram buckets used for latency and media metrics.
+// Empty slices keep the defaults. It must be called before the first NewMetrics call,
+// since histograms are registered once and their buckets cannot change afterwards.
+func SetBuckets(latency, media []float64) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	if len(latency) > 0 {
+		latencyBuckets = latency
+	}
+	if len(media) > 0 {
+		mediaBuckets = media
+	}
+}
+
 // NewMetrics creates a new Metrics instance with all required metrics
 func NewMetrics() *Metrics {
 	metricsMutex.Lock()
@@ -51,7 +96,7 @@ func NewMetrics() *Metrics {
 		HTTPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Buckets: latencyBuckets,
 		}, []string{"method", "path", "status"}),
 
 		// Storage operation metrics
@@ -63,9 +108,14 @@ func NewMetrics() *Metrics {
 		StorageOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "storage_operation_duration_seconds",
 			Help:    "Storage operation duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Buckets: latencyBuckets,
 		}, []string{"operation", "status"}),
 
+		CorruptRecordsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_corrupt_records_total",
+			Help: "Total number of stored records skipped because they could not be decoded",
+		}, []string{"stage"}),
+
 		// Event publishing metrics
 		EventPublishTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "event_publish_total",
@@ -75,9 +125,19 @@ func NewMetrics() *Metrics {
 		EventPublishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "event_publish_duration_seconds",
 			Help:    "Event publish duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Buckets: latencyBuckets,
 		}, []string{"event_type", "status"}),
 
+		EventQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "event_queue_depth",
+			Help: "Number of events waiting in the internal publish queue",
+		}),
+
+		EventPublisherActive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "event_publisher_active",
+			Help: "Whether each event backend type is in use (1) or not (0)",
+		}, []string{"type"}),
+
 		// Schema validation metrics
 		SchemaValidationTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "schema_validation_total",
@@ -87,8 +147,42 @@ func NewMetrics() *Metrics {
 		SchemaValidationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "schema_validation_duration_seconds",
 			Help:    "Schema validation duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Buckets: latencyBuckets,
 		}, []string{"collection", "status"}),
+
+		// Media operation metrics
+		MediaOperationTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "media_operations_total",
+			Help: "Total number of media operations",
+		}, []string{"operation", "status"}),
+
+		MediaOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "media_operation_duration_seconds",
+			Help:    "Media operation duration in seconds",
+			Buckets: mediaBuckets,
+		}, []string{"operation", "status"}),
+
+		// Export metrics
+		ExportRecordsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "export_records_total",
+			Help: "Total number of records streamed by repository exports",
+		}),
+
+		ExportsInProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "exports_in_progress",
+			Help: "Number of repository exports currently running",
+		}),
+
+		// JWKS fetch metrics
+		JWKSFetchTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jwks_fetch_total",
+			Help: "Total number of JWKS fetch attempts",
+		}, []string{"result"}),
+
+		JWKSCircuitOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "jwks_circuit_open",
+			Help: "Whether the JWKS fetch circuit breaker is open (1) or closed (0)",
+		}),
 	}
 	
 	// Register metrics with the default registry
@@ -107,10 +201,19 @@ func registerMetrics(m *Metrics) {
 	registerOrGet(m.HTTPRequestDuration)
 	registerOrGet(m.StorageOperationTotal)
 	registerOrGet(m.StorageOperationDuration)
+	registerOrGet(m.CorruptRecordsTotal)
 	registerOrGet(m.EventPublishTotal)
 	registerOrGet(m.EventPublishDuration)
+	registerOrGet(m.EventQueueDepth)
+	registerOrGet(m.EventPublisherActive)
 	registerOrGet(m.SchemaValidationTotal)
 	registerOrGet(m.SchemaValidationDuration)
+	registerOrGet(m.MediaOperationTotal)
+	registerOrGet(m.MediaOperationDuration)
+	registerOrGet(m.ExportRecordsTotal)
+	registerOrGet(m.ExportsInProgress)
+	registerOrGet(m.JWKSFetchTotal)
+	registerOrGet(m.JWKSCircuitOpen)
 }
 
 // registerOrGet tries to register a metric, returns the existing one if already registered
@@ -4,6 +4,12 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -28,8 +34,15 @@ type Record struct {
 	Value        map[string]interface{} `json:"value" db:"value"`              // Record data as JSON
 	IndexedAt    time.Time              `json:"indexedAt" db:"indexed_at"`     // When the record was indexed
 	SchemaVersion string                `json:"schemaVersion" db:"schema_version"` // Schema version for validation
+	Visibility   string                 `json:"visibility,omitempty" db:"visibility"` // VisibilityPublic or VisibilityPrivate (empty means public)
 }
 
+// Record visibility values
+const (
+	VisibilityPublic  = "public"  // Readable by anyone
+	VisibilityPrivate = "private" // Readable only by the owning DID
+)
+
 // MediaAsset represents a CDV media asset.
 // A media asset is a file (image, video, etc.) that has been uploaded and processed.
 // This corresponds to the media_assets table in storage.
@@ -41,6 +54,8 @@ type MediaAsset struct {
 	Size      int64     `json:"size" db:"size"`            // Size in bytes
 	Checksum  string    `json:"checksum" db:"checksum"`    // SHA-256 checksum for integrity
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`  // When the asset was created
+	ObjectKey string    `json:"-" db:"object_key"`         // Key of the uploaded object in media storage
+	UploadID  string    `json:"uploadId,omitempty" db:"upload_id"` // Multipart upload in progress, cleared once completed
 }
 
 // OperationLogEntry represents an entry in the operation log.
@@ -64,6 +79,48 @@ type ListRecordsQuery struct {
 	Cursor     string    `json:"cursor"`     // Pagination cursor
 	Since      time.Time `json:"since"`      // Filter records created after this time
 	Until      time.Time `json:"until"`      // Filter records created before this time
+	IncludePrivate bool  `json:"includePrivate"` // Include private records; only for the owning DID
+	ValueFilters map[string]string `json:"valueFilters,omitempty"` // Require these top-level value keys to equal the given strings
+	CursorIssuedAfter time.Time `json:"-"` // Reject cursors issued before this time (zero means cursors do not expire)
+}
+
+// FilterHash identifies the query's filters; cursors carry it so a cursor
+// cannot be replayed against a query with different filters
+func (q ListRecordsQuery) FilterHash() string {
+	filterTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	filters := fmt.Sprintf("%s\x00%s\x00%s\x00%s", q.DID, q.Collection, filterTime(q.Since), filterTime(q.Until))
+	// Value filters are appended only when present so existing cursors stay valid
+	keys := make([]string, 0, len(q.ValueFilters))
+	for key := range q.ValueFilters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		filters += fmt.Sprintf("\x00%s=%s", key, q.ValueFilters[key])
+	}
+	sum := sha256.Sum256([]byte(filters))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// BacklinksQuery selects the public records of a collection, across all DIDs,
+// whose value.subject equals Subject
+type BacklinksQuery struct {
+	Subject    string `json:"subject"`    // URI or DID the records refer to
+	Collection string `json:"collection"` // Collection of the referring records
+	Limit      int    `json:"limit"`      // Maximum number of records to return
+	Cursor     string `json:"cursor"`     // Pagination cursor
+	CursorIssuedAfter time.Time `json:"-"` // Reject cursors issued before this time (zero means cursors do not expire)
+}
+
+// FilterHash identifies the query's filters like ListRecordsQuery.FilterHash
+func (q BacklinksQuery) FilterHash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("backlinks\x00%s\x00%s", q.Subject, q.Collection)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
 }
 
 // ListRecordsResult represents the result of listing records.
@@ -73,6 +130,131 @@ type ListRecordsResult struct {
 	NextCursor string   `json:"nextCursor,omitempty"` // Cursor for next page of results
 }
 
+// CountRecordsData is the number of records matching a countRecords query.
+type CountRecordsData struct {
+	Count int64 `json:"count"` // Records matching the filters
+}
+
+// CollectionStats summarizes a DID's records in one collection.
+type CollectionStats struct {
+	Collection     string   `json:"collection"`     // Collection NSID
+	Count          int64    `json:"count"`          // Records in the collection
+	SchemaVersions []string `json:"schemaVersions"` // Schema versions the records were validated against, sorted
+}
+
+// DescribeRepoData summarizes an account's repository.
+type DescribeRepoData struct {
+	DID         string            `json:"did"`         // Account DID
+	CreatedAt   time.Time         `json:"createdAt"`   // When the account was created
+	Collections []CollectionStats `json:"collections"` // Per-collection record counts, sorted by collection
+}
+
+// SchemaRefreshData reports the schema index fetched by a schema refresh.
+type SchemaRefreshData struct {
+	GeneratedAt time.Time        `json:"generatedAt"` // When the specs repository generated the index
+	Schemas     []ResolvedSchema `json:"schemas"`     // Schemas listed in the index
+	Resolved    map[string]string `json:"resolved"`   // Version now resolved for each supported collection
+}
+
+// ResolvedSchema describes one schema in the specs index.
+type ResolvedSchema struct {
+	NSID         string   `json:"nsid"`         // Schema NSID (namespace and name)
+	LatestStable string   `json:"latestStable"` // Latest stable version
+	Versions     []string `json:"versions"`     // All published versions
+	Status       string   `json:"status"`       // Lifecycle status, e.g. stable or deprecated
+}
+
+// ListMediaAssetsQuery represents the parameters for listing a DID's media assets.
+type ListMediaAssetsQuery struct {
+	DID       string `json:"did"`       // Owner's DID
+	MimeType  string `json:"mimeType"`  // Exact MIME type, or a type wildcard such as "image/*"
+	Finalized *bool  `json:"finalized"` // Filter by whether the upload was finalized (nil means any)
+	Limit     int    `json:"limit"`     // Maximum number of assets to return
+	Cursor    string `json:"cursor"`    // Pagination cursor
+	CursorIssuedAfter time.Time `json:"-"` // Reject cursors issued before this time (zero means cursors do not expire)
+}
+
+// FilterHash identifies the query's filters like ListRecordsQuery.FilterHash
+func (q ListMediaAssetsQuery) FilterHash() string {
+	finalized := ""
+	if q.Finalized != nil {
+		finalized = strconv.FormatBool(*q.Finalized)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("media\x00%s\x00%s\x00%s", q.DID, q.MimeType, finalized)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// ListOperationsQuery represents the parameters for reading a DID's operation log.
+type ListOperationsQuery struct {
+	DID    string    `json:"did"`    // DID whose operations are listed
+	Since  time.Time `json:"since"`  // Only operations that occurred at or after this time
+	Until  time.Time `json:"until"`  // Only operations that occurred at or before this time
+	Limit  int       `json:"limit"`  // Maximum number of operations to return
+	Cursor string    `json:"cursor"` // Pagination cursor
+	CursorIssuedAfter time.Time `json:"-"` // Reject cursors issued before this time (zero means cursors do not expire)
+}
+
+// FilterHash identifies the query's filters like ListRecordsQuery.FilterHash
+func (q ListOperationsQuery) FilterHash() string {
+	filterTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("oplog\x00%s\x00%s\x00%s", q.DID, filterTime(q.Since), filterTime(q.Until))))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// ListOperationsResult represents a page of operation log entries in sequence order.
+type ListOperationsResult struct {
+	Operations []OperationLogEntry `json:"operations"`           // Operations in ascending sequence order
+	NextCursor string              `json:"nextCursor,omitempty"` // Cursor for next page of results
+}
+
+// ConsistencyReport lists stored data that violates referential integrity.
+// Samples are capped, while the counts cover everything found.
+type ConsistencyReport struct {
+	OrphanedRecordCount int      `json:"orphanedRecordCount"` // Records whose DID has no account
+	OrphanedRecords     []string `json:"orphanedRecords"`     // Sample of orphaned record URIs
+	OrphanedMediaCount  int      `json:"orphanedMediaCount"`  // Media assets whose DID has no account
+	OrphanedMedia       []string `json:"orphanedMedia"`       // Sample of orphaned media asset IDs
+}
+
+// AccountSettings holds an account's settings, such as display preferences, feature flags
+// or plan tier. It is both the request body and the response data of the settings endpoints.
+type AccountSettings struct {
+	Settings map[string]interface{} `json:"settings"` // Settings as a JSON object
+}
+
+// CreateAccountsRequest represents the request body for provisioning accounts in bulk.
+type CreateAccountsRequest struct {
+	DIDs []string `json:"dids"` // DIDs to create accounts for
+}
+
+// Account creation statuses reported per DID by a bulk account creation
+const (
+	AccountStatusCreated  = "created"  // The account was created
+	AccountStatusConflict = "conflict" // An account already existed for the DID
+)
+
+// CreateAccountsData reports the outcome of a bulk account creation.
+type CreateAccountsData struct {
+	Results []CreateAccountResult `json:"results"` // One result per requested DID, in request order
+}
+
+// CreateAccountResult is the outcome of creating one account in a bulk account creation.
+type CreateAccountResult struct {
+	DID    string `json:"did"`    // Requested DID
+	Status string `json:"status"` // AccountStatusCreated or AccountStatusConflict
+}
+
+// ListMediaAssetsResult represents the result of listing a DID's media assets.
+type ListMediaAssetsResult struct {
+	Assets     []MediaAsset `json:"assets"`               // Media assets, newest first
+	NextCursor string       `json:"nextCursor,omitempty"` // Cursor for next page of results
+}
+
 // CreateRecordRequest represents the request body for creating a record.
 // It contains all the information needed to create a new record.
 type CreateRecordRequest struct {
@@ -81,6 +263,7 @@ type CreateRecordRequest struct {
 	Record          map[string]interface{} `json:"record"`           // Record data
 	CreatedAt       *time.Time             `json:"createdAt,omitempty"` // Optional creation time
 	IdempotencyKey  string                 `json:"idempotencyKey,omitempty"` // Key for idempotent operations
+	Visibility      string                 `json:"visibility,omitempty"` // public (default) or private
 }
 
 // CreateRecordResponse represents the response body for creating a record.
@@ -96,6 +279,45 @@ type CreateRecordData struct {
 	IndexedAt time.Time `json:"indexedAt"` // When the record was indexed
 }
 
+// GetRecordsRequest represents the request body for fetching records by URI in bulk.
+type GetRecordsRequest struct {
+	URIs []string `json:"uris"` // Record URIs to look up
+}
+
+// GetRecordsData contains the records found for a bulk lookup.
+type GetRecordsData struct {
+	Records  []Record `json:"records"`  // Found records, in request order
+	NotFound []string `json:"notFound"` // Requested URIs with no visible record
+}
+
+// UpdateRecordRequest represents the request body for replacing a record's value.
+// Collection and DID are optional but must match the record when given.
+type UpdateRecordRequest struct {
+	URI        string                 `json:"uri"`                  // URI of the record to update
+	Collection string                 `json:"collection,omitempty"` // Collection of the record
+	DID        string                 `json:"did,omitempty"`        // Owner's Decentralized Identifier
+	Record     map[string]interface{} `json:"record"`               // New record data
+	Visibility string                 `json:"visibility,omitempty"` // public or private (empty keeps the current visibility)
+}
+
+// FeedResult represents a page of a following feed.
+type FeedResult struct {
+	Records    []Record `json:"records"`              // Posts, newest first
+	NextCursor string   `json:"nextCursor,omitempty"` // Cursor for next page of results
+	Truncated  bool     `json:"truncated,omitempty"`  // Whether only the most recent follows, up to the fan-out limit, were used
+}
+
+// DeleteRecordRequest represents the optional request body for deleting a record.
+// The URI may be given in the uri query parameter instead.
+type DeleteRecordRequest struct {
+	URI string `json:"uri"` // URI of the record to delete
+}
+
+// DeleteRecordData contains the URI of a deleted record.
+type DeleteRecordData struct {
+	URI string `json:"uri"` // URI of the deleted record
+}
+
 // UploadInitRequest represents the request body for initializing a media upload.
 // It contains the metadata needed to prepare for media file upload.
 type UploadInitRequest struct {
@@ -132,8 +354,81 @@ type FinalizeResponse struct {
 	Data MediaAsset `json:"data"` // Finalized media asset metadata
 }
 
+// UploadInitMultipartRequest represents the request body for starting a multipart media upload.
+// It takes the same fields as UploadInitRequest plus the size of each part.
+type UploadInitMultipartRequest struct {
+	DID      string `json:"did"`      // Owner's Decentralized Identifier
+	MimeType string `json:"mimeType"` // MIME type of the file to be uploaded
+	Size     int64  `json:"size"`     // Size of the file in bytes
+	SHA256   string `json:"sha256,omitempty"` // Optional SHA-256 checksum for integrity
+	Filename string `json:"filename,omitempty"` // Optional original filename
+	PartSize int64  `json:"partSize,omitempty"` // Optional bytes per part; chosen by the service if omitted
+}
+
+// UploadInitMultipartData contains the details needed to upload a media file in parts.
+type UploadInitMultipartData struct {
+	AssetID   string          `json:"assetId"`   // Unique identifier for the media asset
+	UploadID  string          `json:"uploadId"`  // Multipart upload ID, passed to completeMultipart or abortMultipart
+	PartSize  int64           `json:"partSize"`  // Bytes per part; only the last part may be smaller
+	Parts     []MultipartPart `json:"parts"`     // Presigned URL for each part
+	ExpiresAt time.Time       `json:"expiresAt"` // When the part URLs expire
+}
+
+// MultipartPart identifies one part of a multipart upload. Init returns the part's upload
+// URL; completeMultipart takes the ETag returned by the upload.
+type MultipartPart struct {
+	PartNumber int32  `json:"partNumber"`          // 1-based part number
+	UploadURL  string `json:"uploadUrl,omitempty"` // Presigned URL to PUT the part to
+	ETag       string `json:"etag,omitempty"`      // ETag returned when the part was uploaded
+}
+
+// CompleteMultipartRequest represents the request body for assembling uploaded parts.
+type CompleteMultipartRequest struct {
+	AssetID  string          `json:"assetId"`  // Identifier of the media asset being uploaded
+	UploadID string          `json:"uploadId"` // Multipart upload ID from uploadInitMultipart
+	Parts    []MultipartPart `json:"parts"`    // Part numbers and ETags of every uploaded part
+}
+
+// AbortMultipartRequest represents the request body for abandoning a multipart upload.
+type AbortMultipartRequest struct {
+	AssetID  string `json:"assetId"`  // Identifier of the media asset being uploaded
+	UploadID string `json:"uploadId"` // Multipart upload ID from uploadInitMultipart
+}
+
+// AbortMultipartData contains the ID of the media asset removed by an aborted upload.
+type AbortMultipartData struct {
+	AssetID string `json:"assetId"` // Identifier of the removed media asset
+}
+
 // GetMediaMetaResponse represents the response body for getting media metadata.
 // It returns the metadata for a specific media asset.
 type GetMediaMetaResponse struct {
 	Data MediaAsset `json:"data"` // Requested media asset metadata
 }
+
+// IdempotencyStatusData contains the cached outcome of a request made with an idempotency key.
+type IdempotencyStatusData struct {
+	Key      string          `json:"key"`      // Client-supplied idempotency key
+	Status   int             `json:"status"`   // HTTP status code of the original response
+	Response json.RawMessage `json:"response"` // Original response body
+}
+
+// Readiness check statuses
+const (
+	ReadinessOK       = "ok"       // Dependency is reachable
+	ReadinessDegraded = "degraded" // Dependency is unreachable but the service still serves records
+	ReadinessFailed   = "failed"   // Dependency is unreachable and the service is not ready
+)
+
+// ReadinessCheck reports the outcome of checking one dependency from /readyz.
+type ReadinessCheck struct {
+	Name   string `json:"name"`            // Dependency name, e.g. database, nats, s3
+	Status string `json:"status"`          // ok, degraded or failed
+	Error  string `json:"error,omitempty"` // Why the check did not pass, when error detail is enabled
+}
+
+// ReadinessDetails contains the details of a failed readiness probe.
+type ReadinessDetails struct {
+	Dependency string           `json:"dependency"` // First required dependency that failed
+	Checks     []ReadinessCheck `json:"checks"`     // Every dependency checked, in order
+}
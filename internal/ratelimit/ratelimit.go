@@ -0,0 +1,87 @@
+// internal/ratelimit/ratelimit.go
+// Package ratelimit provides token-bucket rate limiting keyed by caller, in process or
+// shared between replicas through Redis.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are dropped
+const sweepInterval = time.Minute
+
+// Allower admits or refuses requests per key
+type Allower interface {
+	// Allow takes a token from key's bucket. When the bucket is empty it returns false
+	// and how long until a token becomes available.
+	Allow(ctx context.Context, key string) (bool, time.Duration)
+}
+
+// Limiter is a set of token buckets, one per key, refilled at a fixed rate.
+// Limits are per process; replicas behind a load balancer each enforce their own
+// unless they share a RedisLimiter instead.
+type Limiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket capacity
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // Clock, replaced in tests
+}
+
+// bucket holds the tokens left for one key as of last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter allowing rps requests per second per key, with bursts of up to
+// burst requests. A burst below one is raised to one.
+func New(rps float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rps,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements Allower
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that would be full by now, which are indistinguishable from new ones
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
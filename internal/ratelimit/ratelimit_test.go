@@ -0,0 +1,99 @@
+// Package ratelimit provides tests for the token-bucket limiters. The Redis limiter runs
+// when CDV_TEST_REDIS_URL is set.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestLimiter tests bursts, refill, per-key isolation and the reported wait.
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow(context.Background(), "a"); !ok {
+			t.Fatalf("request %d within the burst was refused", i)
+		}
+	}
+	ok, wait := l.Allow(context.Background(), "a")
+	if ok {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms at 2 rps", wait)
+	}
+	if ok, _ := l.Allow(context.Background(), "b"); !ok {
+		t.Error("another key was limited by a's bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow(context.Background(), "a"); !ok {
+		t.Error("request after refilling one token was refused")
+	}
+	if ok, _ := l.Allow(context.Background(), "a"); ok {
+		t.Error("second request after refilling one token was allowed")
+	}
+
+	// Idle buckets are dropped once they have refilled
+	now = now.Add(time.Hour)
+	l.Allow(context.Background(), "c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("refilled bucket for a was not swept")
+	}
+}
+
+// TestRedisLimiter tests that two limiters sharing a Redis prefix, like two replicas,
+// enforce one burst and refill between them.
+func TestRedisLimiter(t *testing.T) {
+	url := os.Getenv("CDV_TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("CDV_TEST_REDIS_URL not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// A fresh prefix per run keeps reruns against a shared server independent
+	prefix := fmt.Sprintf("ratelimit-test%d:", time.Now().UnixNano())
+	replicas := []*RedisLimiter{NewRedis(client, prefix, 2, 3), NewRedis(client, prefix, 2, 3)}
+	for _, l := range replicas {
+		l.now = func() time.Time { return now }
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := replicas[i%2].Allow(ctx, "a"); !ok {
+			t.Fatalf("request %d within the burst was refused", i)
+		}
+	}
+	ok, wait := replicas[1].Allow(ctx, "a")
+	if ok {
+		t.Fatal("request beyond the shared burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms at 2 rps", wait)
+	}
+	if ok, _ := replicas[0].Allow(ctx, "b"); !ok {
+		t.Error("another key was limited by a's bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := replicas[0].Allow(ctx, "a"); !ok {
+		t.Error("request after refilling one token was refused")
+	}
+	if ok, _ := replicas[1].Allow(ctx, "a"); ok {
+		t.Error("second request after refilling one token was allowed")
+	}
+}
@@ -0,0 +1,75 @@
+// internal/ratelimit/redis.go
+// Package ratelimit provides a Redis-backed limiter, so replicas behind a load balancer
+// enforce one limit per key together instead of one each. Buckets live in hashes under
+// the limiter's prefix and expire once they would have refilled completely.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes a token from the bucket at KEYS[1], refilled at ARGV[1] tokens per
+// second up to ARGV[2], as of ARGV[3] milliseconds. It returns 0 when a token was taken
+// and otherwise the milliseconds until one is available. Running as a script makes the
+// refill and the take atomic across replicas.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) / 1000 * rate)
+	last = now
+end
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(last))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return wait
+`)
+
+// RedisLimiter is a Limiter whose buckets are kept in Redis and shared by every
+// replica using the same prefix
+type RedisLimiter struct {
+	client redis.Scripter // Redis client holding the buckets
+	prefix string         // Prefix of the bucket keys
+	rate   float64        // Tokens added per second
+	burst  float64        // Bucket capacity
+	now    func() time.Time // Clock, replaced in tests
+}
+
+// NewRedis creates a limiter like New whose buckets are kept in Redis under prefix
+func NewRedis(client redis.Scripter, prefix string, rps float64, burst int) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		prefix: prefix,
+		rate:   rps,
+		burst:  math.Max(float64(burst), 1),
+		now:    time.Now,
+	}
+}
+
+// Allow implements Allower. If Redis cannot be reached the request is allowed, so an
+// outage of the limiter does not take the service down with it.
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	wait, err := takeScript.Run(ctx, l.client, []string{l.prefix + key}, l.rate, l.burst, l.now().UnixMilli()).Int64()
+	if err != nil {
+		slog.Warn("rate limiter unavailable, allowing request", "error", err)
+		return true, 0
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Millisecond
+	}
+	return true, 0
+}
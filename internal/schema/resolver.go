@@ -2,12 +2,14 @@
 package schema
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -28,10 +30,26 @@ type SchemaInfo struct {
 	ReplacedBy    *string `json:"replacedBy"`
 }
 
+// latestStable returns the latest stable version of the schema whose namespace and name
+// form collection, marked ":deprecated" when the schema is deprecated, or "" if absent
+func (idx *SchemaIndex) latestStable(collection string) string {
+	for _, s := range idx.Schemas {
+		if s.Namespace+"."+s.Name != collection || s.LatestStable == "" {
+			continue
+		}
+		if s.Status == "deprecated" {
+			return s.LatestStable + ":deprecated"
+		}
+		return s.LatestStable
+	}
+	return ""
+}
+
 // Resolver handles schema resolution from the specs repository
 type Resolver struct {
 	specsURL     string
 	cacheDir     string
+	mu           sync.Mutex // Protects index and lastUpdate
 	index        *SchemaIndex
 	lastUpdate   time.Time
 	cacheTimeout time.Duration
@@ -48,7 +66,17 @@ func NewResolver(specsURL, cacheDir string) *Resolver {
 
 // ResolveSchemaVersion resolves a collection NSID to its latest stable version
 func (r *Resolver) ResolveSchemaVersion(collection string) (string, error) {
-	// For now, return a default version since the index doesn't match our collection names
+	// An index loaded by Refresh takes precedence for the collections it lists
+	r.mu.Lock()
+	index := r.index
+	r.mu.Unlock()
+	if index != nil {
+		if version := index.latestStable(collection); version != "" {
+			return version, nil
+		}
+	}
+	
+	// Otherwise return a default version since the index doesn't match our collection names
 	// In a real implementation, we would fetch the actual schema file and extract version info
 	switch collection {
 	case "com.registryaccord.feed.post":
@@ -74,6 +102,9 @@ func (r *Resolver) ResolveSchemaVersion(collection string) (string, error) {
 
 // getSchemaIndex retrieves the schema index from the specs repository
 func (r *Resolver) getSchemaIndex() (*SchemaIndex, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
 	// Check if we have a cached version that's still valid
 	if r.index != nil && time.Since(r.lastUpdate) < r.cacheTimeout {
 		return r.index, nil
@@ -89,7 +120,7 @@ func (r *Resolver) getSchemaIndex() (*SchemaIndex, error) {
 	}
 
 	// Fetch from remote repository
-	index, err = r.fetchFromRemote()
+	index, err = r.fetchFromRemote(context.Background())
 	if err != nil {
 		// If remote fetch fails but we have a stale cache, use it
 		if r.index != nil {
@@ -138,10 +169,49 @@ func (r *Resolver) saveToCache(index *SchemaIndex) {
 	_ = os.WriteFile(cachePath, data, 0644) // Ignore errors
 }
 
+// Ping checks that the specs repository is reachable by issuing a HEAD request for SPEC_INDEX.json
+func (r *Resolver) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, r.specsURL+"/SPEC_INDEX.json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema index unavailable: %s", resp.Status)
+	}
+	return nil
+}
+
+// Refresh fetches the schema index from the specs repository now, bypassing the memory
+// and disk caches, so newly published versions take effect without waiting for the
+// cache to expire. On failure the current index is kept.
+func (r *Resolver) Refresh(ctx context.Context) (*SchemaIndex, error) {
+	index, err := r.fetchFromRemote(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema index: %w", err)
+	}
+	
+	r.mu.Lock()
+	r.index = index
+	r.lastUpdate = time.Now()
+	r.mu.Unlock()
+	r.saveToCache(index)
+	return index, nil
+}
+
 // fetchFromRemote fetches the schema index from the remote specs repository
-func (r *Resolver) fetchFromRemote() (*SchemaIndex, error) {
-	indexURL := r.specsURL + "/SPEC_INDEX.json"
-	resp, err := http.Get(indexURL)
+func (r *Resolver) fetchFromRemote(ctx context.Context) (*SchemaIndex, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.specsURL+"/SPEC_INDEX.json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -6,13 +6,20 @@
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/xeipuuv/gojsonschema"
 )
 
-// SupportedCollections lists all collections that are supported for schema validation.
-// Only records belonging to these collections can be validated and stored.
+// SupportedCollections lists the built-in collections supported for schema validation.
+// A Validator may support more, loaded from CDV_SCHEMA_DIR; ask it with Supports.
 var SupportedCollections = map[string]bool{
 	"com.registryaccord.feed.post":     true,  // User posts/feed items
 	"com.registryaccord.profile":       true,  // User profile information
@@ -38,25 +45,81 @@
 	"com.registryaccord.media.asset":   "1.0.0",  // Media asset schema version
 }
 
+// didPattern matches the W3C DID syntax: did:<method>:<method-specific-id>
+var didPattern = regexp.MustCompile(`^did:[a-z0-9]+:[A-Za-z0-9._:%-]*[A-Za-z0-9._%-]$`)
+
+// ValidDID reports whether s has the did:<method>:<method-specific-id> shape
+func ValidDID(s string) bool {
+	return didPattern.MatchString(s)
+}
+
+// didFormatChecker checks the custom "did" format
+type didFormatChecker struct{}
+
+// IsFormat implements gojsonschema.FormatChecker. Non-strings pass, as the type keyword
+// rejects them.
+func (didFormatChecker) IsFormat(input interface{}) bool {
+	s, ok := input.(string)
+	return !ok || ValidDID(s)
+}
+
+// datetimeFormatChecker checks the custom "datetime" format as an RFC 3339 timestamp
+type datetimeFormatChecker struct{}
+
+// IsFormat implements gojsonschema.FormatChecker
+func (datetimeFormatChecker) IsFormat(input interface{}) bool {
+	s, ok := input.(string)
+	if !ok {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339Nano, s)
+	return err == nil
+}
+
+// registerFormats adds the custom formats used by the record schemas to gojsonschema's
+// global checkers. Unknown formats are not checked at all, so without this malformed
+// DIDs and timestamps would pass validation.
+var registerFormats = sync.OnceFunc(func() {
+	gojsonschema.FormatCheckers.Add("did", didFormatChecker{})
+	gojsonschema.FormatCheckers.Add("datetime", datetimeFormatChecker{})
+})
+
+// UnvalidatedVersion is the schema version stored for records in collections accepted
+// without schema validation
+const UnvalidatedVersion = "unvalidated"
+
+// UnknownVersion is the schema version stored for records in collections that have no
+// schema, when unknown collections are allowed
+const UnknownVersion = "unknown"
+
 // Validator validates records against JSON schemas.
 // It ensures data integrity and consistency across all stored records.
 type Validator struct {
 	schemas map[string]*gojsonschema.Schema // Map of collection names to JSON schemas
+	localVersions map[string]string // Versions of schemas loaded from CDV_SCHEMA_DIR, which the resolver does not know
 	resolver *Resolver // Schema resolver for dynamic version resolution
 }
 
+// defaultLocalVersion is the version of a directory-loaded schema without a version field
+const defaultLocalVersion = "1.0.0"
+
 // NewValidator creates a new schema validator.
-// It initializes all supported schemas and prepares them for validation.
+// It initializes all supported schemas and prepares them for validation. When
+// CDV_SCHEMA_DIR is set, the schemas in that directory are loaded on top of the
+// built-in ones; see loadSchemaDir.
 // Returns:
 //   - *Validator: Initialized validator instance
 //   - error: Any error that occurred during initialization
 func NewValidator() (*Validator, error) {
+	registerFormats()
+	
 	// Initialize the resolver
 	resolver := NewResolver("https://raw.githubusercontent.com/RegistryAccord/registryaccord-specs/main/schemas", "/tmp/registryaccord-specs-cache")
 	
 	// Initialize the validator with an empty schema map
 	v := &Validator{
 		schemas: make(map[string]*gojsonschema.Schema),
+		localVersions: make(map[string]string),
 		resolver: resolver,
 	}
 
@@ -64,6 +127,11 @@ func NewValidator() (*Validator, error) {
 	if err := v.loadSchemas(); err != nil {
 		return nil, fmt.Errorf("failed to load schemas: %w", err)
 	}
+	if dir := os.Getenv("CDV_SCHEMA_DIR"); dir != "" {
+		if err := v.loadSchemaDir(dir); err != nil {
+			return nil, fmt.Errorf("failed to load schemas from %s: %w", dir, err)
+		}
+	}
 
 	return v, nil
 }
@@ -129,6 +197,44 @@ func (v *Validator) loadSchemas() error {
 	return nil
 }
 
+// loadSchemaDir loads every *.json file under dir as the schema of the collection named by
+// the file name without its extension, e.g. com.example.note.json. These collections become
+// supported by this validator, and a file for a built-in collection replaces its inline schema.
+// A top-level "version" string sets the schema version recorded for their records
+// (default 1.0.0); the specs repository is not consulted for them.
+func (v *Validator) loadSchemaDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		collection := strings.TrimSuffix(d.Name(), ".json")
+		if collection == "" {
+			return nil
+		}
+		schemaJSON, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var meta struct {
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(schemaJSON, &meta); err != nil {
+			return fmt.Errorf("invalid schema for %s: %w", collection, err)
+		}
+		if err := v.loadSchema(collection, string(schemaJSON)); err != nil {
+			return err
+		}
+		v.localVersions[collection] = defaultLocalVersion
+		if meta.Version != "" {
+			v.localVersions[collection] = meta.Version
+		}
+		return nil
+	})
+}
+
 // loadSchema loads a single schema.
 // It parses and compiles a JSON schema for a specific collection type.
 // Parameters:
@@ -160,15 +266,10 @@ func (v *Validator) loadSchema(collection, schemaJSON string) error {
 //   - string: The schema version used for validation
 //   - error: nil if valid, error with details if invalid
 func (v *Validator) Validate(collection string, record map[string]interface{}) (string, error) {
-	// Check if the collection is supported for validation
-	if !SupportedCollections[collection] {
-		return "", fmt.Errorf("unsupported collection: %s", collection)
-	}
-
-	// Get the compiled schema for this collection
+	// Get the compiled schema for this collection; only collections with one are supported
 	schema, exists := v.schemas[collection]
 	if !exists {
-		return "", fmt.Errorf("schema not found for collection: %s", collection)
+		return "", fmt.Errorf("unsupported collection: %s", collection)
 	}
 
 	// Convert the record to JSON for validation
@@ -193,6 +294,9 @@ func (v *Validator) Validate(collection string, record map[string]interface{}) (
 	}
 
 	// Get the schema version
+	if version, local := v.localVersions[collection]; local {
+		return version, nil
+	}
 	schemaVersion, exists := SchemaVersions[collection]
 	if !exists {
 		schemaVersion = "1.0.0" // Default version if not specified
@@ -202,7 +306,27 @@ func (v *Validator) Validate(collection string, record map[string]interface{}) (
 	return schemaVersion, nil
 }
 
-// ResolveSchemaVersion resolves a collection NSID to its latest stable version
+// Supports reports whether the validator has a schema for collection
+func (v *Validator) Supports(collection string) bool {
+	_, exists := v.schemas[collection]
+	return exists
+}
+
+// Collections returns the collections the validator has schemas for, sorted
+func (v *Validator) Collections() []string {
+	collections := make([]string, 0, len(v.schemas))
+	for collection := range v.schemas {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	return collections
+}
+
+// ResolveSchemaVersion resolves a collection NSID to its latest stable version. Schemas
+// loaded from CDV_SCHEMA_DIR keep their own version.
 func (v *Validator) ResolveSchemaVersion(collection string) (string, error) {
+	if version, local := v.localVersions[collection]; local {
+		return version, nil
+	}
 	return v.resolver.ResolveSchemaVersion(collection)
 }
@@ -0,0 +1,114 @@
+// internal/schema/validator_test.go
+// Package schema provides tests for record validation against the inline and directory-loaded schemas.
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestValidateFormats tests that the custom did and datetime formats are checked.
+func TestValidateFormats(t *testing.T) {
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		name    string
+		record  map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"text": "hello", "createdAt": "2025-01-01T00:00:00Z", "authorDid": "did:example:123"}, false},
+		{"fractional seconds and offset", map[string]interface{}{"text": "hello", "createdAt": "2025-01-01T00:00:00.123+02:00", "authorDid": "did:plc:abc123"}, false},
+		{"malformed did", map[string]interface{}{"text": "hello", "createdAt": "2025-01-01T00:00:00Z", "authorDid": "not-a-did"}, true},
+		{"did without identifier", map[string]interface{}{"text": "hello", "createdAt": "2025-01-01T00:00:00Z", "authorDid": "did:example:"}, true},
+		{"malformed createdAt", map[string]interface{}{"text": "hello", "createdAt": "yesterday", "authorDid": "did:example:123"}, true},
+		{"createdAt without zone", map[string]interface{}{"text": "hello", "createdAt": "2025-01-01T00:00:00", "authorDid": "did:example:123"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Validate("com.registryaccord.feed.post", tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	
+	if _, err := v.Validate("com.registryaccord.graph.follow", map[string]interface{}{"subject": "not-a-did"}); err == nil {
+		t.Error("Validate() accepted a follow with a malformed subject DID")
+	}
+}
+
+// TestSchemaDir tests that schemas in CDV_SCHEMA_DIR add collections and override the
+// built-in schemas.
+func TestSchemaDir(t *testing.T) {
+	dir := t.TempDir()
+	note := `{"version":"2.1.0","type":"object","required":["body"],"properties":{"body":{"type":"string","maxLength":10}}}`
+	if err := os.WriteFile(filepath.Join(dir, "com.example.note.json"), []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	like := `{"type":"object","required":["subject","weight"],"properties":{"subject":{"type":"string"},"weight":{"type":"integer"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "com.registryaccord.feed.like.json"), []byte(like), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a schema"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CDV_SCHEMA_DIR", dir)
+	
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Supports("com.example.note") {
+		t.Error("com.example.note is not a supported collection")
+	}
+	
+	// Loaded schemas keep their own version rather than asking the specs repository
+	for collection, want := range map[string]string{"com.example.note": "2.1.0", "com.registryaccord.feed.like": defaultLocalVersion} {
+		if version, err := v.ResolveSchemaVersion(collection); err != nil || version != want {
+			t.Errorf("%s: got version %q, err %v, want %s", collection, version, err, want)
+		}
+	}
+	
+	// Loaded collections belong to the validator that loaded them
+	t.Setenv("CDV_SCHEMA_DIR", "")
+	other, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Supports("com.example.note") || SupportedCollections["com.example.note"] {
+		t.Error("com.example.note leaked into a validator without CDV_SCHEMA_DIR")
+	}
+	tests := []struct {
+		name       string
+		collection string
+		record     map[string]interface{}
+		wantErr    bool
+	}{
+		{"custom valid", "com.example.note", map[string]interface{}{"body": "hello"}, false},
+		{"custom missing field", "com.example.note", map[string]interface{}{"text": "hello"}, true},
+		{"custom too long", "com.example.note", map[string]interface{}{"body": "hello, world"}, true},
+		{"override valid", "com.registryaccord.feed.like", map[string]interface{}{"subject": "at://x", "weight": 2}, false},
+		{"override rejects built-in shape", "com.registryaccord.feed.like", map[string]interface{}{"subject": "at://x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Validate(tt.collection, tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	
+	// An invalid schema fails startup rather than being skipped
+	t.Setenv("CDV_SCHEMA_DIR", dir)
+	if err := os.WriteFile(filepath.Join(dir, "com.example.broken.json"), []byte(`{"type":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewValidator(); err == nil {
+		t.Error("NewValidator() accepted an invalid schema file")
+	}
+}
@@ -0,0 +1,84 @@
+// internal/server/account.go
+// Account settings endpoints, letting the authenticated account read and replace its own
+// settings.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// handleGetAccountSettings handles GET /v1/account/settings, returning the caller's settings
+func (m *Mux) handleGetAccountSettings(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetAccountSettings")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	did := ctx.Value(ContextKeyDID).(string)
+	span.SetAttributes(attribute.String("did", did))
+	
+	settingsCtx, settingsSpan := startChildSpan(ctx, "storage.GetAccountSettings")
+	settings, err := m.s.GetAccountSettings(settingsCtx, did)
+	endChildSpan(settingsSpan, err)
+	if err != nil {
+		code, msg := errordefs.CDV_INTERNAL, "failed to get account settings"
+		if errors.Is(err, storage.ErrNotFound) {
+			code, msg = errordefs.CDV_NOT_FOUND, "account not found"
+		}
+		errDef := errordefs.New(code, msg, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	m.writeSuccess(w, http.StatusOK, model.AccountSettings{Settings: settings})
+}
+
+// handleUpdateAccountSettings handles PUT /v1/account/settings, replacing the caller's
+// settings. The account is created if it does not exist yet, as on a first record.
+func (m *Mux) handleUpdateAccountSettings(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleUpdateAccountSettings")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	did := ctx.Value(ContextKeyDID).(string)
+	span.SetAttributes(attribute.String("did", did))
+	
+	var req model.AccountSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errDef := m.jsonBodyError(err, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	if req.Settings == nil {
+		errDef := errordefs.New(errordefs.CDV_VALIDATION, "settings must be a JSON object", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	updateCtx, updateSpan := startChildSpan(ctx, "storage.UpdateAccountSettings")
+	err := m.s.UpdateAccountSettings(updateCtx, did, req.Settings)
+	if errors.Is(err, storage.ErrNotFound) {
+		// A concurrent request may create the account first; either way it exists now
+		if err = m.s.CreateAccount(updateCtx, did); err == nil || errors.Is(err, storage.ErrConflict) {
+			err = m.s.UpdateAccountSettings(updateCtx, did, req.Settings)
+		}
+	}
+	endChildSpan(updateSpan, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to update account settings", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	m.writeSuccess(w, http.StatusOK, req)
+}
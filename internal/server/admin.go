@@ -0,0 +1,203 @@
+// internal/server/admin.go
+// Operator endpoints under /v1/admin/, restricted to DIDs configured as admins.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// consistencySampleLimit caps how many offending IDs a consistency report lists per kind
+const consistencySampleLimit = 100
+
+// maxAccountBatch caps how many DIDs a bulk account creation accepts
+const maxAccountBatch = 1000
+
+// WithAdminDIDs sets the DIDs allowed to call /v1/admin/ endpoints.
+// With none configured, admin endpoints reject every caller.
+func WithAdminDIDs(dids ...string) Option {
+	return func(m *Mux) {
+		m.adminDIDs = dids
+	}
+}
+
+// WithAuditLogger sets the logger receiving audit entries for admin operations.
+// Without one, audit entries go to the default logger tagged "log": "audit".
+func WithAuditLogger(l *slog.Logger) Option {
+	return func(m *Mux) {
+		m.auditLogger = l
+	}
+}
+
+// audit records an admin operation: who performed it, what it did and when.
+// Entries are kept off the request log so they can be retained and shipped separately.
+func (m *Mux) audit(ctx context.Context, action, outcome string, attrs ...any) {
+	logger := m.auditLogger
+	if logger == nil {
+		logger = slog.Default().With("log", "audit")
+	}
+	did, _ := ctx.Value(ContextKeyDID).(string)
+	correlationID, _ := ctx.Value(ContextKeyCorrelationID).(string)
+	attrs = append([]any{"actor", did, "action", action, "outcome", outcome, "at", time.Now().UTC(), "correlationId", correlationID}, attrs...)
+	logger.LogAttrs(ctx, slog.LevelInfo, "admin operation", slog.Group("audit", attrs...))
+}
+
+// requireAdmin rejects callers whose authenticated DID is not an admin
+func (m *Mux) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		did := r.Context().Value(ContextKeyDID).(string)
+		if !slices.Contains(m.adminDIDs, did) {
+			m.audit(r.Context(), r.Method+" "+r.URL.Path, "denied")
+			correlationID := r.Context().Value(ContextKeyCorrelationID).(string)
+			m.writeErrorDef(w, errordefs.New(errordefs.CDV_AUTHZ, "admin access required", correlationID))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleConsistency handles GET /v1/admin/consistency, reporting records and media
+// assets that reference a DID without an account
+func (m *Mux) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleConsistency")
+	defer span.End()
+	
+	checkCtx, checkSpan := startChildSpan(ctx, "storage.CheckConsistency")
+	report, err := m.s.CheckConsistency(checkCtx, consistencySampleLimit)
+	endChildSpan(checkSpan, err)
+	if err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		slog.Error("consistency check failed", "error", err, "correlationId", correlationID)
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to check consistency", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	span.SetAttributes(
+		attribute.Int("orphanedRecords", report.OrphanedRecordCount),
+		attribute.Int("orphanedMedia", report.OrphanedMediaCount),
+	)
+	if report.OrphanedRecordCount > 0 || report.OrphanedMediaCount > 0 {
+		slog.Warn("consistency check found orphaned data", "records", report.OrphanedRecordCount, "media", report.OrphanedMediaCount)
+	}
+	m.writeSuccess(w, http.StatusOK, report)
+}
+
+// handleCreateAccounts handles POST /v1/admin/accounts/batch, creating accounts for a list
+// of DIDs in one transaction so a cohort can be provisioned at once. DIDs that already have
+// an account are reported as conflicts rather than failing the batch.
+func (m *Mux) handleCreateAccounts(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleCreateAccounts")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	var req model.CreateAccountsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errDef := m.jsonBodyError(err, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	if len(req.DIDs) == 0 || len(req.DIDs) > maxAccountBatch {
+		errDef := errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("dids must list between 1 and %d DIDs", maxAccountBatch), correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	for _, did := range req.DIDs {
+		if !schema.ValidDID(did) {
+			errDef := errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("invalid DID %q", did), correlationID)
+			failSpan(span, errDef)
+			m.writeErrorDef(w, errDef)
+			return
+		}
+	}
+	span.SetAttributes(attribute.Int("dids", len(req.DIDs)))
+	
+	createCtx, createSpan := startChildSpan(ctx, "storage.CreateAccounts")
+	created, err := m.s.CreateAccounts(createCtx, req.DIDs)
+	endChildSpan(createSpan, err)
+	if err != nil {
+		slog.Error("bulk account creation failed", "error", err, "correlationId", correlationID)
+		m.audit(ctx, "accounts.batch", "failed", "dids", len(req.DIDs), "error", err.Error())
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to create accounts", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	data := model.CreateAccountsData{Results: make([]model.CreateAccountResult, len(req.DIDs))}
+	createdCount := 0
+	for i, did := range req.DIDs {
+		status := model.AccountStatusConflict
+		if created[i] {
+			status = model.AccountStatusCreated
+			createdCount++
+		}
+		data.Results[i] = model.CreateAccountResult{DID: did, Status: status}
+	}
+	m.audit(ctx, "accounts.batch", "ok", "created", createdCount, "conflicts", len(req.DIDs)-createdCount)
+	m.writeSuccess(w, http.StatusOK, data)
+}
+
+// handleSchemaRefresh handles POST /v1/admin/schema/refresh, refetching the schema index
+// so new versions are used immediately rather than after the resolver's cache expires
+func (m *Mux) handleSchemaRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleSchemaRefresh")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	previous := m.resolvedVersions()
+	refreshCtx, refreshSpan := startChildSpan(ctx, "schema.Refresh")
+	index, err := m.resolver.Refresh(refreshCtx)
+	endChildSpan(refreshSpan, err)
+	if err != nil {
+		slog.Error("schema refresh failed", "error", err, "correlationId", correlationID)
+		m.audit(ctx, "schema.refresh", "failed", "error", err.Error())
+		errDef := errordefs.New(errordefs.CDV_UNAVAILABLE, "failed to fetch the schema index; the current versions are kept", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	data := model.SchemaRefreshData{GeneratedAt: index.GeneratedAt, Schemas: []model.ResolvedSchema{}, Resolved: m.resolvedVersions()}
+	for _, s := range index.Schemas {
+		data.Schemas = append(data.Schemas, model.ResolvedSchema{
+			NSID: s.Namespace + "." + s.Name, LatestStable: s.LatestStable, Versions: s.Versions, Status: s.Status,
+		})
+	}
+	changed := make(map[string]string)
+	for collection, version := range data.Resolved {
+		if previous[collection] != version {
+			changed[collection] = previous[collection] + " -> " + version
+		}
+	}
+	span.SetAttributes(attribute.Int("schemas", len(data.Schemas)))
+	slog.Info("schema index refreshed", "schemas", len(data.Schemas), "generatedAt", index.GeneratedAt)
+	m.audit(ctx, "schema.refresh", "ok", "generatedAt", index.GeneratedAt, "changed", changed)
+	m.writeSuccess(w, http.StatusOK, data)
+}
+
+// resolvedVersions returns the schema version currently resolved for each supported collection
+func (m *Mux) resolvedVersions() map[string]string {
+	versions := make(map[string]string)
+	for _, collection := range m.validator.Collections() {
+		if version, err := m.resolver.ResolveSchemaVersion(collection); err == nil {
+			versions[collection] = version
+		}
+	}
+	return versions
+}
@@ -0,0 +1,84 @@
+// internal/server/car.go
+// Minimal CARv1 (Content Addressable aRchive) writer used by repository export.
+// Blocks are the JSON-encoded records, addressed by CIDv1 (json codec, sha2-256),
+// preceded by a manifest block that serves as the archive root.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	cidVersion1   = 0x01   // CIDv1
+	codecJSON     = 0x0200 // multicodec: json
+	multihashSHA2 = 0x12   // multihash: sha2-256
+	sha256Length  = 32
+)
+
+// carHeader returns the DAG-CBOR encoding of {"roots": [root], "version": 1}.
+// CARv1 readers require at least one root, so exports name their manifest block.
+func carHeader(root []byte) []byte {
+	header := []byte{
+		0xa2,                          // map(2)
+		0x65, 'r', 'o', 'o', 't', 's', // "roots"
+		0x81,       // array(1)
+		0xd8, 0x2a, // tag(42): CID
+		0x58, byte(len(root) + 1), 0x00, // bytes(len+1) with the identity multibase prefix
+	}
+	header = append(header, root...)
+	return append(header, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01) // "version": 1
+}
+
+// exportManifest is the root block of a CAR export. Records are streamed as they are
+// read, so the root describes the export rather than linking every record block.
+type exportManifest struct {
+	DID        string    `json:"did"`
+	Collection string    `json:"collection,omitempty"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// carWriter writes a CARv1 stream of JSON blocks
+type carWriter struct {
+	w io.Writer
+}
+
+// WriteHeader writes the CAR header rooted at the manifest block, followed by that
+// block; it must be called once before any other block
+func (c *carWriter) WriteHeader(manifest exportManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	header := carHeader(jsonCID(data))
+	buf := binary.AppendUvarint(nil, uint64(len(header)))
+	if _, err := c.w.Write(append(buf, header...)); err != nil {
+		return err
+	}
+	_, err = c.WriteBlock(data)
+	return err
+}
+
+// WriteBlock writes one block and returns its CID bytes
+func (c *carWriter) WriteBlock(data []byte) ([]byte, error) {
+	cid := jsonCID(data)
+	buf := binary.AppendUvarint(nil, uint64(len(cid)+len(data)))
+	buf = append(buf, cid...)
+	if _, err := c.w.Write(buf); err != nil {
+		return nil, err
+	}
+	_, err := c.w.Write(data)
+	return cid, err
+}
+
+// jsonCID returns the binary CIDv1 of a JSON block
+func jsonCID(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	cid := binary.AppendUvarint(nil, cidVersion1)
+	cid = binary.AppendUvarint(cid, codecJSON)
+	cid = append(cid, multihashSHA2, sha256Length)
+	return append(cid, sum[:]...)
+}
@@ -0,0 +1,68 @@
+// internal/server/clientip.go
+// Client IP resolution for per-client limits, honouring X-Forwarded-* headers only from trusted proxies.
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies sets the proxies (CIDR blocks) whose X-Forwarded-For header is
+// used to find the client IP and whose X-Forwarded-Proto header is used to find the request
+// scheme. Without any, the connection's remote address and TLS state are used.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(m *Mux) {
+		m.trustedProxies = append(m.trustedProxies, prefixes...)
+	}
+}
+
+// clientIP returns the IP of the client behind r. When the peer is a trusted proxy,
+// X-Forwarded-For is walked from the right and the first untrusted hop is used, so a
+// client cannot spoof its address by sending the header itself.
+func (m *Mux) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !m.isTrustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed hop cannot be trusted; stop at the last address we can vouch for
+			break
+		}
+		addr = hop
+		if !m.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+// fromTrustedProxy reports whether r was received directly from a trusted proxy
+func (m *Mux) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && m.isTrustedProxy(addr)
+}
+
+// isTrustedProxy reports whether addr is within one of the trusted proxy blocks
+func (m *Mux) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range m.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// internal/server/dpop.go
+// DPoP (RFC 9449) support: DPoP-bound access tokens must be presented with the
+// DPoP authorization scheme and a proof signed by the key named in cnf.jkt.
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/jwks"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+)
+
+// dpopNonceWindow is how long an issued nonce stays current; nonces from the
+// previous window are still accepted, so a nonce lives between one and two windows
+const dpopNonceWindow = 5 * time.Minute
+
+// DPoP error values for the WWW-Authenticate challenge
+const (
+	dpopErrorUseNonce     = "use_dpop_nonce"
+	dpopErrorInvalidProof = "invalid_dpop_proof"
+)
+
+// WithDPoP enables DPoP-bound access tokens. Nonces are HMACs of the current time
+// window under nonceKey; instances behind one load balancer must share the key.
+// An empty key generates a per-instance one.
+func WithDPoP(enabled bool, nonceKey []byte) Option {
+	return func(m *Mux) {
+		if !enabled {
+			m.dpopNonceKey = nil
+			return
+		}
+		if len(nonceKey) == 0 {
+			nonceKey = make([]byte, 32)
+			if _, err := rand.Read(nonceKey); err != nil {
+				panic(fmt.Sprintf("failed to generate DPoP nonce key: %v", err))
+			}
+		}
+		m.dpopNonceKey = nonceKey
+	}
+}
+
+// dpopEnabled reports whether DPoP-bound tokens are supported
+func (m *Mux) dpopEnabled() bool {
+	return m.dpopNonceKey != nil
+}
+
+// dpopNonce returns the nonce for the time window containing now
+func (m *Mux) dpopNonce(now time.Time) string {
+	return m.dpopNonceFor(now.Unix() / int64(dpopNonceWindow/time.Second))
+}
+
+// dpopNonceFor returns the nonce for a time window: the window number followed by its HMAC
+func (m *Mux) dpopNonceFor(window int64) string {
+	buf := binary.BigEndian.AppendUint64(nil, uint64(window))
+	mac := hmac.New(sha256.New, m.dpopNonceKey)
+	mac.Write(buf)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(buf)[:8+16])
+}
+
+// validDPoPNonce reports whether nonce was issued for the current or previous window
+func (m *Mux) validDPoPNonce(nonce string, now time.Time) bool {
+	window := now.Unix() / int64(dpopNonceWindow/time.Second)
+	for _, w := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(nonce), []byte(m.dpopNonceFor(w))) {
+			return true
+		}
+	}
+	return false
+}
+
+// dpopError builds the authentication error for a failed DPoP check; the middleware
+// turns the details into a WWW-Authenticate challenge
+func dpopError(dpopErr, message string) error {
+	return errordefs.NewWithDetails(errordefs.CDV_JWT_INVALID, message, "", map[string]string{"dpopError": dpopErr})
+}
+
+// setDPoPChallenge adds the DPoP WWW-Authenticate challenge for a DPoP authentication error
+func setDPoPChallenge(w http.ResponseWriter, err *errordefs.Error) {
+	details, ok := err.Details.(map[string]string)
+	if !ok || details["dpopError"] == "" {
+		return
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`DPoP algs="EdDSA ES256", error="%s"`, details["dpopError"]))
+}
+
+// dpopRequestURI reconstructs the request URI that the proof's htu must match. The scheme
+// is taken from X-Forwarded-Proto only when a trusted proxy sent the request.
+func (m *Mux) dpopRequestURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || (m.fromTrustedProxy(r) && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
+
+// checkDPoP enforces sender-constraining for an authenticated request. Tokens bound to
+// a key (cnf.jkt) must use the DPoP scheme with a valid proof of that key; the DPoP
+// scheme is only accepted for bound tokens.
+func (m *Mux) checkDPoP(r *http.Request, tokenString string, source tokenSource, claims map[string]interface{}) error {
+	var jkt string
+	if cnf, ok := claims["cnf"].(map[string]interface{}); ok {
+		jkt, _ = cnf["jkt"].(string)
+	}
+	if source != tokenFromDPoP {
+		if jkt != "" {
+			return dpopError(dpopErrorInvalidProof, "DPoP-bound token must be sent with the DPoP authorization scheme")
+		}
+		return nil
+	}
+	if jkt == "" {
+		return errordefs.New(errordefs.CDV_JWT_INVALID, "DPoP scheme requires a DPoP-bound token (cnf.jkt)", "")
+	}
+
+	proofs := r.Header.Values("DPoP")
+	if len(proofs) != 1 {
+		return dpopError(dpopErrorInvalidProof, "exactly one DPoP proof header is required")
+	}
+	now := time.Now()
+	proof, err := jwks.ValidateDPoPProof(proofs[0], r.Method, m.dpopRequestURI(r), tokenString, now)
+	if err != nil {
+		return dpopError(dpopErrorInvalidProof, err.Error())
+	}
+	if proof.Thumbprint != jkt {
+		return dpopError(dpopErrorInvalidProof, "DPoP proof key does not match the token's cnf.jkt")
+	}
+	if !m.validDPoPNonce(proof.Nonce, now) {
+		return dpopError(dpopErrorUseNonce, "DPoP proof must carry the nonce from the DPoP-Nonce response header")
+	}
+
+	// Each proof may only be used once while it is fresh
+	proofHash := fmt.Sprintf("%x", sha256.Sum256([]byte("dpop:"+jkt+":"+proof.JTI)))
+	err = m.s.MarkTokenUsed(r.Context(), proofHash, proof.IssuedAt.Add(jwks.DPoPProofMaxAge).UTC())
+	switch {
+	case errors.Is(err, storage.ErrConflict):
+		return dpopError(dpopErrorInvalidProof, "DPoP proof has already been used")
+	case err != nil:
+		slog.Error("failed to record DPoP proof ID", "error", err)
+		return errordefs.New(errordefs.CDV_INTERNAL, "failed to check DPoP proof replay", "")
+	}
+	return nil
+}
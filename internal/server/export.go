@@ -0,0 +1,190 @@
+// internal/server/export.go
+// Repository export: streams every record of the authenticated DID, paging through
+// storage internally so memory use stays bounded regardless of repository size.
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// DefaultExportPageSize is the default number of records fetched per storage page during export
+	DefaultExportPageSize = MaxListLimit
+
+	// exportRetryAfter is the Retry-After value (seconds) sent when exports are saturated
+	exportRetryAfter = "5"
+
+	// exportPageWriteTimeout bounds writing a single page, so long exports outlive the server
+	// WriteTimeout while a stalled client still gets disconnected
+	exportPageWriteTimeout = 30 * time.Second
+)
+
+// Export formats, selected with the format query parameter or the Accept header
+const (
+	exportFormatJSONL = "jsonl" // JSON Lines, one record per line
+	exportFormatCAR   = "car"   // CARv1 archive, one JSON block per record
+)
+
+// exportContentTypes maps export formats to their response media types
+var exportContentTypes = map[string]string{
+	exportFormatJSONL: "application/x-ndjson",
+	exportFormatCAR:   "application/vnd.ipld.car",
+}
+
+// negotiateExportFormat picks the export format. An explicit format parameter wins;
+// otherwise the first supported type in Accept is used, defaulting to JSON Lines.
+func negotiateExportFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		_, ok := exportContentTypes[format]
+		return format, ok
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for format, contentType := range exportContentTypes {
+			if mediaType == contentType {
+				return format, true
+			}
+		}
+	}
+	return exportFormatJSONL, true
+}
+
+// recordEncoder writes one exported record in the negotiated format
+type recordEncoder func(record model.Record) error
+
+// newRecordEncoder returns the encoder for a format, writing any preamble first
+func newRecordEncoder(w http.ResponseWriter, format string, manifest exportManifest) (recordEncoder, error) {
+	if format == exportFormatCAR {
+		car := &carWriter{w: w}
+		if err := car.WriteHeader(manifest); err != nil {
+			return nil, err
+		}
+		return func(record model.Record) error {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			_, err = car.WriteBlock(data)
+			return err
+		}, nil
+	}
+	enc := json.NewEncoder(w)
+	return func(record model.Record) error { return enc.Encode(record) }, nil
+}
+
+// WithExportPageSize sets how many records an export fetches per storage page (1-100)
+func WithExportPageSize(n int) Option {
+	return func(m *Mux) {
+		if n > 0 && n <= MaxListLimit {
+			m.exportPageSize = n
+		}
+	}
+}
+
+// WithMaxConcurrentExports limits how many exports may run at once per instance.
+// Zero or less means unlimited.
+func WithMaxConcurrentExports(n int) Option {
+	return func(m *Mux) {
+		if n > 0 {
+			m.exportSem = make(chan struct{}, n)
+		} else {
+			m.exportSem = nil
+		}
+	}
+}
+
+// handleExportRecords handles GET /v1/repo/export, streaming the authenticated DID's
+// records newest first as JSON Lines or a CAR archive
+func (m *Mux) handleExportRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleExportRecords")
+	defer span.End()
+	
+	did := ctx.Value(ContextKeyDID).(string)
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	collection := r.URL.Query().Get("collection")
+	span.SetAttributes(
+		attribute.String("did", did),
+		attribute.String("collection", collection),
+	)
+	
+	format, ok := negotiateExportFormat(r)
+	if !ok {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "format must be one of: jsonl, car", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("format", format))
+	
+	release, ok := tryAcquire(m.exportSem)
+	if !ok {
+		err := errordefs.New(errordefs.CDV_UNAVAILABLE, "too many exports in progress, retry later", correlationID)
+		failSpan(span, err)
+		w.Header().Set("Retry-After", exportRetryAfter)
+		m.writeErrorDef(w, err)
+		return
+	}
+	defer release()
+	m.metrics.ExportsInProgress.Inc()
+	defer m.metrics.ExportsInProgress.Dec()
+	
+	rc := http.NewResponseController(w)
+	var encode recordEncoder
+	// Exports are the authenticated owner's own repository, so private records are included
+	query := model.ListRecordsQuery{DID: did, Collection: collection, Limit: m.exportPageSize, IncludePrivate: true}
+	exported := 0
+	for {
+		pageCtx, pageSpan := startChildSpan(ctx, "storage.ListRecords")
+		page, err := m.s.ListRecords(pageCtx, query)
+		endChildSpan(pageSpan, err)
+		if err != nil {
+			// Once streaming has started the status is sent; the truncated body is the only signal
+			if encode == nil {
+				err := errordefs.New(errordefs.CDV_INTERNAL, "failed to export records", correlationID)
+				failSpan(span, err)
+				m.writeErrorDef(w, err)
+			}
+			slog.Error("export failed", "did", did, "exported", exported, "error", err, "correlationId", correlationID)
+			return
+		}
+		if encode == nil {
+			w.Header().Set("Content-Type", exportContentTypes[format])
+			w.WriteHeader(http.StatusOK)
+			manifest := exportManifest{DID: did, Collection: collection, ExportedAt: time.Now().UTC()}
+			if encode, err = newRecordEncoder(w, format, manifest); err != nil {
+				slog.Warn("export aborted by client", "did", did, "error", err, "correlationId", correlationID)
+				return
+			}
+		}
+		
+		_ = rc.SetWriteDeadline(time.Now().Add(exportPageWriteTimeout))
+		for _, record := range page.Records {
+			if err := encode(record); err != nil {
+				slog.Warn("export aborted by client", "did", did, "exported", exported, "error", err, "correlationId", correlationID)
+				return
+			}
+		}
+		exported += len(page.Records)
+		m.metrics.ExportRecordsTotal.Add(float64(len(page.Records)))
+		_ = rc.Flush()
+		
+		if page.NextCursor == "" || ctx.Err() != nil {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	span.SetAttributes(attribute.Int("exported", exported))
+}
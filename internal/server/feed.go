@@ -0,0 +1,251 @@
+// internal/server/feed.go
+// Following feed: resolves a DID's follow records and merges the followed DIDs' posts
+// into one newest-first timeline, paginated with a cursor over the merged order.
+//
+// Fan-out is bounded in two ways. Only the most recently followed DIDs up to the fan-out
+// limit are read, and the response is marked truncated when more exist. Each author
+// contributes at most the per-author cap per page; a page then ends at the oldest record
+// read from a capped author, so pages may be shorter than the limit but no post is skipped.
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	feedFollowCollection = "com.registryaccord.graph.follow" // Records whose subject is a followed DID
+	feedPostCollection   = "com.registryaccord.feed.post"    // Records merged into the feed
+
+	// DefaultFeedMaxFanout is the default number of followed DIDs read for one feed page
+	DefaultFeedMaxFanout = 1000
+)
+
+// WithFeedLimits bounds the following feed: maxFanout followed DIDs per request (zero
+// or less keeps the default) and maxPerAuthor records read per author per page (zero or
+// less means only the page limit applies).
+func WithFeedLimits(maxFanout, maxPerAuthor int) Option {
+	return func(m *Mux) {
+		if maxFanout > 0 {
+			m.feedMaxFanout = maxFanout
+		}
+		m.feedMaxPerAuthor = max(maxPerAuthor, 0)
+	}
+}
+
+// feedCursor is the position of the last record of a feed page in the merged order
+type feedCursor struct {
+	DID       string    // Feed owner the cursor was issued for
+	IndexedAt time.Time // Indexed time of the last record
+	URI       string    // URI of the last record, breaking ties in IndexedAt
+	IssuedAt  time.Time `json:",omitempty"` // When the cursor was issued, for expiry
+}
+
+// errFeedCursor is returned for cursors that are malformed or were issued for another DID
+var errFeedCursor = errors.New("invalid cursor")
+
+// errFeedCursorExpired is returned for cursors older than the cursor TTL
+var errFeedCursorExpired = errors.New("invalid cursor: expired; restart paging without a cursor")
+
+// encodeFeedCursor encodes a feed position as an opaque cursor
+func encodeFeedCursor(c feedCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// decodeFeedCursor decodes a cursor, requiring it to belong to did's feed and, unless
+// issuedAfter is zero, to have been issued after issuedAfter
+func decodeFeedCursor(s, did string, issuedAfter time.Time) (*feedCursor, error) {
+	b, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errFeedCursor
+	}
+	var c feedCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.DID != did {
+		return nil, errFeedCursor
+	}
+	if !issuedAfter.IsZero() && c.IssuedAt.Before(issuedAfter) {
+		return nil, errFeedCursorExpired
+	}
+	return &c, nil
+}
+
+// feedBefore reports whether a sorts before b in the feed: newest first, then by URI
+func feedBefore(a, b model.Record) bool {
+	if a.IndexedAt.Equal(b.IndexedAt) {
+		return a.URI < b.URI
+	}
+	return a.IndexedAt.After(b.IndexedAt)
+}
+
+// followedDIDs returns the distinct subjects of did's follow records, most recent first,
+// up to the fan-out limit, and whether follows beyond the limit were left out.
+// Private follows are only read when includePrivate is set.
+func (m *Mux) followedDIDs(ctx context.Context, did string, includePrivate bool) ([]string, bool, error) {
+	var dids []string
+	seen := make(map[string]bool)
+	query := model.ListRecordsQuery{DID: did, Collection: feedFollowCollection, Limit: MaxListLimit, IncludePrivate: includePrivate}
+	for {
+		result, err := m.s.ListRecords(ctx, query)
+		if err != nil {
+			return nil, false, err
+		}
+		for i, record := range result.Records {
+			subject, _ := record.Value["subject"].(string)
+			if subject == "" || subject == did || seen[subject] {
+				continue
+			}
+			seen[subject] = true
+			dids = append(dids, subject)
+			if len(dids) == m.feedMaxFanout {
+				// Remaining follows may only repeat subjects already read; report them anyway
+				return dids, i < len(result.Records)-1 || result.NextCursor != "", nil
+			}
+		}
+		if result.NextCursor == "" {
+			return dids, false, nil
+		}
+		query.Cursor = result.NextCursor
+	}
+}
+
+// authorPosts returns up to n of author's posts that come after cursor in feed order,
+// and whether the author has more
+func (m *Mux) authorPosts(ctx context.Context, author string, cursor *feedCursor, n int) ([]model.Record, bool, error) {
+	// One extra record tells whether more exist; records at the cursor's own timestamp
+	// that were already served are skipped, reading further pages if needed
+	query := model.ListRecordsQuery{DID: author, Collection: feedPostCollection, Limit: n + 1}
+	var after model.Record
+	if cursor != nil {
+		query.Until = cursor.IndexedAt
+		after = model.Record{IndexedAt: cursor.IndexedAt, URI: cursor.URI}
+	}
+	var posts []model.Record
+	for {
+		result, err := m.s.ListRecords(ctx, query)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, record := range result.Records {
+			if cursor == nil || feedBefore(after, record) {
+				posts = append(posts, record)
+			}
+		}
+		if len(posts) > n {
+			return posts[:n], true, nil
+		}
+		if result.NextCursor == "" {
+			return posts, false, nil
+		}
+		query.Cursor = result.NextCursor
+	}
+}
+
+// handleFollowingFeed handles GET /v1/feed/following
+func (m *Mux) handleFollowingFeed(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleFollowingFeed")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	did := r.URL.Query().Get("did")
+	if did == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "did is required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("did", did))
+	
+	limit := DefaultListLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, MaxListLimit)
+	}
+	
+	var cursor *feedCursor
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		payload, err := m.openCursor(s)
+		var c *feedCursor
+		if err == nil {
+			c, err = decodeFeedCursor(payload, did, m.cursorIssuedAfter())
+		}
+		if err != nil {
+			err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		cursor = c
+	}
+	
+	// The owner authenticated by the optional JWT also sees their private follows
+	callerDID, _ := ctx.Value(ContextKeyDID).(string)
+	followsCtx, followsSpan := startChildSpan(ctx, "feed.followedDIDs")
+	follows, truncated, err := m.followedDIDs(followsCtx, did, callerDID == did)
+	endChildSpan(followsSpan, err)
+	if err != nil {
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to read follows", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("follows", len(follows)), attribute.Bool("truncated", truncated))
+	
+	perAuthor := limit
+	if m.feedMaxPerAuthor > 0 {
+		perAuthor = min(perAuthor, m.feedMaxPerAuthor)
+	}
+	
+	// Read each author's next posts past the cursor. For an author with more posts, the
+	// last one read bounds the page: anything older may be preceded by unread posts.
+	var candidates []model.Record
+	var bound *model.Record
+	more := false
+	postsCtx, postsSpan := startChildSpan(ctx, "feed.ListPosts")
+	for _, author := range follows {
+		posts, authorMore, err := m.authorPosts(postsCtx, author, cursor, perAuthor)
+		if err != nil {
+			endChildSpan(postsSpan, err)
+			err := errordefs.New(errordefs.CDV_INTERNAL, fmt.Sprintf("failed to list posts of %s", author), correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		candidates = append(candidates, posts...)
+		if authorMore {
+			more = true
+			if last := posts[len(posts)-1]; bound == nil || feedBefore(last, *bound) {
+				bound = &last
+			}
+		}
+	}
+	endChildSpan(postsSpan, nil)
+	
+	sort.Slice(candidates, func(i, j int) bool { return feedBefore(candidates[i], candidates[j]) })
+	if bound != nil {
+		n := sort.Search(len(candidates), func(i int) bool { return feedBefore(*bound, candidates[i]) })
+		candidates = candidates[:n]
+	}
+	result := model.FeedResult{Records: []model.Record{}, Truncated: truncated}
+	if len(candidates) > limit {
+		candidates, more = candidates[:limit], true
+	}
+	result.Records = append(result.Records, candidates...)
+	if more && len(candidates) > 0 {
+		last := candidates[len(candidates)-1]
+		result.NextCursor = m.signCursor(encodeFeedCursor(feedCursor{DID: did, IndexedAt: last.IndexedAt, URI: last.URI, IssuedAt: time.Now().UTC()}))
+	}
+	
+	m.writeSuccess(w, http.StatusOK, result)
+}
@@ -0,0 +1,68 @@
+// internal/server/filters.go
+// Value filters for listRecords and countRecords: equality on allowlisted top-level
+// keys of a record's value, given as value.<key>=<value> query parameters. Only keys
+// that identify related records are filterable, so every filter is served by the
+// GIN index on records.value rather than a scan.
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// valueFilterPrefix marks query parameters that filter on record values
+const valueFilterPrefix = "value."
+
+// filterableValueKeys lists the value keys that may be filtered on, per collection
+var filterableValueKeys = map[string][]string{
+	"com.registryaccord.feed.post":       {"authorDid"},
+	"com.registryaccord.graph.follow":    {"subject"},
+	"com.registryaccord.feed.like":       {"subject"},
+	"com.registryaccord.feed.comment":    {"subject"},
+	"com.registryaccord.feed.repost":     {"subject"},
+	"com.registryaccord.moderation.flag": {"subject", "reason"},
+	"com.registryaccord.media.asset":     {"mimeType", "checksum"},
+}
+
+// parseValueFilters collects the value.<key> parameters of a query into key/value
+// filters, rejecting them unless collection is set and allows every key
+func parseValueFilters(params url.Values, collection string) (map[string]string, error) {
+	var filters map[string]string
+	for param, values := range params {
+		key, ok := strings.CutPrefix(param, valueFilterPrefix)
+		if !ok {
+			continue
+		}
+		if collection == "" {
+			return nil, fmt.Errorf("collection is required to filter on %s", param)
+		}
+		if !slices.Contains(filterableValueKeys[collection], key) {
+			return nil, fmt.Errorf("%s is not filterable in %s; filterable keys: %s", param, collection, filterableKeysList(collection))
+		}
+		if len(values) != 1 {
+			return nil, fmt.Errorf("%s must be given once", param)
+		}
+		if filters == nil {
+			filters = make(map[string]string)
+		}
+		filters[key] = values[0]
+	}
+	return filters, nil
+}
+
+// filterableKeysList names the filterable parameters of a collection for error messages
+func filterableKeysList(collection string) string {
+	keys := filterableValueKeys[collection]
+	if len(keys) == 0 {
+		return "none"
+	}
+	params := make([]string, len(keys))
+	for i, key := range keys {
+		params[i] = valueFilterPrefix + key
+	}
+	sort.Strings(params)
+	return strings.Join(params, ", ")
+}
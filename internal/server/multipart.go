@@ -0,0 +1,320 @@
+// internal/server/multipart.go
+// Multipart media uploads for objects too large for a single presigned PUT. The client
+// uploads each part to its presigned URL, then completes the upload with the part ETags
+// and finalizes the asset as usual; abandoned uploads are aborted to free their parts.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/media"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// S3 multipart limits
+const (
+	minMultipartPartSize     = 5 << 20  // Smallest part S3 accepts, except for the last part
+	maxMultipartPartSize     = 5 << 30  // Largest part S3 accepts
+	maxMultipartParts        = 10000    // Most parts an upload may have
+	defaultMultipartPartSize = 64 << 20 // Part size used when the client does not choose one
+	multipartURLTTL          = time.Hour // How long presigned part URLs stay valid
+)
+
+// multipartPartSize returns the part size for an upload of size bytes. Without a requested
+// size the default is used, grown if needed to stay within the part limit.
+func multipartPartSize(size, requested int64) (int64, error) {
+	if requested == 0 {
+		return max(defaultMultipartPartSize, (size+maxMultipartParts-1)/maxMultipartParts), nil
+	}
+	if requested < minMultipartPartSize || requested > maxMultipartPartSize {
+		return 0, fmt.Errorf("partSize must be between %d and %d bytes", minMultipartPartSize, maxMultipartPartSize)
+	}
+	if (size+requested-1)/requested > maxMultipartParts {
+		return 0, fmt.Errorf("partSize is too small: an upload may have at most %d parts", maxMultipartParts)
+	}
+	return requested, nil
+}
+
+// handleUploadInitMultipart handles POST /v1/media/uploadInitMultipart
+func (m *Mux) handleUploadInitMultipart(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleUploadInitMultipart")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+
+	var req model.UploadInitMultipartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errDef := m.jsonBodyError(err, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("did", req.DID),
+		attribute.String("mimeType", req.MimeType),
+		attribute.Int64("size", req.Size),
+		attribute.Int64("partSize", req.PartSize),
+	)
+
+	if m.mediaClient == nil {
+		errDef := errordefs.New(errordefs.CDV_NOT_IMPLEMENTED, "multipart uploads require S3 storage", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	asset, errDef := m.newPendingAsset(ctx, model.UploadInitRequest{
+		DID: req.DID, MimeType: req.MimeType, Size: req.Size, SHA256: req.SHA256, Filename: req.Filename,
+	})
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	partSize, err := multipartPartSize(req.Size, req.PartSize)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_VALIDATION, err.Error(), correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	createStart := time.Now()
+	createCtx, createSpan := startChildSpan(ctx, "media.CreateMultipartUpload")
+	asset.UploadID, err = m.mediaClient.CreateMultipartUpload(createCtx, asset.ObjectKey, asset.MimeType)
+	endChildSpan(createSpan, err)
+	m.observeMediaOperation("create_multipart", createStart, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to start multipart upload", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.CreateMediaAsset")
+	err = m.s.CreateMediaAsset(assetCtx, asset)
+	endChildSpan(assetSpan, err)
+	if err != nil {
+		m.abortOrphanedUpload(ctx, asset)
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to create media asset", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	data := model.UploadInitMultipartData{
+		AssetID:   asset.AssetID,
+		UploadID:  asset.UploadID,
+		PartSize:  partSize,
+		ExpiresAt: time.Now().Add(multipartURLTTL),
+	}
+	presignStart := time.Now()
+	presignCtx, presignSpan := startChildSpan(ctx, "media.GeneratePartUploadURL")
+	for partNumber := int32(1); int64(partNumber-1)*partSize < req.Size; partNumber++ {
+		var uploadURL string
+		uploadURL, err = m.mediaClient.GeneratePartUploadURL(presignCtx, asset.ObjectKey, asset.UploadID, partNumber, multipartURLTTL)
+		if err != nil {
+			break
+		}
+		data.Parts = append(data.Parts, model.MultipartPart{PartNumber: partNumber, UploadURL: uploadURL})
+	}
+	endChildSpan(presignSpan, err)
+	m.observeMediaOperation("presign_upload_parts", presignStart, err)
+	if err != nil {
+		// The asset would otherwise count against the pending upload limit until cleaned up
+		m.abortOrphanedUpload(ctx, asset)
+		deleteCtx, deleteSpan := startChildSpan(ctx, "storage.DeleteMediaAsset")
+		deleteErr := m.s.DeleteMediaAsset(deleteCtx, asset.AssetID)
+		endChildSpan(deleteSpan, deleteErr)
+		if deleteErr != nil {
+			slog.Warn("failed to delete media asset of failed multipart upload", "assetId", asset.AssetID, "error", deleteErr)
+		}
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to generate part upload URLs", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(attribute.Int("parts", len(data.Parts)))
+
+	m.publishMediaUploadInitiated(ctx, asset)
+	m.writeSuccess(w, http.StatusOK, data)
+}
+
+// abortOrphanedUpload aborts a multipart upload that has no asset to track it; failures
+// are only logged, leaving the parts to the bucket's lifecycle rules
+func (m *Mux) abortOrphanedUpload(ctx context.Context, asset model.MediaAsset) {
+	abortCtx, abortSpan := startChildSpan(ctx, "media.AbortMultipartUpload")
+	err := m.mediaClient.AbortMultipartUpload(abortCtx, asset.ObjectKey, asset.UploadID)
+	endChildSpan(abortSpan, err)
+	if err != nil {
+		slog.Warn("failed to abort orphaned multipart upload", "assetId", asset.AssetID, "error", err)
+	}
+}
+
+// multipartAsset loads the caller's asset for a multipart request and checks that the
+// upload is still in progress
+func (m *Mux) multipartAsset(ctx context.Context, assetID, uploadID string) (*model.MediaAsset, *errordefs.Error) {
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	if assetID == "" || uploadID == "" {
+		return nil, errordefs.New(errordefs.CDV_VALIDATION, "assetId and uploadId are required", correlationID)
+	}
+	if m.mediaClient == nil {
+		return nil, errordefs.New(errordefs.CDV_NOT_IMPLEMENTED, "multipart uploads require S3 storage", correlationID)
+	}
+
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.GetMediaAsset")
+	asset, err := m.s.GetMediaAsset(assetCtx, assetID)
+	endChildSpan(assetSpan, err)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, errordefs.New(errordefs.CDV_NOT_FOUND, "asset not found", correlationID)
+		}
+		return nil, errordefs.New(errordefs.CDV_INTERNAL, "failed to get media asset", correlationID)
+	}
+	if asset.DID != ctx.Value(ContextKeyDID).(string) {
+		return nil, errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+	}
+	if asset.UploadID == "" || asset.UploadID != uploadID {
+		return nil, errordefs.New(errordefs.CDV_CONFLICT, "no multipart upload in progress with this uploadId", correlationID)
+	}
+	return asset, nil
+}
+
+// handleCompleteMultipart handles POST /v1/media/completeMultipart
+func (m *Mux) handleCompleteMultipart(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleCompleteMultipart")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+
+	var req model.CompleteMultipartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errDef := m.jsonBodyError(err, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(attribute.String("assetId", req.AssetID), attribute.Int("parts", len(req.Parts)))
+
+	asset, errDef := m.multipartAsset(ctx, req.AssetID, req.UploadID)
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	// S3 needs the parts in ascending order; each part may appear only once
+	parts := make([]media.CompletedPart, 0, len(req.Parts))
+	for _, part := range req.Parts {
+		if part.PartNumber < 1 || part.PartNumber > maxMultipartParts || part.ETag == "" {
+			errDef := errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("parts need a partNumber between 1 and %d and an etag", maxMultipartParts), correlationID)
+			failSpan(span, errDef)
+			m.writeErrorDef(w, errDef)
+			return
+		}
+		parts = append(parts, media.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	if len(parts) == 0 {
+		errDef := errordefs.New(errordefs.CDV_VALIDATION, "parts are required", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	slices.SortFunc(parts, func(a, b media.CompletedPart) int { return int(a.PartNumber - b.PartNumber) })
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber == parts[i-1].PartNumber {
+			errDef := errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("part %d is listed more than once", parts[i].PartNumber), correlationID)
+			failSpan(span, errDef)
+			m.writeErrorDef(w, errDef)
+			return
+		}
+	}
+
+	completeStart := time.Now()
+	completeCtx, completeSpan := startChildSpan(ctx, "media.CompleteMultipartUpload")
+	err := m.mediaClient.CompleteMultipartUpload(completeCtx, asset.ObjectKey, asset.UploadID, parts)
+	endChildSpan(completeSpan, err)
+	m.observeMediaOperation("complete_multipart", completeStart, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to complete multipart upload", correlationID)
+		if errors.Is(err, media.ErrInvalidUpload) {
+			errDef = errordefs.New(errordefs.CDV_VALIDATION, err.Error(), correlationID)
+		}
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	// The object now exists; finalize verifies its checksum
+	asset.UploadID = ""
+	updateCtx, updateSpan := startChildSpan(ctx, "storage.UpdateMediaAsset")
+	err = m.s.UpdateMediaAsset(updateCtx, *asset)
+	endChildSpan(updateSpan, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to update media asset", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	m.writeSuccess(w, http.StatusOK, asset)
+}
+
+// handleAbortMultipart handles POST /v1/media/abortMultipart
+func (m *Mux) handleAbortMultipart(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleAbortMultipart")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+
+	var req model.AbortMultipartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errDef := m.jsonBodyError(err, correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(attribute.String("assetId", req.AssetID))
+
+	asset, errDef := m.multipartAsset(ctx, req.AssetID, req.UploadID)
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	// An upload S3 no longer knows has no parts left to free
+	abortStart := time.Now()
+	abortCtx, abortSpan := startChildSpan(ctx, "media.AbortMultipartUpload")
+	err := m.mediaClient.AbortMultipartUpload(abortCtx, asset.ObjectKey, asset.UploadID)
+	endChildSpan(abortSpan, err)
+	m.observeMediaOperation("abort_multipart", abortStart, err)
+	if err != nil && !errors.Is(err, media.ErrInvalidUpload) {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to abort multipart upload", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	deleteCtx, deleteSpan := startChildSpan(ctx, "storage.DeleteMediaAsset")
+	err = m.s.DeleteMediaAsset(deleteCtx, asset.AssetID)
+	endChildSpan(deleteSpan, err)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to delete media asset", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	m.writeSuccess(w, http.StatusOK, model.AbortMultipartData{AssetID: asset.AssetID})
+}
@@ -12,8 +12,13 @@
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -25,14 +30,20 @@
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/media"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/ratelimit"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/schema"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ContextKey is used for context values to avoid collisions
@@ -42,7 +53,7 @@
 const (
 	// Context keys for storing request-scoped values
 	ContextKeyDID ContextKey = "did"           // Stores the DID from JWT
-	ContextKeyCorrelationID ContextKey = "correlationId" // Unique ID for request tracking
+	ContextKeyCorrelationID = telemetry.CorrelationIDKey // Unique ID for request tracking, shared with storage, media and events
 
 	// Default limits for list operations
 	DefaultListLimit = 25  // Default number of records to return
@@ -54,7 +65,18 @@
 // such as storage, event publishing, and identity validation.
 type Mux struct {
 	mux *http.ServeMux          // HTTP request multiplexer
+	allowedMethods map[string][]string // Methods registered per route pattern, for 405 Allow headers
+	optionalAuthPaths map[string]bool // Paths that authenticate the caller when credentials are sent
+	requiredAuthRoutes map[string]bool // Routes ("METHOD pattern", as in Request.Pattern) that require a JWT
+	requireAuthReads bool // Whether the optionally authenticated reads require a JWT
+	anonReadLimit rateLimitSetting // Per-IP limit on unauthenticated reads (zero rps means unlimited)
+	rateLimit rateLimitSetting // Per-DID limit on authenticated requests (zero rps means unlimited)
+	rateLimitRedis redis.Scripter // Keeps rate-limit buckets in Redis so replicas share them (nil keeps them in process)
+	anonReadLimiter ratelimit.Allower // Limiter enforcing anonReadLimit (nil means unlimited)
+	rateLimiter ratelimit.Allower // Limiter enforcing rateLimit (nil means unlimited)
+	trustedProxies []netip.Prefix // Proxies whose X-Forwarded-For header is trusted
 	s   storage.Store           // Storage interface for records and media
+	idempotency storage.IdempotencyStore // Cached responses of idempotent requests (defaults to s)
 	p   event.Publisher         // Event publisher for streaming updates
 	id  *identity.Client        // Identity client for DID validation
 	jwksClient *jwks.Client     // JWKS client for JWT validation
@@ -64,15 +86,43 @@ type Mux struct {
 	mediaClient *media.S3Client // S3 client for media storage operations
 	metrics     *metrics.Metrics // Metrics for monitoring
 	
-	// Media limits
-	maxMediaSize int64      // Maximum media size in bytes
-	allowedMimeTypes []string // Allowed MIME types for media uploads
+	// Reloadable policy: media limits, CORS origins, deprecated schema handling
+	policy *PolicyHolder
 	
-	// Schema policy
-	rejectDeprecatedSchemas bool // Whether to reject deprecated schemas
+	// Request limits
+	allowedContentTypes []string // Accepted Content-Type media types for request bodies
+	maxRequestBody int64 // Maximum body size of mutating requests in bytes
+	verifySem chan struct{} // Bounds concurrent media verifications (nil means unlimited)
+	maxPendingUploads int64 // Maximum unfinalized uploads per DID (0 means unlimited)
+	feedMaxFanout int // Followed DIDs read per following feed request
+	feedMaxPerAuthor int // Records read per author per feed page (0 means the page limit)
+	maxQueryWindow time.Duration // Maximum since/until span of a listRecords query without a cursor (0 means unlimited)
+	cursorTTL time.Duration // How long pagination cursors stay valid (0 means no expiry)
+	cursorKey []byte        // HMAC key cursors are signed with (nil leaves them unsigned)
 	
-	// CORS configuration
-	corsAllowedOrigins []string // Allowed origins for CORS (empty means deny all)
+	// Export limits
+	exportPageSize int          // Records fetched per storage page during export
+	exportSem      chan struct{} // Bounds concurrent exports (nil means unlimited)
+	
+	// Schema resolution
+	resolver *schema.Resolver // Schema resolver, probed by readiness when enabled
+	checkSpecsReadiness bool  // Whether readiness probes the specs repository
+	unvalidatedCollections []string // Collections (or "prefix.*" patterns) stored without schema validation
+	allowUnknownCollections bool // Whether collections without a schema are stored instead of rejected
+	readinessErrorDetail bool // Whether readiness failures include each dependency's error
+	
+	// Authentication limits
+	maxJWTLength int // Maximum accepted bearer token length in bytes
+	trustedAudiences []string // Additional JWT audiences accepted besides jwtAudience
+	replayProtection bool // Whether JWT IDs (jti) are tracked and reuse is rejected
+	authCookieName string // Cookie carrying the JWT when no Authorization header is sent (empty disables)
+	dpopNonceKey []byte // HMAC key for DPoP nonces (nil disables DPoP)
+	adminDIDs []string // DIDs allowed to call /v1/admin/ endpoints
+	auditLogger *slog.Logger // Receives audit entries for admin operations (nil uses the default logger)
+	
+	// API description
+	routes      []apiRoute // Registered API routes, used to generate the OpenAPI document
+	openAPISpec []byte     // Serialized OpenAPI document served at /openapi.json
 }
 
 // NewMux creates a new HTTP mux with all CDV endpoints.
@@ -85,7 +135,230 @@ type Mux struct {
 //   - jwtAudience: Expected JWT audience for validation
 //   - specsURL: URL to the specs repository for schema resolution
 //   - rejectDeprecatedSchemas: Whether to reject deprecated schemas
-func NewMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer, jwtAudience string, maxMediaSize int64, allowedMimeTypes []string, jwksClient *jwks.Client, specsURL string, rejectDeprecatedSchemas bool) *http.ServeMux {
+//   - opts: Optional settings applied after defaults
+func NewMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer, jwtAudience string, maxMediaSize int64, allowedMimeTypes []string, jwksClient *jwks.Client, specsURL string, rejectDeprecatedSchemas bool, opts ...Option) *http.ServeMux {
+	return newMux(s, p, id, jwtIssuer, jwtAudience, maxMediaSize, allowedMimeTypes, jwksClient, specsURL, rejectDeprecatedSchemas, opts...).mux
+}
+
+// Option configures optional Mux behavior
+type Option func(*Mux)
+
+// WithSpecsReadinessProbe enables probing the specs repository from /readyz.
+// Failures are reported as degraded rather than not ready, since validation
+// falls back to the inline schemas.
+func WithSpecsReadinessProbe(enabled bool) Option {
+	return func(m *Mux) {
+		m.checkSpecsReadiness = enabled
+	}
+}
+
+// WithUnvalidatedCollections accepts records in the given collections with only
+// structural validation (a JSON object) instead of schema validation. A pattern ending in
+// ".*" matches every collection under that prefix, e.g. "app.bsky.*". Such records are
+// stored with schema version "unvalidated".
+func WithUnvalidatedCollections(collections ...string) Option {
+	return func(m *Mux) {
+		m.unvalidatedCollections = append(m.unvalidatedCollections, collections...)
+	}
+}
+
+// WithAllowUnknownCollections stores records for collections that have no schema with
+// only structural validation (a JSON object) and schema version "unknown", instead of
+// rejecting them. Collections with a schema are still validated strictly.
+func WithAllowUnknownCollections(enabled bool) Option {
+	return func(m *Mux) {
+		m.allowUnknownCollections = enabled
+	}
+}
+
+// WithReadinessErrorDetail sets whether the /readyz failure body includes each
+// dependency's error message. The checked dependencies and their statuses are always
+// listed; disable this when the probe is reachable by untrusted clients.
+func WithReadinessErrorDetail(enabled bool) Option {
+	return func(m *Mux) {
+		m.readinessErrorDetail = enabled
+	}
+}
+
+// DefaultMaxJWTLength is the default maximum accepted bearer token length in bytes
+const DefaultMaxJWTLength = 8192
+
+// WithMaxJWTLength sets the maximum accepted bearer token length in bytes.
+// Longer tokens are rejected before parsing.
+func WithMaxJWTLength(n int) Option {
+	return func(m *Mux) {
+		if n > 0 {
+			m.maxJWTLength = n
+		}
+	}
+}
+
+// WithTrustedAudiences accepts tokens issued for any of the given audiences
+// in addition to the primary JWT audience
+func WithTrustedAudiences(audiences ...string) Option {
+	return func(m *Mux) {
+		m.trustedAudiences = append(m.trustedAudiences, audiences...)
+	}
+}
+
+// WithReplayProtection enables JWT replay detection: every token must carry a jti,
+// and a jti seen before is rejected until the token expires. Used IDs are kept in
+// the store so detection works across replicas sharing a database.
+func WithReplayProtection(enabled bool) Option {
+	return func(m *Mux) {
+		m.replayProtection = enabled
+	}
+}
+
+// WithRequireAuthReads requires a valid JWT on the public read endpoints
+// (getRecord, listRecords, getRecords, countRecords, describeRepo, backlinks, feed/following),
+// for deployments that keep all data private
+func WithRequireAuthReads(enabled bool) Option {
+	return func(m *Mux) {
+		m.requireAuthReads = enabled
+	}
+}
+
+// WithAnonReadRateLimit limits unauthenticated requests to the public read endpoints
+// to rps per second per client IP, with bursts of up to burst requests (a burst of
+// zero allows one second's worth). Authenticated reads and writes are not affected.
+// Zero rps means unlimited.
+func WithAnonReadRateLimit(rps float64, burst int) Option {
+	return func(m *Mux) {
+		m.anonReadLimit = rateLimitSetting{rps: rps, burst: burst}
+	}
+}
+
+// WithRateLimit limits authenticated requests to rps per second per DID, with bursts
+// of up to burst requests (a burst of zero allows one second's worth). Unauthenticated
+// public reads share the limit per client IP unless WithAnonReadRateLimit sets their
+// own. Zero rps means unlimited.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(m *Mux) {
+		m.rateLimit = rateLimitSetting{rps: rps, burst: burst}
+	}
+}
+
+// WithRedisRateLimits keeps the buckets of WithRateLimit and WithAnonReadRateLimit in
+// Redis, so replicas sharing the server enforce each limit together instead of one each.
+// Without it every replica limits callers on its own.
+func WithRedisRateLimits(client redis.Scripter) Option {
+	return func(m *Mux) {
+		m.rateLimitRedis = client
+	}
+}
+
+// rateLimitSetting is a token-bucket limit configured through the options
+type rateLimitSetting struct {
+	rps   float64 // Requests per second (zero means unlimited)
+	burst int     // Bucket capacity (zero allows one second's worth)
+}
+
+// newLimiter builds the limiter for setting, in Redis under prefix when rateLimitRedis is
+// set and in process otherwise; nil when the setting is unlimited
+func (m *Mux) newLimiter(prefix string, setting rateLimitSetting) ratelimit.Allower {
+	if setting.rps <= 0 {
+		return nil
+	}
+	burst := setting.burst
+	if burst <= 0 {
+		burst = int(math.Ceil(setting.rps))
+	}
+	if m.rateLimitRedis != nil {
+		return ratelimit.NewRedis(m.rateLimitRedis, prefix, setting.rps, burst)
+	}
+	return ratelimit.New(setting.rps, burst)
+}
+
+// WithAllowedContentTypes sets the media types accepted in the Content-Type of
+// request bodies. The default accepts only application/json.
+func WithAllowedContentTypes(types ...string) Option {
+	return func(m *Mux) {
+		if len(types) > 0 {
+			m.allowedContentTypes = types
+		}
+	}
+}
+
+// WithCursorTTL makes pagination cursors expire d after they were issued; an expired
+// cursor is rejected with CDV_CURSOR_INVALID so the client restarts paging. Zero
+// means cursors do not expire.
+func WithCursorTTL(d time.Duration) Option {
+	return func(m *Mux) {
+		m.cursorTTL = max(d, 0)
+	}
+}
+
+// cursorIssuedAfter returns the oldest issue time of a cursor accepted now, or the
+// zero time when cursors do not expire
+func (m *Mux) cursorIssuedAfter() time.Time {
+	if m.cursorTTL == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-m.cursorTTL)
+}
+
+// DefaultMaxRequestBody is the default maximum body size of mutating requests in bytes
+const DefaultMaxRequestBody = 1 << 20
+
+// WithMaxRequestBody limits the JSON body of POST, PUT and DELETE requests to n bytes;
+// larger bodies are rejected with CDV_BODY_TOO_LARGE. Zero or less keeps the default.
+// Media content is uploaded to object storage directly and is not affected.
+func WithMaxRequestBody(n int64) Option {
+	return func(m *Mux) {
+		if n > 0 {
+			m.maxRequestBody = n
+		}
+	}
+}
+
+// WithMaxQueryWindow limits the time range a listRecords query may span when it
+// has no cursor. An open-ended range is measured up to now. Zero means unlimited.
+func WithMaxQueryWindow(d time.Duration) Option {
+	return func(m *Mux) {
+		m.maxQueryWindow = d
+	}
+}
+
+// WithMaxPendingUploads limits how many unfinalized media assets a DID may have;
+// further uploadInit calls get CDV_QUOTA_EXCEEDED. Zero or less means unlimited.
+func WithMaxPendingUploads(n int) Option {
+	return func(m *Mux) {
+		m.maxPendingUploads = int64(max(n, 0))
+	}
+}
+
+// WithMaxConcurrentVerify limits how many finalize calls may download and hash
+// objects at once. Zero or less means unlimited.
+func WithMaxConcurrentVerify(n int) Option {
+	return func(m *Mux) {
+		if n > 0 {
+			m.verifySem = make(chan struct{}, n)
+		} else {
+			m.verifySem = nil
+		}
+	}
+}
+
+// WithAuthCookie enables reading the JWT from the named cookie when a request has no
+// Authorization header, for browser clients that cannot set headers on navigations.
+// CORS responses to explicitly allowed origins then permit credentials.
+func WithAuthCookie(name string) Option {
+	return func(m *Mux) {
+		m.authCookieName = name
+	}
+}
+
+// WithIdempotencyStore keeps idempotent responses in store instead of the primary Store,
+// e.g. Redis shared by all replicas.
+func WithIdempotencyStore(store storage.IdempotencyStore) Option {
+	return func(m *Mux) {
+		m.idempotency = store
+	}
+}
+
+// newMux builds the Mux and registers all handlers; see NewMux for parameters
+func newMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer, jwtAudience string, maxMediaSize int64, allowedMimeTypes []string, jwksClient *jwks.Client, specsURL string, rejectDeprecatedSchemas bool, opts ...Option) *Mux {
 	// Initialize schema validator
 	validator, err := schema.NewValidator()
 	if err != nil {
@@ -99,9 +372,9 @@ func NewMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer,
 		mediaClient, err = media.NewS3Client(
 			os.Getenv("CDV_S3_ENDPOINT"),
 			os.Getenv("CDV_S3_REGION"),
+			os.Getenv("CDV_S3_BUCKET"),
 			os.Getenv("CDV_S3_ACCESS_KEY_ID"),
 			os.Getenv("CDV_S3_SECRET_ACCESS_KEY"),
-			os.Getenv("CDV_S3_BUCKET"),
 		)
 		if err != nil {
 			slog.Error("failed to initialize S3 client", "error", err)
@@ -121,6 +394,7 @@ func NewMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer,
 	m := &Mux{
 		mux:         http.NewServeMux(),
 		s:           s,
+		idempotency: storage.StoreIdempotency(s),
 		p:           p,
 		id:          id,
 		jwksClient:  jwksClient,
@@ -129,35 +403,286 @@ func NewMux(s storage.Store, p event.Publisher, id *identity.Client, jwtIssuer,
 		validator:   validator,
 		mediaClient: mediaClient,
 		metrics:     metrics.NewMetrics(),
-		maxMediaSize: maxMediaSize,
-		allowedMimeTypes: allowedMimeTypes,
-		rejectDeprecatedSchemas: rejectDeprecatedSchemas,
+		policy: NewPolicyHolder(Policy{
+			MaxMediaSize:            maxMediaSize,
+			AllowedMimeTypes:        allowedMimeTypes,
+			RejectDeprecatedSchemas: rejectDeprecatedSchemas,
+		}),
+		resolver:    resolver,
+		readinessErrorDetail: true,
+		maxJWTLength: DefaultMaxJWTLength,
+		allowedContentTypes: []string{"application/json"},
+		maxRequestBody: DefaultMaxRequestBody,
+		exportPageSize: DefaultExportPageSize,
+		feedMaxFanout: DefaultFeedMaxFanout,
+		allowedMethods: make(map[string][]string),
+		optionalAuthPaths: make(map[string]bool),
+		requiredAuthRoutes: make(map[string]bool),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	// Build the limiters once every option is known, so their order does not matter
+	m.anonReadLimiter = m.newLimiter("ratelimit:anon:", m.anonReadLimit)
+	m.rateLimiter = m.newLimiter("ratelimit:", m.rateLimit)
 
 	// Register health endpoints
 	m.mux.HandleFunc("/healthz", m.handleHealthz)
 	m.mux.HandleFunc("/readyz", m.handleReadyz)
-	m.mux.Handle("/metrics", promhttp.Handler())
+	// OpenMetrics exposition is required for exemplars to be scraped
+	m.mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})))
 
 	// Register Phase 1 CDV endpoints with appropriate middleware
-	m.mux.HandleFunc("/v1/repo/record", m.method("POST", m.withMiddleware(m.handleCreateRecord)))
-	m.mux.HandleFunc("/v1/repo/listRecords", m.method("GET", m.withMiddleware(m.handleListRecords)))
-	m.mux.HandleFunc("/v1/media/uploadInit", m.method("POST", m.withMiddleware(m.handleUploadInit)))
-	m.mux.HandleFunc("/v1/media/finalize", m.method("POST", m.withMiddleware(m.handleFinalize)))
-	m.mux.HandleFunc("/v1/media/", m.method("GET", m.withMiddleware(m.handleGetMediaMeta)))
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/repo/record", Auth: true,
+		Summary:  "Create a record in the authenticated DID's repository",
+		Request:  model.CreateRecordRequest{},
+		Response: model.CreateRecordData{},
+	}, m.handleCreateRecord)
+	m.handleAPI(apiRoute{
+		Method: "PUT", Pattern: "/v1/repo/record", Auth: true,
+		Summary:  "Replace the value of a record owned by the authenticated DID, keeping its URI",
+		Request:  model.UpdateRecordRequest{},
+		Response: model.CreateRecordData{},
+	}, m.handleUpdateRecord)
+	m.handleAPI(apiRoute{
+		Method: "DELETE", Pattern: "/v1/repo/record", Auth: true,
+		Summary: "Delete a record owned by the authenticated DID; the URI may also be sent as a JSON body",
+		Params: []apiParam{
+			{Name: "uri", In: "query", Type: "string", Desc: "URI of the record to delete"},
+		},
+		Response: model.DeleteRecordData{},
+	}, m.handleDeleteRecord)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/listRecords", OptionalAuth: true,
+		Summary: "List records for a DID with cursor pagination; private records are included when the caller is authenticated as the DID. Records of a collection can be filtered by equality on its filterable value keys with value.<key>=<value> parameters (e.g. value.subject for likes and follows)",
+		Params: []apiParam{
+			{Name: "did", In: "query", Type: "string", Required: true, Desc: "Repository owner DID"},
+			{Name: "collection", In: "query", Type: "string", Desc: "Collection NSID filter"},
+			{Name: "limit", In: "query", Type: "integer", Desc: "Maximum records to return (1-100, default 25)"},
+			{Name: "cursor", In: "query", Type: "string", Desc: "Pagination cursor from a previous response"},
+			{Name: "since", In: "query", Type: "string", Desc: "Only records indexed after this RFC 3339 time"},
+			{Name: "until", In: "query", Type: "string", Desc: "Only records indexed before this RFC 3339 time"},
+			{Name: "fields", In: "query", Type: "string", Desc: "Comma-separated top-level value keys to return; other keys are omitted"},
+		},
+		Response: model.ListRecordsResult{},
+	}, m.handleListRecords)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/getRecord", OptionalAuth: true,
+		Summary: "Get a record by URI; private records are returned only to their owner",
+		Params: []apiParam{
+			{Name: "uri", In: "query", Type: "string", Required: true, Desc: "Record URI (at://did/collection/rkey)"},
+		},
+		Response: model.Record{},
+	}, m.handleGetRecord)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/repo/getRecords", OptionalAuth: true,
+		Summary: fmt.Sprintf("Get up to %d records by URI; private records are returned only to their owner, and missing ones are listed as not found", maxGetRecordsBatch),
+		Request:  model.GetRecordsRequest{},
+		Response: model.GetRecordsData{},
+	}, m.handleGetRecords)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/countRecords", OptionalAuth: true,
+		Summary: "Count a DID's records matching the listRecords filters, including value.<key> filters; private records are counted when the caller is authenticated as the DID",
+		Params: []apiParam{
+			{Name: "did", In: "query", Type: "string", Required: true, Desc: "Repository owner DID"},
+			{Name: "collection", In: "query", Type: "string", Desc: "Collection NSID filter"},
+			{Name: "since", In: "query", Type: "string", Desc: "Only records indexed after this RFC 3339 time"},
+			{Name: "until", In: "query", Type: "string", Desc: "Only records indexed before this RFC 3339 time"},
+		},
+		Response: model.CountRecordsData{},
+	}, m.handleCountRecords)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/describeRepo", OptionalAuth: true,
+		Summary: "Summarize an account: its creation time and per-collection record counts with the schema versions in use; private records are counted when the caller is authenticated as the DID",
+		Params: []apiParam{
+			{Name: "did", In: "query", Type: "string", Required: true, Desc: "Repository owner DID"},
+		},
+		Response: model.DescribeRepoData{},
+	}, m.handleDescribeRepo)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/backlinks", OptionalAuth: true,
+		Summary: "List the public records of a collection, across all DIDs, whose value.subject is the given URI or DID (e.g. who liked a post), newest first with cursor pagination",
+		Params: []apiParam{
+			{Name: "subject", In: "query", Type: "string", Required: true, Desc: "URI or DID the records refer to"},
+			{Name: "collection", In: "query", Type: "string", Required: true, Desc: "Collection NSID of the referring records"},
+			{Name: "limit", In: "query", Type: "integer", Desc: "Maximum records to return (1-100, default 25)"},
+			{Name: "cursor", In: "query", Type: "string", Desc: "Pagination cursor from a previous response"},
+		},
+		Response: model.ListRecordsResult{},
+	}, m.handleBacklinks)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/opLog", Auth: true,
+		Summary: "List the authenticated DID's operation log entries in sequence order, with cursor pagination, to reconcile local state against the server",
+		Params: []apiParam{
+			{Name: "did", In: "query", Type: "string", Desc: "DID whose operations are listed; must be the authenticated DID (default)"},
+			{Name: "since", In: "query", Type: "string", Desc: "Only operations at or after this RFC 3339 time"},
+			{Name: "until", In: "query", Type: "string", Desc: "Only operations at or before this RFC 3339 time"},
+			{Name: "limit", In: "query", Type: "integer", Desc: "Maximum operations to return (1-100, default 25)"},
+			{Name: "cursor", In: "query", Type: "string", Desc: "Pagination cursor from a previous response"},
+		},
+		Response: model.ListOperationsResult{},
+	}, m.handleListOperations)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/feed/following", OptionalAuth: true,
+		Summary: "List the public posts of the DIDs a DID follows, newest first, with cursor pagination; private follows are used when the caller is authenticated as the DID. Only the most recent follows up to the fan-out limit are used (truncated is set when more exist), and pages may be shorter than limit when authors are capped",
+		Params: []apiParam{
+			{Name: "did", In: "query", Type: "string", Required: true, Desc: "DID whose follows make up the feed"},
+			{Name: "limit", In: "query", Type: "integer", Desc: "Maximum records to return (1-100, default 25)"},
+			{Name: "cursor", In: "query", Type: "string", Desc: "Pagination cursor from a previous response"},
+		},
+		Response: model.FeedResult{},
+	}, m.handleFollowingFeed)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/export", Auth: true,
+		Summary: "Export the authenticated DID's records newest first, as JSON Lines (application/x-ndjson) or a CARv1 archive (application/vnd.ipld.car) chosen via format or Accept",
+		Params: []apiParam{
+			{Name: "collection", In: "query", Type: "string", Desc: "Collection NSID filter"},
+			{Name: "format", In: "query", Type: "string", Desc: "Export format (jsonl or car); overrides Accept"},
+		},
+	}, m.handleExportRecords)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/repo/idempotency/{key}", Auth: true,
+		Summary:  "Get the cached outcome of a request made with an idempotency key",
+		Params:   []apiParam{{Name: "key", In: "path", Type: "string", Desc: "Idempotency key"}},
+		Response: model.IdempotencyStatusData{},
+	}, m.handleGetIdempotencyStatus)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/media/uploadInit", Auth: true,
+		Summary:  "Start a media upload and get a presigned upload URL",
+		Request:  model.UploadInitRequest{},
+		Response: model.UploadInitData{},
+	}, m.handleUploadInit)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/media/finalize", Auth: true,
+		Summary:  "Verify an uploaded object's checksum and finalize the media asset",
+		Request:  model.FinalizeRequest{},
+		Response: model.MediaAsset{},
+	}, m.handleFinalize)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/media/uploadInitMultipart", Auth: true,
+		Summary:  "Start a multipart media upload and get a presigned URL for each part",
+		Request:  model.UploadInitMultipartRequest{},
+		Response: model.UploadInitMultipartData{},
+	}, m.handleUploadInitMultipart)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/media/completeMultipart", Auth: true,
+		Summary:  "Assemble the uploaded parts of a multipart upload; the asset is then finalized as usual",
+		Request:  model.CompleteMultipartRequest{},
+		Response: model.MediaAsset{},
+	}, m.handleCompleteMultipart)
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/media/abortMultipart", Auth: true,
+		Summary:  "Abandon a multipart upload, discarding its uploaded parts and the pending asset",
+		Request:  model.AbortMultipartRequest{},
+		Response: model.AbortMultipartData{},
+	}, m.handleAbortMultipart)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/media/list", Auth: true,
+		Summary: "List the authenticated DID's media assets, newest first, with cursor pagination",
+		Params: []apiParam{
+			{Name: "mimeType", In: "query", Type: "string", Desc: "MIME type filter; a trailing wildcard such as image/* matches the whole type"},
+			{Name: "finalized", In: "query", Type: "boolean", Desc: "Only finalized (true) or unfinalized (false) uploads"},
+			{Name: "limit", In: "query", Type: "integer", Desc: "Maximum assets to return (1-100, default 25)"},
+			{Name: "cursor", In: "query", Type: "string", Desc: "Pagination cursor from a previous response"},
+		},
+		Response: model.ListMediaAssetsResult{},
+	}, m.handleListMediaAssets)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/media/{assetId}/meta", Auth: true,
+		Summary:  "Get media asset metadata",
+		Params:   []apiParam{{Name: "assetId", In: "path", Type: "string", Desc: "Media asset ID"}},
+		Response: model.MediaAsset{},
+	}, m.handleGetMediaMeta)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/media/{assetId}/download", Auth: true,
+		Summary: "Redirect the asset's owner to a short-lived presigned URL for the finalized object",
+		Params:  []apiParam{{Name: "assetId", In: "path", Type: "string", Desc: "Media asset ID"}},
+	}, m.handleDownloadMedia)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/account/settings", Auth: true,
+		Summary:  "Get the caller's account settings",
+		Response: model.AccountSettings{},
+	}, m.handleGetAccountSettings)
+	m.handleAPI(apiRoute{
+		Method: "PUT", Pattern: "/v1/account/settings", Auth: true,
+		Summary:  "Replace the caller's account settings, creating the account if needed",
+		Request:  model.AccountSettings{},
+		Response: model.AccountSettings{},
+	}, m.handleUpdateAccountSettings)
+	m.handleAPI(apiRoute{
+		Method: "GET", Pattern: "/v1/admin/consistency", Auth: true,
+		Summary:  "Report records and media assets whose DID has no account (admin only)",
+		Response: model.ConsistencyReport{},
+	}, m.requireAdmin(m.handleConsistency))
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/admin/schema/refresh", Auth: true,
+		Summary:  "Refetch the schema index from the specs repository now, bypassing the resolver cache, and return the resolved versions (admin only)",
+		Response: model.SchemaRefreshData{},
+	}, m.requireAdmin(m.handleSchemaRefresh))
+	m.handleAPI(apiRoute{
+		Method: "POST", Pattern: "/v1/admin/accounts/batch", Auth: true,
+		Summary:  "Create accounts for up to 1000 DIDs in one transaction, reporting per DID whether it was created or already existed (admin only)",
+		Request:  model.CreateAccountsRequest{},
+		Response: model.CreateAccountsData{},
+	}, m.requireAdmin(m.handleCreateAccounts))
+	// Unknown API paths get a JSON 404 rather than ServeMux's plain-text one
+	m.mux.HandleFunc("/v1/", m.handleNotFound)
+
+	// Serve the OpenAPI document generated from the routes above
+	m.openAPISpec, err = json.Marshal(m.openAPIDocument())
+	if err != nil {
+		slog.Error("failed to build OpenAPI document", "error", err)
+		os.Exit(1)
+	}
+	m.route(http.MethodGet, "/openapi.json", m.handleOpenAPI)
 
-	return m.mux
+	return m
 }
 
-// method ensures the HTTP method matches the expected method
-func (m *Mux) method(method string, h http.HandlerFunc) http.HandlerFunc {
+// route registers h under a method pattern (e.g. "GET /v1/media/{assetId}/meta").
+// Other methods on the same path get a 405 with an Allow header, except OPTIONS,
+// which goes through the middleware for CORS preflight handling.
+func (m *Mux) route(method, pattern string, h http.HandlerFunc) {
+	if _, ok := m.allowedMethods[pattern]; !ok {
+		m.mux.HandleFunc(pattern, m.methodNotAllowed(pattern))
+	}
+	m.allowedMethods[pattern] = append(m.allowedMethods[pattern], method)
+	if method == http.MethodGet {
+		m.allowedMethods[pattern] = append(m.allowedMethods[pattern], http.MethodHead)
+	}
+	m.mux.HandleFunc(method+" "+pattern, h)
+}
+
+// handleNotFound answers API requests that match no route
+func (m *Mux) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	m.writeError(w, http.StatusNotFound, string(errordefs.CDV_NOT_FOUND), "no such endpoint", requestCorrelationID(w, r), nil)
+}
+
+// requestCorrelationID returns the request's X-Correlation-Id, generating one if the client
+// sent none, and echoes it in the response. Routes outside withMiddleware use it directly.
+func requestCorrelationID(w http.ResponseWriter, r *http.Request) string {
+	correlationID := r.Header.Get("X-Correlation-Id")
+	if correlationID == "" {
+		correlationID = uuid.New().String()
+	}
+	w.Header().Set("X-Correlation-Id", correlationID)
+	return correlationID
+}
+
+// methodNotAllowed answers requests whose method has no handler registered for pattern.
+// OPTIONS is answered for every route: with the Allow header, plus CORS headers for preflights.
+func (m *Mux) methodNotAllowed(pattern string) http.HandlerFunc {
+	preflight := m.withMiddleware(func(http.ResponseWriter, *http.Request) {})
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != method {
-			err := errordefs.New(errordefs.CDV_BAD_REQUEST, "method not allowed", "")
-			m.writeErrorDef(w, err)
+		w.Header().Set("Allow", strings.Join(m.allowedMethods[pattern], ", ")+", "+http.MethodOptions)
+		if r.Method == http.MethodOptions {
+			preflight(w, r)
 			return
 		}
-		h(w, r)
+		m.writeError(w, http.StatusMethodNotAllowed, string(errordefs.CDV_BAD_REQUEST), "method not allowed", requestCorrelationID(w, r), nil)
 	}
 }
 
@@ -165,16 +690,32 @@ func (m *Mux) method(method string, h http.HandlerFunc) http.HandlerFunc {
 func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+
+		// Start a server span, continuing any propagated trace, so handler spans
+		// and metric exemplars share the same trace ID
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer("cdv-service").Start(ctx, r.Method+" "+routeLabel(r), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		// Capture the status code actually written for metrics
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		w = rec
+		defer func() {
+			m.observeRequest(r, rec.status, start)
+		}()
+		
+		corsAllowedOrigins := m.policy.Load().CORSAllowedOrigins
 		
 		// Handle CORS preflight requests
 		if r.Method == "OPTIONS" {
 			// Set CORS headers
-			if len(m.corsAllowedOrigins) > 0 {
+			if len(corsAllowedOrigins) > 0 {
 				origin := r.Header.Get("Origin")
 				if origin != "" {
 					// Check if origin is allowed
 					allowed := false
-					for _, allowedOrigin := range m.corsAllowedOrigins {
+					for _, allowedOrigin := range corsAllowedOrigins {
 						if allowedOrigin == "*" || allowedOrigin == origin {
 							allowed = true
 							break
@@ -182,9 +723,10 @@ func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 					}
 					if allowed {
 						w.Header().Set("Access-Control-Allow-Origin", origin)
-						w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Correlation-Id")
+						w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Correlation-Id, DPoP")
 						w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+						m.setCORSCredentials(w, origin)
 					}
 				}
 			}
@@ -193,12 +735,12 @@ func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 		}
 		
 		// Set CORS headers for regular requests
-		if len(m.corsAllowedOrigins) > 0 {
+		if len(corsAllowedOrigins) > 0 {
 			origin := r.Header.Get("Origin")
 			if origin != "" {
 				// Check if origin is allowed
 				allowed := false
-				for _, allowedOrigin := range m.corsAllowedOrigins {
+				for _, allowedOrigin := range corsAllowedOrigins {
 					if allowedOrigin == "*" || allowedOrigin == origin {
 						allowed = true
 						break
@@ -206,20 +748,41 @@ func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 				}
 				if allowed {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "DPoP-Nonce, WWW-Authenticate, X-Correlation-Id")
+					m.setCORSCredentials(w, origin)
 				}
 			}
 		}
 
 		// Add correlation ID if not present
-		correlationID := r.Header.Get("X-Correlation-Id")
-		if correlationID == "" {
-			correlationID = uuid.New().String()
-		}
+		correlationID := requestCorrelationID(w, r)
 		r = r.WithContext(context.WithValue(r.Context(), ContextKeyCorrelationID, correlationID))
-		w.Header().Set("X-Correlation-Id", correlationID)
 
-		// Apply JWT authentication for mutating endpoints
-		if r.Method == "POST" || strings.HasPrefix(r.URL.Path, "/v1/media/") {
+		// Apply JWT authentication for routes declared with Auth, and for public reads when
+		// credentials are sent; invalid credentials are rejected either way
+		authRequired := m.requiredAuthRoutes[r.Pattern]
+		if m.optionalAuthPaths[r.URL.Path] && (m.requireAuthReads || m.hasCredentials(r)) {
+			authRequired = true
+		}
+
+		// Throttle anonymous reads per client IP, with their own limit when configured
+		if !authRequired && m.optionalAuthPaths[r.URL.Path] {
+			limiter, key := m.anonReadLimiter, m.clientIP(r)
+			if limiter == nil {
+				limiter, key = m.rateLimiter, "ip:"+key
+			}
+			if limiter != nil {
+				if ok, wait := limiter.Allow(r.Context(), key); !ok {
+					m.rejectRateLimited(w, r, span, wait, "too many unauthenticated requests", correlationID, start)
+					return
+				}
+			}
+		}
+		if authRequired {
+			// Hand out a fresh DPoP nonce so clients can (re)build proofs
+			if m.dpopEnabled() {
+				w.Header().Set("DPoP-Nonce", m.dpopNonce(time.Now()))
+			}
 			did, err := m.validateJWT(r)
 			if err != nil {
 				// Check if err is already an errordefs.Error or create a new one
@@ -230,11 +793,26 @@ func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 				} else {
 					errorDef = errordefs.New(errordefs.CDV_AUTHZ, err.Error(), correlationID)
 				}
+				setDPoPChallenge(w, errorDef)
+				failSpan(span, errorDef)
 				m.writeErrorDef(w, errorDef)
-				m.logRequest(r, errorDef.HTTPStatus, time.Since(start), correlationID, err)
+				m.logRequest(w, r, time.Since(start), correlationID, err)
 				return
 			}
 			r = r.WithContext(context.WithValue(r.Context(), ContextKeyDID, did))
+			
+			// Throttle authenticated callers per DID
+			if m.rateLimiter != nil {
+				if ok, wait := m.rateLimiter.Allow(r.Context(), "did:"+did); !ok {
+					m.rejectRateLimited(w, r, span, wait, "too many requests", correlationID, start)
+					return
+				}
+			}
+		}
+
+		// Bound request bodies so decoding cannot exhaust memory
+		if r.Method == "POST" || r.Method == "PUT" || r.Method == "DELETE" {
+			r.Body = http.MaxBytesReader(w, r.Body, m.maxRequestBody)
 		}
 
 		// Call the handler
@@ -242,37 +820,166 @@ func (m *Mux) withMiddleware(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// jsonBodyError maps a request body decoding error to its API error: bodies over the
+// size limit get CDV_BODY_TOO_LARGE, anything else is invalid JSON
+func (m *Mux) jsonBodyError(err error, correlationID string) *errordefs.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errordefs.New(errordefs.CDV_BODY_TOO_LARGE, fmt.Sprintf("request body exceeds the limit of %d bytes", tooLarge.Limit), correlationID)
+	}
+	return errordefs.New(errordefs.CDV_VALIDATION, "invalid JSON", correlationID)
+}
+
+// rejectRateLimited answers a throttled request with CDV_RATE_LIMIT and a Retry-After
+// of wait rounded up to whole seconds
+func (m *Mux) rejectRateLimited(w http.ResponseWriter, r *http.Request, span trace.Span, wait time.Duration, message, correlationID string, start time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	errorDef := errordefs.New(errordefs.CDV_RATE_LIMIT, message, correlationID)
+	failSpan(span, errorDef)
+	m.writeErrorDef(w, errorDef)
+	m.logRequest(w, r, time.Since(start), correlationID, errorDef)
+}
+
+// setCORSCredentials allows credentialed (cookie) requests from an origin when cookie
+// authentication is enabled. A wildcard entry never grants credentials; the origin
+// must be listed explicitly.
+func (m *Mux) setCORSCredentials(w http.ResponseWriter, origin string) {
+	if m.authCookieName == "" || !slices.Contains(m.policy.Load().CORSAllowedOrigins, origin) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Add("Vary", "Origin")
+}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code written by handlers
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int   // Status code passed to WriteHeader (200 if never called)
+	wroteHeader bool  // Whether WriteHeader has been called
+	bytes       int64 // Body bytes written
+}
+
+// WriteHeader records the status code and forwards it to the wrapped writer
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.status = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Write forwards to the wrapped writer, implying a 200 status if none was set
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if !sr.wroteHeader {
+		sr.WriteHeader(http.StatusOK)
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController can reach Flush and deadlines
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// findStatusRecorder returns the statusRecorder installed by the middleware, looking
+// through writers that wrap it, or nil outside the middleware
+func findStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	for {
+		switch rw := w.(type) {
+		case *statusRecorder:
+			return rw
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// routeLabel returns the registered route pattern for a request, which keeps
+// metric and span names bounded regardless of path parameters
+func routeLabel(r *http.Request) string {
+	if r.Pattern != "" {
+		// Drop the method from method patterns; it is recorded separately
+		if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+			return path
+		}
+		return r.Pattern
+	}
+	return "unmatched"
+}
+
+// observeRequest counts the request and records its duration, attaching the trace ID as
+// an exemplar when the request is traced so operators can jump from a latency bucket to the trace
+func (m *Mux) observeRequest(r *http.Request, status int, start time.Time) {
+	duration := time.Since(start).Seconds()
+	m.metrics.HTTPRequestTotal.WithLabelValues(r.Method, routeLabel(r), strconv.Itoa(status)).Inc()
+	observer := m.metrics.HTTPRequestDuration.WithLabelValues(r.Method, routeLabel(r), strconv.Itoa(status))
+
+	spanContext := trace.SpanContextFromContext(r.Context())
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanContext.HasTraceID() {
+		exemplarObserver.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+		return
+	}
+	observer.Observe(duration)
+}
+
 // validateJWT validates a JWT and extracts the DID using JWKS
 func (m *Mux) validateJWT(r *http.Request) (string, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return "", errordefs.New(errordefs.CDV_AUTHN, "missing Authorization header", "")
+	tokenString, source, err := m.bearerToken(r)
+	if err != nil {
+		return "", err
+	}
+	
+	// Browsers attach cookies to cross-site requests, so cookie-authenticated mutations need CSRF checks
+	if source == tokenFromCookie && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if err := m.checkCSRF(r); err != nil {
+			return "", err
+		}
 	}
 
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return "", errordefs.New(errordefs.CDV_AUTHN, "invalid Authorization header format", "")
+	// Cheap structural checks before handing the token to the parser
+	if len(tokenString) > m.maxJWTLength {
+		return "", errordefs.New(errordefs.CDV_JWT_MALFORMED, fmt.Sprintf("JWT exceeds maximum length of %d bytes", m.maxJWTLength), "")
 	}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	// A JWE compact serialization has five segments; only signed JWTs (JWS, three segments) are accepted
+	segments := strings.Split(tokenString, ".")
+	if len(segments) == 5 {
+		return "", errordefs.New(errordefs.CDV_JWT_MALFORMED, "encrypted JWTs (JWE) are not supported; send a signed JWT (JWS)", "")
+	}
+	if len(segments) != 3 || segments[0] == "" || segments[1] == "" {
+		return "", errordefs.New(errordefs.CDV_JWT_MALFORMED, "JWT must have three non-empty segments", "")
+	}
 
 	// Validate JWT using JWKS
-	claims, err := m.jwksClient.ValidateJWT(r.Context(), tokenString, m.jwtIssuer, m.jwtAudience)
+	claims, err := m.jwksClient.ValidateJWT(r.Context(), tokenString, m.jwtIssuer, append([]string{m.jwtAudience}, m.trustedAudiences...)...)
 	if err != nil {
 		// Map specific JWT validation errors to appropriate error codes
-		errStr := err.Error()
-		if strings.Contains(errStr, "expired") {
+		switch {
+		case errors.Is(err, jwks.ErrExpired):
 			return "", errordefs.New(errordefs.CDV_JWT_EXPIRED, "JWT token expired", "")
-		} else if strings.Contains(errStr, "invalid issuer") {
+		case errors.Is(err, jwks.ErrNotYetValid):
+			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "JWT not yet valid", "")
+		case errors.Is(err, jwks.ErrInvalidIssuer):
 			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "invalid JWT issuer", "")
-		} else if strings.Contains(errStr, "invalid audience") {
+		case errors.Is(err, jwks.ErrInvalidAudience):
 			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "invalid JWT audience", "")
-		} else if strings.Contains(errStr, "kid") {
+		case errors.Is(err, jwks.ErrMissingKid), errors.Is(err, jwks.ErrKidNotFound):
 			return "", errordefs.New(errordefs.CDV_JWT_MALFORMED, "missing or invalid kid in JWT header", "")
-		} else if strings.Contains(errStr, "key") {
+		case errors.Is(err, jwks.ErrKeyFetch):
 			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "failed to get key for JWT validation", "")
-		} else if strings.Contains(errStr, "signature") || strings.Contains(errStr, "verify") {
+		case errors.Is(err, jwks.ErrInvalidSignature):
 			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "invalid JWT signature", "")
-		} else {
+		case errors.Is(err, jwks.ErrUnsupportedAlg):
+			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "unsupported JWT algorithm; EdDSA (Ed25519) is required", "")
+		case errors.Is(err, jwks.ErrInvalidType):
+			return "", errordefs.New(errordefs.CDV_JWT_INVALID, "unsupported JWT typ", "")
+		case errors.Is(err, jwks.ErrMalformed), errors.Is(err, jwks.ErrInvalidClaims):
+			return "", errordefs.New(errordefs.CDV_JWT_MALFORMED, "malformed JWT", "")
+		default:
 			return "", errordefs.New(errordefs.CDV_JWT_INVALID, fmt.Sprintf("failed to validate JWT: %v", err), "")
 		}
 	}
@@ -282,9 +989,154 @@ func (m *Mux) validateJWT(r *http.Request) (string, error) {
 		return "", errordefs.New(errordefs.CDV_JWT_INVALID, "missing or invalid sub claim", "")
 	}
 
+	if m.dpopEnabled() {
+		if err := m.checkDPoP(r, tokenString, source, claims); err != nil {
+			return "", err
+		}
+	}
+
+	if m.replayProtection {
+		if err := m.checkReplay(r.Context(), claims); err != nil {
+			return "", err
+		}
+	}
+
 	return did, nil
 }
 
+// verifyRetryAfter is the Retry-After value (seconds) sent when verification is saturated
+const verifyRetryAfter = "1"
+
+// acquireVerify reserves a media verification slot without blocking.
+// It returns false when the limit is reached; otherwise release must be called when done.
+func (m *Mux) acquireVerify() (release func(), ok bool) {
+	return tryAcquire(m.verifySem)
+}
+
+// tryAcquire takes a slot from a semaphore channel without blocking.
+// A nil semaphore is unlimited.
+func tryAcquire(sem chan struct{}) (release func(), ok bool) {
+	if sem == nil {
+		return func() {}, true
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	default:
+		return nil, false
+	}
+}
+
+// requireContentType rejects requests whose Content-Type is not an allowed media type
+// before the body is decoded, so misconfigured clients get a clear error
+func (m *Mux) requireContentType(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !slices.Contains(m.allowedContentTypes, mediaType) {
+			correlationID, _ := r.Context().Value(ContextKeyCorrelationID).(string)
+			m.writeErrorDef(w, errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("unsupported Content-Type %q; expected one of: %s", contentType, strings.Join(m.allowedContentTypes, ", ")), correlationID))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// checkReplay records the token's jti until the token expires, including the leeway, and rejects a jti that was already used
+func (m *Mux) checkReplay(ctx context.Context, claims map[string]interface{}) error {
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return errordefs.New(errordefs.CDV_JWT_INVALID, "missing jti claim; replay protection requires a unique token ID", "")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errordefs.New(errordefs.CDV_JWT_INVALID, "missing exp claim; replay protection requires an expiring token", "")
+	}
+
+	// Token IDs are only unique per issuer
+	iss, _ := claims["iss"].(string)
+	tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(iss+":"+jti)))
+
+	// Tokens are accepted until exp plus the clock skew leeway, so keep the jti that long
+	err := m.s.MarkTokenUsed(ctx, tokenHash, time.Unix(int64(exp), 0).Add(m.jwksClient.Leeway()).UTC())
+	switch {
+	case errors.Is(err, storage.ErrConflict):
+		return errordefs.New(errordefs.CDV_JWT_INVALID, "JWT has already been used", "")
+	case err != nil:
+		slog.Error("failed to record JWT ID", "error", err)
+		return errordefs.New(errordefs.CDV_INTERNAL, "failed to check JWT replay", "")
+	}
+	return nil
+}
+
+// tokenSource records where a request's JWT was read from
+type tokenSource int
+
+const (
+	tokenFromBearer tokenSource = iota // Authorization: Bearer
+	tokenFromDPoP                      // Authorization: DPoP, with a proof in the DPoP header
+	tokenFromCookie                    // Auth cookie
+)
+
+// bearerToken extracts the JWT from the Authorization header, falling back to the
+// auth cookie when cookie authentication is enabled. It reports where the token came from.
+func (m *Mux) bearerToken(r *http.Request) (string, tokenSource, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if m.authCookieName != "" {
+			if cookie, err := r.Cookie(m.authCookieName); err == nil && cookie.Value != "" {
+				return cookie.Value, tokenFromCookie, nil
+			}
+			return "", tokenFromBearer, errordefs.New(errordefs.CDV_AUTHN, "missing Authorization header or auth cookie", "")
+		}
+		return "", tokenFromBearer, errordefs.New(errordefs.CDV_AUTHN, "missing Authorization header", "")
+	}
+
+	if m.dpopEnabled() && strings.HasPrefix(authHeader, "DPoP ") {
+		return strings.TrimPrefix(authHeader, "DPoP "), tokenFromDPoP, nil
+	}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", tokenFromBearer, errordefs.New(errordefs.CDV_AUTHN, "invalid Authorization header format", "")
+	}
+
+	return strings.TrimPrefix(authHeader, "Bearer "), tokenFromBearer, nil
+}
+
+// hasCredentials reports whether a request carries a token that bearerToken would read
+func (m *Mux) hasCredentials(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	if m.authCookieName == "" {
+		return false
+	}
+	cookie, err := r.Cookie(m.authCookieName)
+	return err == nil && cookie.Value != ""
+}
+
+// checkCSRF verifies that a cookie-authenticated mutation comes from this service's own origin
+// or an explicitly allowed CORS origin, using the Origin header (or Referer when Origin is absent).
+// Requests authenticated with an Authorization header cannot be forged cross-site and are not checked.
+func (m *Mux) checkCSRF(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+			origin = referer.Scheme + "://" + referer.Host
+		}
+	}
+	if origin == "" {
+		return errordefs.New(errordefs.CDV_AUTHZ, "cookie-authenticated requests must include an Origin header", "")
+	}
+
+	if slices.Contains(m.policy.Load().CORSAllowedOrigins, origin) {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return errordefs.New(errordefs.CDV_AUTHZ, "cross-origin request rejected for cookie authentication", "")
+}
+
 // writeSuccess writes a successful response
 func (m *Mux) writeSuccess(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -320,11 +1172,17 @@ func (m *Mux) writeErrorDef(w http.ResponseWriter, err *errordefs.Error) {
 }
 
 // logRequest logs request details
-func (m *Mux) logRequest(r *http.Request, status int, duration time.Duration, correlationID string, err error) {
+func (m *Mux) logRequest(w http.ResponseWriter, r *http.Request, duration time.Duration, correlationID string, err error) {
+	// Log the status the client actually received, as recorded by the middleware
+	status, bytes := http.StatusOK, int64(0)
+	if rec := findStatusRecorder(w); rec != nil {
+		status, bytes = rec.status, rec.bytes
+	}
 	attrs := []slog.Attr{
 		slog.String("method", r.Method),
 		slog.String("path", r.URL.Path),
 		slog.Int("status", status),
+		slog.Int64("bytes", bytes),
 		slog.Duration("duration", duration),
 		slog.String("user_agent", r.UserAgent()),
 		slog.String("remote_addr", r.RemoteAddr),
@@ -346,52 +1204,220 @@ func (m *Mux) logRequest(r *http.Request, status int, duration time.Duration, co
 	}
 }
 
+// failSpan records err on the handler span and marks the span as failed
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// startChildSpan starts a child span around a storage, media, or publish call
+// so traces show where request time is spent
+func startChildSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("cdv-service").Start(ctx, name)
+}
+
+// endChildSpan records the outcome of a call on its child span and ends it.
+// Not-found results are expected lookups and are not marked as span errors.
+func endChildSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		failSpan(span, err)
+	}
+	span.End()
+}
+
+// observeMediaOperation records the count and duration of a media storage operation
+func (m *Mux) observeMediaOperation(operation string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.metrics.MediaOperationTotal.WithLabelValues(operation, status).Inc()
+	m.metrics.MediaOperationDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
 // handleHealthz handles liveness health check requests
 func (m *Mux) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
 
-// handleReadyz handles readiness health check requests
+// handleReadyz handles readiness health check requests, checking each configured
+// dependency. The database and event streams are required; the others degrade the service
+// without making it unready. A failure answers 503 with an error envelope listing every
+// check, logged with the same correlation ID.
 func (m *Mux) handleReadyz(w http.ResponseWriter, r *http.Request) {
-	// Check if the service is ready to serve requests
-	// This should check dependencies like database connectivity
-	
-	// For now, we'll do a simple database check
-	// In a real implementation, you might check more dependencies
-	
-	// Test database connectivity by doing a simple query
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	correlationID := requestCorrelationID(w, r)
+	ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), ContextKeyCorrelationID, correlationID), 5*time.Second)
 	defer cancel()
 	
-	// Try to get a non-existent account to test database connectivity
-	_, err := m.s.GetAccount(ctx, "health-check")
-	
-	// We expect ErrNotFound, which means the database is accessible
-	// Any other error indicates a problem
-	if err != nil && !errors.Is(err, storage.ErrNotFound) {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		_, _ = w.Write([]byte("not ready"))
-		return
+	checks := []model.ReadinessCheck{}
+	var degraded []string
+	var failed string
+	record := func(name, status string, err error) {
+		check := model.ReadinessCheck{Name: name, Status: status}
+		if err != nil {
+			slog.Warn("readiness check "+status, "dependency", name, "error", err, "correlationId", correlationID)
+			if m.readinessErrorDetail {
+				check.Error = err.Error()
+			}
+		}
+		switch {
+		case status == model.ReadinessDegraded && name == "nats":
+			// The plaintext body names what is lost, as it did before checks were listed
+			degraded = append(degraded, "events")
+		case status == model.ReadinessDegraded:
+			degraded = append(degraded, name)
+		case status == model.ReadinessFailed && failed == "":
+			failed = name
+		}
+		checks = append(checks, check)
 	}
 	
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("ok"))
-}
-
-// handleCreateRecord handles POST /v1/repo/record with idempotency support
-func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
-	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleCreateRecord")
-	defer span.End()
-	defer r.Body.Close()
-	
-	var req model.CreateRecordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "invalid JSON")
-		err := errordefs.New(errordefs.CDV_VALIDATION, "invalid JSON", correlationID)
-		m.writeErrorDef(w, err)
-		return
+	// Look up a non-existent account: ErrNotFound means the database is accessible
+	if _, err := m.s.GetAccount(ctx, "health-check"); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		record("database", model.ReadinessFailed, err)
+	} else {
+		record("database", model.ReadinessOK, nil)
+	}
+	
+	// Events cannot be published until pre-provisioned streams exist. Requests still succeed
+	// when NATS was configured but the publisher fell back to noop, so that only degrades
+	// readiness; the dropped events must still be visible.
+	rc, checksStreams := m.p.(event.ReadinessChecker)
+	sr, reportsStatus := m.p.(event.StatusReporter)
+	var kind string
+	var statusErr error
+	if reportsStatus {
+		kind, statusErr = sr.Status()
+	}
+	if streamsErr := error(nil); checksStreams || (reportsStatus && (kind != event.PublisherTypeNoop || statusErr != nil)) {
+		if checksStreams {
+			streamsErr = rc.Ready(ctx)
+		}
+		switch {
+		case streamsErr != nil:
+			record("nats", model.ReadinessFailed, streamsErr)
+		case kind == event.PublisherTypeNoop && statusErr != nil:
+			record("nats", model.ReadinessDegraded, statusErr)
+		default:
+			record("nats", model.ReadinessOK, nil)
+		}
+	}
+	
+	// Media and identity lookups fail without S3 and the identity service, but records do not
+	if m.mediaClient != nil {
+		if err := m.mediaClient.Ping(ctx); err != nil {
+			record("s3", model.ReadinessDegraded, err)
+		} else {
+			record("s3", model.ReadinessOK, nil)
+		}
+	}
+	if m.id != nil {
+		if err := m.id.Ping(ctx); err != nil {
+			record("identity", model.ReadinessDegraded, err)
+		} else {
+			record("identity", model.ReadinessOK, nil)
+		}
+	}
+	
+	// The schema resolver falls back to inline schemas, so an unreachable
+	// specs repository degrades the service but does not make it unready
+	if m.checkSpecsReadiness {
+		if err := m.resolver.Ping(ctx); err != nil {
+			record("schema_resolver", model.ReadinessDegraded, err)
+		} else {
+			record("schema_resolver", model.ReadinessOK, nil)
+		}
+	}
+	
+	if failed != "" {
+		m.writeErrorDef(w, errordefs.NewWithDetails(errordefs.CDV_UNAVAILABLE, "not ready: "+failed+" unavailable", correlationID,
+			model.ReadinessDetails{Dependency: failed, Checks: checks}))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if len(degraded) > 0 {
+		_, _ = w.Write([]byte("degraded: " + strings.Join(degraded, ", ")))
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+// recordConflictError builds a CDV_CONFLICT error naming the conflicting field when the store reports it
+func recordConflictError(err error, correlationID string) *errordefs.Error {
+	var conflict *storage.ConflictError
+	if errors.As(err, &conflict) {
+		return errordefs.NewWithDetails(errordefs.CDV_CONFLICT,
+			fmt.Sprintf("record already exists: conflicting %s", conflict.Field),
+			correlationID, map[string]string{"field": conflict.Field})
+	}
+	return errordefs.New(errordefs.CDV_CONFLICT, "record already exists", correlationID)
+}
+
+// isUnvalidatedCollection reports whether collection is exempt from schema validation
+func (m *Mux) isUnvalidatedCollection(collection string) bool {
+	for _, pattern := range m.unvalidatedCollections {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, ".") {
+			if strings.HasPrefix(collection, prefix) {
+				return true
+			}
+		} else if collection == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRecordValue validates a record value against its collection schema and returns
+// the schema version to store: the latest resolved version, with deprecated versions
+// rejected when the policy says so
+func (m *Mux) validateRecordValue(collection string, value map[string]interface{}, correlationID string) (string, *errordefs.Error) {
+	// Decoding already guaranteed a JSON object, which is all these collections require
+	if m.isUnvalidatedCollection(collection) {
+		return schema.UnvalidatedVersion, nil
+	}
+	if m.allowUnknownCollections && !m.validator.Supports(collection) {
+		return schema.UnknownVersion, nil
+	}
+	
+	schemaVersion, err := m.validator.Validate(collection, value)
+	if err != nil {
+		return "", errordefs.NewWithDetails(errordefs.CDV_SCHEMA_REJECT, fmt.Sprintf("schema validation failed: %v", err), correlationID, err.Error())
+	}
+	
+	// Resolve the latest schema version for this collection
+	resolvedVersion, err := m.validator.ResolveSchemaVersion(collection)
+	if err != nil {
+		slog.Warn("failed to resolve schema version, using validated version", "collection", collection, "error", err)
+		return schemaVersion, nil
+	}
+	
+	// Check if the resolved version is deprecated
+	if actualVersion, deprecated := strings.CutSuffix(resolvedVersion, ":deprecated"); deprecated {
+		if m.policy.Load().RejectDeprecatedSchemas {
+			return "", errordefs.New(errordefs.CDV_SCHEMA_REJECT, fmt.Sprintf("schema version %s of %s is deprecated", actualVersion, collection), correlationID)
+		}
+		
+		// Log a warning about using a deprecated schema
+		slog.Warn("using deprecated schema version", "collection", collection, "version", actualVersion)
+		return actualVersion, nil
+	}
+	return resolvedVersion, nil
+}
+
+// handleCreateRecord handles POST /v1/repo/record with idempotency support
+func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleCreateRecord")
+	defer span.End()
+	defer r.Body.Close()
+	
+	var req model.CreateRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := m.jsonBodyError(err, correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
 	}
 	
 	// Add request attributes to span
@@ -406,6 +1432,19 @@ func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
 	if req.Collection == "" || req.DID == "" || req.Record == nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		err := errordefs.New(errordefs.CDV_VALIDATION, "collection, did, and record are required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+
+	switch req.Visibility {
+	case "":
+		req.Visibility = model.VisibilityPublic
+	case model.VisibilityPublic, model.VisibilityPrivate:
+	default:
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_VALIDATION, "visibility must be public or private", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
@@ -415,68 +1454,90 @@ func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
 	if req.DID != jwtDID {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		err := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
 
-	// Check for idempotency key
+	// Claim the idempotency key before doing any work, so concurrent retries on any replica
+	// cannot both create the record; the claim is released if no record gets created
+	created := false
 	if req.IdempotencyKey != "" {
-		// Hash the idempotency key
-		keyHash := fmt.Sprintf("%x", sha256.Sum256([]byte(req.IdempotencyKey)))
+		keyHash := idempotencyKeyHash(req.DID, req.IdempotencyKey)
+		requestHash := idempotencyRequestHash(req)
 		
-		// Try to get cached response
-		if responseBody, statusCode, err := m.s.GetIdempotentResponse(ctx, keyHash); err == nil {
+		idemCtx, idemSpan := startChildSpan(ctx, "storage.ReserveIdempotencyKey")
+		cached, err := m.idempotency.Reserve(idemCtx, keyHash, requestHash, time.Now().UTC().Add(idempotencyReservationTTL))
+		endChildSpan(idemSpan, err)
+		if err != nil {
+			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+			err := errordefs.New(errordefs.CDV_INTERNAL, "failed to reserve idempotency key", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		if cached != nil {
+			// Reusing a key with a different payload is a conflict, not a replay
+			if cached.RequestHash != requestHash {
+				correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+				err := errordefs.New(errordefs.CDV_CONFLICT, "idempotency key conflict: different payload for same key", correlationID)
+				failSpan(span, err)
+				m.writeErrorDef(w, err)
+				return
+			}
+			if cached.Pending() {
+				correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+				err := errordefs.New(errordefs.CDV_CONFLICT, "a request with this idempotency key is still in progress", correlationID)
+				failSpan(span, err)
+				m.writeErrorDef(w, err)
+				return
+			}
+			
 			// Return cached response
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(statusCode)
-			w.Write(responseBody)
+			w.WriteHeader(cached.StatusCode)
+			w.Write(cached.ResponseBody)
 			return
 		}
+		defer func() {
+			if created {
+				return
+			}
+			// Detach from the request so a cancelled client still frees the key
+			if err := m.idempotency.Release(context.WithoutCancel(ctx), keyHash, requestHash); err != nil {
+				slog.Warn("failed to release idempotency key", "error", err)
+			}
+		}()
 	}
 
 	// Validate record against schema
-	schemaVersion, err := m.validator.Validate(req.Collection, req.Record)
-	if err != nil {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		err := errordefs.NewWithDetails(errordefs.CDV_SCHEMA_REJECT, fmt.Sprintf("schema validation failed: %v", err), correlationID, err.Error())
-		m.writeErrorDef(w, err)
+	schemaVersion, errDef := m.validateRecordValue(req.Collection, req.Record, ctx.Value(ContextKeyCorrelationID).(string))
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
 		return
 	}
-	
-	// Resolve the latest schema version for this collection
-	resolvedVersion, err := m.validator.ResolveSchemaVersion(req.Collection)
-	if err != nil {
-		slog.Warn("failed to resolve schema version, using validated version", "collection", req.Collection, "error", err)
-	} else {
-		// Check if the resolved version is deprecated
-		if strings.HasSuffix(resolvedVersion, ":deprecated") {
-			// Remove the deprecated suffix for storage
-			actualVersion := strings.TrimSuffix(resolvedVersion, ":deprecated")
-			
-			// Log a warning about using a deprecated schema
-			slog.Warn("using deprecated schema version", "collection", req.Collection, "version", actualVersion)
-			
-			// In a production environment, you might want to reject deprecated schemas
-			// after a certain date, but for now we'll accept them with a warning
-			schemaVersion = actualVersion
-		} else {
-			// Use the resolved version if available
-			schemaVersion = resolvedVersion
-		}
-	}
 
 	// Create account if it doesn't exist
-	if _, err := m.s.GetAccount(ctx, req.DID); err != nil {
+	accountCtx, accountSpan := startChildSpan(ctx, "storage.GetAccount")
+	_, err := m.s.GetAccount(accountCtx, req.DID)
+	endChildSpan(accountSpan, err)
+	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			if err := m.s.CreateAccount(ctx, req.DID); err != nil {
+			createCtx, createSpan := startChildSpan(ctx, "storage.CreateAccount")
+			err := m.s.CreateAccount(createCtx, req.DID)
+			endChildSpan(createSpan, err)
+			if err != nil {
 				correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 				err := errordefs.New(errordefs.CDV_INTERNAL, "failed to create account", correlationID)
+				failSpan(span, err)
 				m.writeErrorDef(w, err)
 				return
 			}
 		} else {
 			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 			err := errordefs.New(errordefs.CDV_INTERNAL, "failed to check account", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
@@ -509,25 +1570,37 @@ func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
 		Value:        req.Record,
 		IndexedAt:    indexedAt,
 		SchemaVersion: schemaVersion, // Use the schema version from validation
+		Visibility:   req.Visibility,
 	}
 
 	start := time.Now()
-	if err := m.s.CreateRecord(ctx, record); err != nil {
+	storeCtx, storeSpan := startChildSpan(ctx, "storage.CreateRecord")
+	err = m.s.CreateRecord(storeCtx, record)
+	endChildSpan(storeSpan, err)
+	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		if errors.Is(err, storage.ErrConflict) {
-			err := errordefs.New(errordefs.CDV_CONFLICT, "record already exists", correlationID)
+			err := recordConflictError(err, correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
-			m.logRequest(r, http.StatusConflict, time.Since(start), correlationID, err)
+			m.logRequest(w, r, time.Since(start), correlationID, err)
 			return
 		}
 		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to create record", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
-		m.logRequest(r, http.StatusInternalServerError, time.Since(start), correlationID, err)
+		m.logRequest(w, r, time.Since(start), correlationID, err)
 		return
 	}
+	// Keep the idempotency claim from here on, even if storing the response fails,
+	// so a retry cannot create the record a second time
+	created = true
 
 	// Publish record created event
-	if err := m.p.PublishRecordCreated(ctx, req.Collection, record); err != nil {
+	publishCtx, publishSpan := startChildSpan(ctx, "event.PublishRecordCreated")
+	err = m.p.PublishRecordCreated(publishCtx, req.Collection, record)
+	endChildSpan(publishSpan, err)
+	if err != nil {
 		slog.Warn("failed to publish record created event", "error", err)
 	}
 
@@ -539,20 +1612,23 @@ func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
 
 	// Store response for idempotency if key was provided
 	if req.IdempotencyKey != "" {
-		keyHash := fmt.Sprintf("%x", sha256.Sum256([]byte(req.IdempotencyKey)))
+		keyHash := idempotencyKeyHash(req.DID, req.IdempotencyKey)
 		// Calculate request hash for conflict detection
-		requestBytes, _ := json.Marshal(req)
-		requestHash := fmt.Sprintf("%x", sha256.Sum256(requestBytes))
+		requestHash := idempotencyRequestHash(req)
 		responseBody, _ := json.Marshal(map[string]interface{}{"data": response})
-		expiresAt := time.Now().UTC().Add(24 * time.Hour) // 24-hour expiration
+		expiresAt := time.Now().UTC().Add(idempotencyTTL)
 		
-		// Try to store the idempotent response
-		// If there's a conflict with a different request hash, this should return an error
-		if err := m.s.StoreIdempotentResponse(ctx, keyHash, requestHash, responseBody, http.StatusOK, expiresAt); err != nil {
+		// Replace the pending claim with the response; this only conflicts if the claim
+		// expired and another request took the key in the meantime
+		idemCtx, idemSpan := startChildSpan(ctx, "storage.StoreIdempotentResponse")
+		err := m.idempotency.Store(idemCtx, keyHash, requestHash, responseBody, http.StatusOK, expiresAt)
+		endChildSpan(idemSpan, err)
+		if err != nil {
 			// Check if this is a conflict error (different payload for same idempotency key)
 			if errors.Is(err, storage.ErrConflict) {
 				correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 				err := errordefs.New(errordefs.CDV_CONFLICT, "idempotency key conflict: different payload for same key", correlationID)
+				failSpan(span, err)
 				m.writeErrorDef(w, err)
 				return
 			}
@@ -562,7 +1638,378 @@ func (m *Mux) handleCreateRecord(w http.ResponseWriter, r *http.Request) {
 	}
 
 	m.writeSuccess(w, http.StatusOK, response)
-	m.logRequest(r, http.StatusOK, time.Since(start), ctx.Value(ContextKeyCorrelationID).(string), nil)
+	m.logRequest(w, r, time.Since(start), ctx.Value(ContextKeyCorrelationID).(string), nil)
+}
+
+// Lifetimes of idempotency entries
+const (
+	idempotencyReservationTTL = time.Minute    // Claim held while a request runs; outlives the server write timeout
+	idempotencyTTL            = 24 * time.Hour // Cached response of a finished request
+)
+
+// idempotencyKeyHash returns the storage hash for a client-supplied idempotency key.
+// The key is namespaced by DID so different accounts reusing the same key never collide.
+func idempotencyKeyHash(did, key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(did+":"+key)))
+}
+
+// idempotencyRequestHash returns the hash of a create request used to detect
+// a key being reused with a different payload
+func idempotencyRequestHash(req model.CreateRecordRequest) string {
+	requestBytes, _ := json.Marshal(req)
+	return fmt.Sprintf("%x", sha256.Sum256(requestBytes))
+}
+
+// handleGetIdempotencyStatus handles GET /v1/repo/idempotency/{key}.
+// It returns the cached status and response for a key owned by the authenticated DID,
+// so clients can reconcile after a network failure instead of blindly retrying.
+func (m *Mux) handleGetIdempotencyStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetIdempotencyStatus")
+	defer span.End()
+	
+	start := time.Now()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	key := r.PathValue("key")
+	if key == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "idempotency key is required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	did := ctx.Value(ContextKeyDID).(string)
+	span.SetAttributes(attribute.String("did", did))
+	
+	idemCtx, idemSpan := startChildSpan(ctx, "storage.GetIdempotentResponse")
+	cached, err := m.idempotency.Get(idemCtx, idempotencyKeyHash(did, key))
+	endChildSpan(idemSpan, err)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get idempotency status", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		m.logRequest(w, r, time.Since(start), correlationID, err)
+		return
+	}
+	
+	// Keys are namespaced by DID, so other accounts' keys are simply not found
+	if err != nil {
+		err := errordefs.New(errordefs.CDV_NOT_FOUND, "idempotency key not found", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	if cached.Pending() {
+		err := errordefs.New(errordefs.CDV_CONFLICT, "a request with this idempotency key is still in progress", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	m.writeSuccess(w, http.StatusOK, model.IdempotencyStatusData{
+		Key:      key,
+		Status:   cached.StatusCode,
+		Response: json.RawMessage(cached.ResponseBody),
+	})
+	m.logRequest(w, r, time.Since(start), correlationID, nil)
+}
+
+// handleUpdateRecord handles PUT /v1/repo/record
+func (m *Mux) handleUpdateRecord(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleUpdateRecord")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	var req model.UpdateRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err := m.jsonBodyError(err, correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	if req.URI == "" || req.Record == nil {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "uri and record are required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("uri", req.URI))
+	
+	getCtx, getSpan := startChildSpan(ctx, "storage.GetRecordByURI")
+	existing, err := m.s.GetRecordByURI(getCtx, req.URI)
+	endChildSpan(getSpan, err)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			err := errordefs.New(errordefs.CDV_NOT_FOUND, "record not found", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get record", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Only the owner may update a record, and it cannot move to another repository or collection
+	if existing.DID != ctx.Value(ContextKeyDID).(string) || (req.DID != "" && req.DID != existing.DID) {
+		err := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	if req.Collection != "" && req.Collection != existing.Collection {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "collection of an existing record cannot change", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	switch req.Visibility {
+	case "":
+		req.Visibility = existing.Visibility
+	case model.VisibilityPublic, model.VisibilityPrivate:
+	default:
+		err := errordefs.New(errordefs.CDV_VALIDATION, "visibility must be public or private", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	schemaVersion, errDef := m.validateRecordValue(existing.Collection, req.Record, correlationID)
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	// ID, RKey and URI are kept; the new value gets a new CID and is indexed now
+	record := *existing
+	record.Value = req.Record
+	record.CID = uuid.New().String() // In a real implementation, this would be a content hash
+	record.IndexedAt = time.Now().UTC()
+	record.SchemaVersion = schemaVersion
+	record.Visibility = req.Visibility
+	
+	updateCtx, updateSpan := startChildSpan(ctx, "storage.UpdateRecord")
+	err = m.s.UpdateRecord(updateCtx, record)
+	endChildSpan(updateSpan, err)
+	if err != nil {
+		// A concurrent delete may have won the race
+		if errors.Is(err, storage.ErrNotFound) {
+			err := errordefs.New(errordefs.CDV_NOT_FOUND, "record not found", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to update record", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Publish record updated event
+	publishCtx, publishSpan := startChildSpan(ctx, "event.PublishRecordUpdated")
+	err = m.p.PublishRecordUpdated(publishCtx, record)
+	endChildSpan(publishSpan, err)
+	if err != nil {
+		slog.Warn("failed to publish record updated event", "error", err)
+	}
+	
+	m.writeSuccess(w, http.StatusOK, model.CreateRecordData{
+		URI:       record.URI,
+		CID:       record.CID,
+		IndexedAt: record.IndexedAt,
+	})
+}
+
+// parseRecordURI splits a record URI of the form at://did/collection/rkey
+func parseRecordURI(uri string) (did, collection, rkey string, ok bool) {
+	rest, ok := strings.CutPrefix(uri, "at://")
+	if !ok {
+		return "", "", "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "did:") || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// handleGetRecord handles GET /v1/repo/getRecord
+func (m *Mux) handleGetRecord(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetRecord")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	uri := r.URL.Query().Get("uri")
+	if _, _, _, ok := parseRecordURI(uri); !ok {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "uri must be a record URI of the form at://did/collection/rkey", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("uri", uri))
+	
+	getCtx, getSpan := startChildSpan(ctx, "storage.GetRecordByURI")
+	record, err := m.s.GetRecordByURI(getCtx, uri)
+	endChildSpan(getSpan, err)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get record", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Private records are reported as not found to anyone but their owner
+	callerDID, _ := ctx.Value(ContextKeyDID).(string)
+	if err != nil || (record.Visibility == model.VisibilityPrivate && record.DID != callerDID) {
+		err := errordefs.New(errordefs.CDV_NOT_FOUND, "record not found", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	m.writeSuccess(w, http.StatusOK, record)
+}
+
+// maxGetRecordsBatch is the most URIs a getRecords request may look up
+const maxGetRecordsBatch = 100
+
+// handleGetRecords handles POST /v1/repo/getRecords
+func (m *Mux) handleGetRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetRecords")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	var req model.GetRecordsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err := m.jsonBodyError(err, correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Look each URI up once, keeping the request order
+	uris := make([]string, 0, len(req.URIs))
+	seen := make(map[string]bool, len(req.URIs))
+	for _, uri := range req.URIs {
+		if uri != "" && !seen[uri] {
+			seen[uri] = true
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 || len(uris) > maxGetRecordsBatch {
+		err := errordefs.New(errordefs.CDV_VALIDATION, fmt.Sprintf("uris must contain 1-%d URIs", maxGetRecordsBatch), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("uri_count", len(uris)))
+	
+	getCtx, getSpan := startChildSpan(ctx, "storage.GetRecordsByURIs")
+	found, err := m.s.GetRecordsByURIs(getCtx, uris)
+	endChildSpan(getSpan, err)
+	if err != nil {
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get records", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Private records are reported as not found to anyone but their owner
+	callerDID, _ := ctx.Value(ContextKeyDID).(string)
+	data := model.GetRecordsData{Records: []model.Record{}, NotFound: []string{}}
+	for _, uri := range uris {
+		record, ok := found[uri]
+		if !ok || (record.Visibility == model.VisibilityPrivate && record.DID != callerDID) {
+			data.NotFound = append(data.NotFound, uri)
+			continue
+		}
+		data.Records = append(data.Records, *record)
+	}
+	
+	m.writeSuccess(w, http.StatusOK, data)
+}
+
+// handleDeleteRecord handles DELETE /v1/repo/record
+func (m *Mux) handleDeleteRecord(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleDeleteRecord")
+	defer span.End()
+	defer r.Body.Close()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	// The URI comes from the query string, or from a JSON body when there is none
+	uri := r.URL.Query().Get("uri")
+	if uri == "" && r.ContentLength != 0 {
+		var req model.DeleteRecordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			err := m.jsonBodyError(err, correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		uri = req.URI
+	}
+	if uri == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "uri is required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("uri", uri))
+	
+	getCtx, getSpan := startChildSpan(ctx, "storage.GetRecordByURI")
+	record, err := m.s.GetRecordByURI(getCtx, uri)
+	endChildSpan(getSpan, err)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			err := errordefs.New(errordefs.CDV_NOT_FOUND, "record not found", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get record", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Only the owner may delete a record
+	if record.DID != ctx.Value(ContextKeyDID).(string) {
+		err := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	deleteCtx, deleteSpan := startChildSpan(ctx, "storage.DeleteRecord")
+	err = m.s.DeleteRecord(deleteCtx, uri)
+	endChildSpan(deleteSpan, err)
+	if err != nil {
+		// A concurrent delete may have won the race
+		if errors.Is(err, storage.ErrNotFound) {
+			err := errordefs.New(errordefs.CDV_NOT_FOUND, "record not found", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to delete record", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Publish record deleted event
+	publishCtx, publishSpan := startChildSpan(ctx, "event.PublishRecordDeleted")
+	err = m.p.PublishRecordDeleted(publishCtx, *record)
+	endChildSpan(publishSpan, err)
+	if err != nil {
+		slog.Warn("failed to publish record deleted event", "error", err)
+	}
+	
+	m.writeSuccess(w, http.StatusOK, model.DeleteRecordData{URI: uri})
 }
 
 // handleListRecords handles GET /v1/repo/listRecords
@@ -574,10 +2021,10 @@ func (m *Mux) handleListRecords(w http.ResponseWriter, r *http.Request) {
 	did := r.URL.Query().Get("did")
 	if did == "" {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "did is required")
 		err := errordefs.New(errordefs.CDV_VALIDATION, "did is required", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
-		m.logRequest(r, http.StatusBadRequest, time.Since(start), correlationID, errors.New("did is required"))
+		m.logRequest(w, r, time.Since(start), correlationID, errors.New("did is required"))
 		return
 	}
 	
@@ -620,33 +2067,328 @@ func (m *Mux) handleListRecords(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := model.ListRecordsQuery{
-		DID:        did,
-		Collection: collection,
-		Limit:      limit,
-		Cursor:     r.URL.Query().Get("cursor"),
-		Since:      since,
-		Until:      until,
+		DID:        did,
+		Collection: collection,
+		Limit:      limit,
+		Cursor:     r.URL.Query().Get("cursor"),
+		Since:      since,
+		Until:      until,
+		CursorIssuedAfter: m.cursorIssuedAfter(),
+	}
+	// Owners authenticated by the optional JWT also see their private records
+	if callerDID, _ := ctx.Value(ContextKeyDID).(string); callerDID == did {
+		query.IncludePrivate = true
+	}
+	
+	filters, err := parseValueFilters(r.URL.Query(), collection)
+	if err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_VALIDATION, err.Error(), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	query.ValueFilters = filters
+
+	// A wide range on a large repository forces a large scan; later pages are bounded by their cursor
+	if query.Cursor == "" && m.exceedsQueryWindow(since, until) {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_VALIDATION,
+			fmt.Sprintf("since/until range exceeds the maximum of %s; narrow the range or paginate", m.maxQueryWindow), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+
+	if query.Cursor, err = m.openCursor(query.Cursor); err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+
+	listCtx, listSpan := startChildSpan(ctx, "storage.ListRecords")
+	result, err := m.s.ListRecords(listCtx, query)
+	endChildSpan(listSpan, err)
+	if err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		
+		// Check if this is a cursor validation error
+		if strings.Contains(err.Error(), "invalid cursor") {
+			err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		
+		// For all other errors, return internal error
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to list records", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+
+	// Trim record values to the requested keys to save bandwidth
+	if fields := parseFields(r.URL.Query().Get("fields")); fields != nil {
+		span.SetAttributes(attribute.StringSlice("fields", fields))
+		for i := range result.Records {
+			result.Records[i].Value = projectValue(result.Records[i].Value, fields)
+		}
+	}
+	result.NextCursor = m.signCursor(result.NextCursor)
+
+	m.writeSuccess(w, http.StatusOK, result)
+}
+
+// handleListOperations handles GET /v1/repo/opLog, listing the caller's own operations
+func (m *Mux) handleListOperations(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleListOperations")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	params := r.URL.Query()
+	
+	// Operations reveal private records, so only the owner may read them
+	did := ctx.Value(ContextKeyDID).(string)
+	if requested := params.Get("did"); requested != "" && requested != did {
+		errDef := errordefs.New(errordefs.CDV_DID_MISMATCH, "did must match JWT subject", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(attribute.String("did", did))
+	
+	query := model.ListOperationsQuery{
+		DID:    did,
+		Limit:  DefaultListLimit,
+		Cursor: params.Get("cursor"),
+		CursorIssuedAfter: m.cursorIssuedAfter(),
+	}
+	if v, err := strconv.Atoi(params.Get("limit")); err == nil && v > 0 {
+		query.Limit = min(v, MaxListLimit)
+	}
+	for name, bound := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				errDef := errordefs.New(errordefs.CDV_VALIDATION, name+" must be an RFC 3339 time", correlationID)
+				failSpan(span, errDef)
+				m.writeErrorDef(w, errDef)
+				return
+			}
+			*bound = t
+		}
+	}
+	
+	var err error
+	if query.Cursor, err = m.openCursor(query.Cursor); err != nil {
+		errDef := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	listCtx, listSpan := startChildSpan(ctx, "storage.ListOperations")
+	result, err := m.s.ListOperations(listCtx, query)
+	endChildSpan(listSpan, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to list operations", correlationID)
+		if strings.Contains(err.Error(), "invalid cursor") {
+			errDef = errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+		}
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	span.SetAttributes(attribute.Int("operations", len(result.Operations)))
+	result.NextCursor = m.signCursor(result.NextCursor)
+	
+	m.writeSuccess(w, http.StatusOK, result)
+}
+
+// parseFields splits a comma-separated fields parameter, returning nil when no field is named
+func parseFields(v string) []string {
+	var fields []string
+	for _, field := range strings.Split(v, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// projectValue returns a new value holding only the given top-level keys; the stored
+// value is left untouched since it may be shared with the store
+func projectValue(value map[string]interface{}, fields []string) map[string]interface{} {
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if v, ok := value[field]; ok {
+			projected[field] = v
+		}
+	}
+	return projected
+}
+
+// exceedsQueryWindow reports whether a since/until range is wider than the configured
+// maximum. Without since the query is bounded by its limit instead.
+func (m *Mux) exceedsQueryWindow(since, until time.Time) bool {
+	if m.maxQueryWindow <= 0 || since.IsZero() {
+		return false
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	return until.Sub(since) > m.maxQueryWindow
+}
+
+// handleBacklinks handles GET /v1/repo/backlinks
+func (m *Mux) handleBacklinks(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleBacklinks")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	query := model.BacklinksQuery{
+		Subject:    r.URL.Query().Get("subject"),
+		Collection: r.URL.Query().Get("collection"),
+		Limit:      DefaultListLimit,
+		Cursor:     r.URL.Query().Get("cursor"),
+		CursorIssuedAfter: m.cursorIssuedAfter(),
+	}
+	if query.Subject == "" || query.Collection == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "subject and collection are required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		query.Limit = min(v, MaxListLimit)
+	}
+	span.SetAttributes(
+		attribute.String("subject", query.Subject),
+		attribute.String("collection", query.Collection),
+	)
+	
+	var err error
+	if query.Cursor, err = m.openCursor(query.Cursor); err != nil {
+		err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	listCtx, listSpan := startChildSpan(ctx, "storage.ListBacklinks")
+	result, err := m.s.ListBacklinks(listCtx, query)
+	endChildSpan(listSpan, err)
+	if err != nil {
+		code, msg := errordefs.CDV_INTERNAL, "failed to list backlinks"
+		if strings.Contains(err.Error(), "invalid cursor") {
+			code, msg = errordefs.CDV_CURSOR_INVALID, err.Error()
+		}
+		err := errordefs.New(code, msg, correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	result.NextCursor = m.signCursor(result.NextCursor)
+	
+	m.writeSuccess(w, http.StatusOK, result)
+}
+
+// handleDescribeRepo handles GET /v1/repo/describeRepo
+func (m *Mux) handleDescribeRepo(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleDescribeRepo")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	did := r.URL.Query().Get("did")
+	if did == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "did is required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("did", did))
+	
+	accountCtx, accountSpan := startChildSpan(ctx, "storage.GetAccount")
+	account, err := m.s.GetAccount(accountCtx, did)
+	endChildSpan(accountSpan, err)
+	if err != nil {
+		code, msg := errordefs.CDV_INTERNAL, "failed to get account"
+		if errors.Is(err, storage.ErrNotFound) {
+			code, msg = errordefs.CDV_NOT_FOUND, "account not found"
+		}
+		err := errordefs.New(code, msg, correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	// Private records are counted only for the owner, like countRecords
+	callerDID, _ := ctx.Value(ContextKeyDID).(string)
+	statsCtx, statsSpan := startChildSpan(ctx, "storage.CollectionStats")
+	stats, err := m.s.CollectionStats(statsCtx, did, callerDID == did)
+	endChildSpan(statsSpan, err)
+	if err != nil {
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to count records", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	data := model.DescribeRepoData{DID: account.DID, CreatedAt: account.CreatedAt, Collections: []model.CollectionStats{}}
+	data.Collections = append(data.Collections, stats...)
+	m.writeSuccess(w, http.StatusOK, data)
+}
+
+// handleCountRecords handles GET /v1/repo/countRecords
+func (m *Mux) handleCountRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleCountRecords")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	query := model.ListRecordsQuery{
+		DID:        r.URL.Query().Get("did"),
+		Collection: r.URL.Query().Get("collection"),
 	}
-
-	result, err := m.s.ListRecords(ctx, query)
+	if query.DID == "" {
+		err := errordefs.New(errordefs.CDV_VALIDATION, "did is required", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	span.SetAttributes(attribute.String("did", query.DID), attribute.String("collection", query.Collection))
+	if callerDID, _ := ctx.Value(ContextKeyDID).(string); callerDID == query.DID {
+		query.IncludePrivate = true
+	}
+	
+	// Time filters are parsed like listRecords: invalid values are ignored
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("since")); err == nil {
+		query.Since = t
+	}
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("until")); err == nil {
+		query.Until = t
+	}
+	filters, err := parseValueFilters(r.URL.Query(), query.Collection)
 	if err != nil {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "failed to list records")
-		
-		// Check if this is a cursor validation error
-		if strings.Contains(err.Error(), "invalid cursor") {
-			err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
-			m.writeErrorDef(w, err)
-			return
-		}
-		
-		// For all other errors, return internal error
-		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to list records", correlationID)
+		err := errordefs.New(errordefs.CDV_VALIDATION, err.Error(), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	query.ValueFilters = filters
+	
+	countCtx, countSpan := startChildSpan(ctx, "storage.CountRecords")
+	count, err := m.s.CountRecords(countCtx, query)
+	endChildSpan(countSpan, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to count records", correlationID)
+		failSpan(span, errDef)
 		m.writeErrorDef(w, errDef)
 		return
 	}
-
-	m.writeSuccess(w, http.StatusOK, result)
+	
+	m.writeSuccess(w, http.StatusOK, model.CountRecordsData{Count: count})
 }
 
 // handleUploadInit handles POST /v1/media/uploadInit
@@ -658,8 +2400,8 @@ func (m *Mux) handleUploadInit(w http.ResponseWriter, r *http.Request) {
 	var req model.UploadInitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "invalid JSON")
-		err := errordefs.New(errordefs.CDV_VALIDATION, "invalid JSON", correlationID)
+		err := m.jsonBodyError(err, correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
@@ -672,106 +2414,46 @@ func (m *Mux) handleUploadInit(w http.ResponseWriter, r *http.Request) {
 		attribute.Bool("has_filename", req.Filename != ""),
 	)
 
-	// Validate required fields
-	if req.DID == "" || req.MimeType == "" || req.Size <= 0 {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		err := errordefs.New(errordefs.CDV_VALIDATION, "did, mimeType, and size are required", correlationID)
-		m.writeErrorDef(w, err)
-		return
-	}
-
-	// Validate media size limit
-	if req.Size > m.maxMediaSize {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		err := errordefs.New(errordefs.CDV_MEDIA_SIZE, fmt.Sprintf("media size exceeds limit of %d bytes", m.maxMediaSize), correlationID)
-		m.writeErrorDef(w, err)
-		return
-	}
-
-	// Validate media type
-	allowed := false
-	for _, mimeType := range m.allowedMimeTypes {
-		if req.MimeType == mimeType {
-			allowed = true
-			break
-		}
-	}
-	if !allowed {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		err := errordefs.New(errordefs.CDV_MEDIA_TYPE, fmt.Sprintf("media type %s is not allowed", req.MimeType), correlationID)
-		m.writeErrorDef(w, err)
-		return
-	}
-
-	// Validate DID matches JWT subject (Phase 1 requirement)
-	jwtDID := ctx.Value(ContextKeyDID).(string)
-	if req.DID != jwtDID {
-		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		err := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
-		m.writeErrorDef(w, err)
+	asset, errDef := m.newPendingAsset(ctx, req)
+	if errDef != nil {
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
 		return
 	}
+	assetID := asset.AssetID
 
-	// Create account if it doesn't exist
-	if _, err := m.s.GetAccount(ctx, req.DID); err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			if err := m.s.CreateAccount(ctx, req.DID); err != nil {
-				correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-				err := errordefs.New(errordefs.CDV_INTERNAL, "failed to create account", correlationID)
-				m.writeErrorDef(w, err)
-				return
-			}
-		} else {
-			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-			err := errordefs.New(errordefs.CDV_INTERNAL, "failed to check account", correlationID)
-			m.writeErrorDef(w, err)
-			return
-		}
-	}
-
-	// Generate asset ID
-	assetID := uuid.New().String()
-	uri := fmt.Sprintf("at://%s/media/%s", req.DID, assetID)
-
-	// Create the media asset record
-	asset := model.MediaAsset{
-		AssetID:   assetID,
-		DID:       req.DID,
-		URI:       uri,
-		MimeType:  req.MimeType,
-		Size:      req.Size,
-		Checksum:  req.SHA256,
-		CreatedAt: time.Now().UTC(),
-	}
-
-	if err := m.s.CreateMediaAsset(ctx, asset); err != nil {
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.CreateMediaAsset")
+	err := m.s.CreateMediaAsset(assetCtx, asset)
+	endChildSpan(assetSpan, err)
+	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		if errors.Is(err, storage.ErrConflict) {
 			err := errordefs.New(errordefs.CDV_CONFLICT, "asset already exists", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
 		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to create media asset", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
 
-	// Generate object key
-	objectKey := fmt.Sprintf("%s/%s/%s", os.Getenv("CDV_ENV"), req.DID, assetID)
-	if req.Filename != "" {
-		objectKey += "/" + req.Filename
-	}
-
 	// Generate presigned URL for S3 upload
 	var uploadURL string
 	var expiresAt time.Time
 	if m.mediaClient != nil {
 		expiresAt = time.Now().Add(15 * time.Minute)
 		var err error
-		uploadURL, err = m.mediaClient.GenerateUploadURL(ctx, objectKey, 15*time.Minute)
+		presignStart := time.Now()
+		presignCtx, presignSpan := startChildSpan(ctx, "media.GenerateUploadURL")
+		uploadURL, err = m.mediaClient.GenerateUploadURL(presignCtx, asset.ObjectKey, 15*time.Minute)
+		endChildSpan(presignSpan, err)
+		m.observeMediaOperation("presign_upload", presignStart, err)
 		if err != nil {
 			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 			err := errordefs.New(errordefs.CDV_INTERNAL, "failed to generate upload URL", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
@@ -781,18 +2463,110 @@ func (m *Mux) handleUploadInit(w http.ResponseWriter, r *http.Request) {
 		expiresAt = time.Now().Add(15 * time.Minute)
 	}
 
-	// Store the object key in the asset metadata
-	asset.URI = fmt.Sprintf("s3://%s/%s", os.Getenv("CDV_S3_BUCKET"), objectKey)
-
 	response := model.UploadInitData{
 		AssetID:   assetID,
 		UploadURL: uploadURL,
 		ExpiresAt: expiresAt,
 	}
 
+	m.publishMediaUploadInitiated(ctx, asset)
 	m.writeSuccess(w, http.StatusOK, response)
 }
 
+// publishMediaUploadInitiated publishes the upload initiated event for a new asset. Like
+// other events it is best-effort: a failure is logged and the upload proceeds.
+func (m *Mux) publishMediaUploadInitiated(ctx context.Context, asset model.MediaAsset) {
+	publishCtx, publishSpan := startChildSpan(ctx, "event.PublishMediaUploadInitiated")
+	err := m.p.PublishMediaUploadInitiated(publishCtx, asset)
+	endChildSpan(publishSpan, err)
+	if err != nil {
+		slog.Warn("failed to publish media upload initiated event", "assetId", asset.AssetID, "error", err)
+	}
+}
+
+// newPendingAsset validates an upload request against the media policy and the caller,
+// creates the caller's account if needed and returns the unfinalized asset to store
+func (m *Mux) newPendingAsset(ctx context.Context, req model.UploadInitRequest) (model.MediaAsset, *errordefs.Error) {
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	// Validate required fields
+	if req.DID == "" || req.MimeType == "" || req.Size <= 0 {
+		return model.MediaAsset{}, errordefs.New(errordefs.CDV_VALIDATION, "did, mimeType, and size are required", correlationID)
+	}
+
+	// Validate media size limit
+	policy := m.policy.Load()
+	if req.Size > policy.MaxMediaSize {
+		return model.MediaAsset{}, errordefs.New(errordefs.CDV_MEDIA_SIZE, fmt.Sprintf("media size exceeds limit of %d bytes", policy.MaxMediaSize), correlationID)
+	}
+
+	// Validate media type
+	if !slices.Contains(policy.AllowedMimeTypes, req.MimeType) {
+		return model.MediaAsset{}, errordefs.New(errordefs.CDV_MEDIA_TYPE, fmt.Sprintf("media type %s is not allowed", req.MimeType), correlationID)
+	}
+
+	// Validate DID matches JWT subject (Phase 1 requirement)
+	if req.DID != ctx.Value(ContextKeyDID).(string) {
+		return model.MediaAsset{}, errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+	}
+
+	// Bound abandoned or runaway uploads; the check is not atomic with the insert,
+	// so concurrent calls may overshoot the limit slightly
+	if m.maxPendingUploads > 0 {
+		pendingCtx, pendingSpan := startChildSpan(ctx, "storage.CountPendingMediaAssets")
+		pending, err := m.s.CountPendingMediaAssets(pendingCtx, req.DID)
+		endChildSpan(pendingSpan, err)
+		if err != nil {
+			return model.MediaAsset{}, errordefs.New(errordefs.CDV_INTERNAL, "failed to check pending uploads", correlationID)
+		}
+		if pending >= m.maxPendingUploads {
+			return model.MediaAsset{}, errordefs.New(errordefs.CDV_QUOTA_EXCEEDED,
+				fmt.Sprintf("too many pending uploads (limit %d); finalize existing uploads first", m.maxPendingUploads), correlationID)
+		}
+	}
+
+	// Create account if it doesn't exist
+	accountCtx, accountSpan := startChildSpan(ctx, "storage.GetAccount")
+	_, err := m.s.GetAccount(accountCtx, req.DID)
+	endChildSpan(accountSpan, err)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return model.MediaAsset{}, errordefs.New(errordefs.CDV_INTERNAL, "failed to check account", correlationID)
+		}
+		createCtx, createSpan := startChildSpan(ctx, "storage.CreateAccount")
+		err := m.s.CreateAccount(createCtx, req.DID)
+		endChildSpan(createSpan, err)
+		if err != nil {
+			return model.MediaAsset{}, errordefs.New(errordefs.CDV_INTERNAL, "failed to create account", correlationID)
+		}
+	}
+
+	// The object key is kept on the asset so finalize and downloads find the upload
+	assetID := uuid.New().String()
+	objectKey := fmt.Sprintf("%s/%s/%s", os.Getenv("CDV_ENV"), req.DID, assetID)
+	if req.Filename != "" {
+		objectKey += "/" + req.Filename
+	}
+	return model.MediaAsset{
+		AssetID:   assetID,
+		DID:       req.DID,
+		URI:       fmt.Sprintf("at://%s/media/%s", req.DID, assetID),
+		MimeType:  req.MimeType,
+		Size:      req.Size,
+		CreatedAt: time.Now().UTC(), // Checksum is set at finalize, marking the asset finalized
+		ObjectKey: objectKey,
+	}, nil
+}
+
+// mediaObjectKey returns the storage key of an asset's object. Assets created before the
+// key was stored were uploaded under the default key, without a filename.
+func mediaObjectKey(asset *model.MediaAsset) string {
+	if asset.ObjectKey != "" {
+		return asset.ObjectKey
+	}
+	return fmt.Sprintf("%s/%s/%s", os.Getenv("CDV_ENV"), asset.DID, asset.AssetID)
+}
+
 // handleFinalize handles POST /v1/media/finalize
 func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleFinalize")
@@ -802,8 +2576,8 @@ func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 	var req model.FinalizeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "invalid JSON")
-		err := errordefs.New(errordefs.CDV_VALIDATION, "invalid JSON", correlationID)
+		err := m.jsonBodyError(err, correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
@@ -818,20 +2592,25 @@ func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 	if req.AssetID == "" || req.SHA256 == "" {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		err := errordefs.New(errordefs.CDV_VALIDATION, "assetId and sha256 are required", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
 
 	// Get the media asset
-	asset, err := m.s.GetMediaAsset(ctx, req.AssetID)
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.GetMediaAsset")
+	asset, err := m.s.GetMediaAsset(assetCtx, req.AssetID)
+	endChildSpan(assetSpan, err)
 	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		if errors.Is(err, storage.ErrNotFound) {
 			err := errordefs.New(errordefs.CDV_NOT_FOUND, "asset not found", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
 		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get media asset", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
@@ -841,19 +2620,58 @@ func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 	if asset.DID != jwtDID {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		err := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+
+	// A finalized asset is immutable: repeating the same checksum is a no-op, a new one a conflict
+	if asset.Checksum != "" {
+		if asset.Checksum == req.SHA256 {
+			m.writeSuccess(w, http.StatusOK, asset)
+			return
+		}
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_CONFLICT, "asset is already finalized with a different checksum", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+
+	// A multipart upload has no object until its parts are assembled
+	if asset.UploadID != "" {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_CONFLICT, "multipart upload is not completed; call completeMultipart first", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
 
 	// Verify object exists and checksum matches if S3 is configured
 	if m.mediaClient != nil {
-		// Extract object key from URI
-		objectKey := strings.TrimPrefix(asset.URI, fmt.Sprintf("s3://%s/", os.Getenv("CDV_S3_BUCKET")))
+		objectKey := mediaObjectKey(asset)
+		
+		// Shed load rather than queue when too many verifications are already running
+		release, ok := m.acquireVerify()
+		if !ok {
+			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+			err := errordefs.New(errordefs.CDV_UNAVAILABLE, "too many media verifications in progress, retry later", correlationID)
+			failSpan(span, err)
+			w.Header().Set("Retry-After", verifyRetryAfter)
+			m.writeErrorDef(w, err)
+			return
+		}
 		
-		valid, size, err := m.mediaClient.VerifyObject(ctx, objectKey, req.SHA256)
+		verifyStart := time.Now()
+		verifyCtx, verifySpan := startChildSpan(ctx, "media.VerifyObject")
+		valid, size, err := m.mediaClient.VerifyObject(verifyCtx, objectKey, req.SHA256)
+		release()
+		endChildSpan(verifySpan, err)
+		m.observeMediaOperation("verify", verifyStart, err)
 		if err != nil {
 			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 			err := errordefs.New(errordefs.CDV_INTERNAL, "failed to verify media object", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
@@ -861,6 +2679,7 @@ func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 		if !valid {
 			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 			err := errordefs.New(errordefs.CDV_MEDIA_CHECKSUM, "checksum verification failed", correlationID)
+			failSpan(span, err)
 			m.writeErrorDef(w, err)
 			return
 		}
@@ -871,53 +2690,220 @@ func (m *Mux) handleFinalize(w http.ResponseWriter, r *http.Request) {
 
 	// Update the asset with the checksum
 	asset.Checksum = req.SHA256
-	if err := m.s.UpdateMediaAsset(ctx, *asset); err != nil {
+	updateCtx, updateSpan := startChildSpan(ctx, "storage.UpdateMediaAsset")
+	err = m.s.UpdateMediaAsset(updateCtx, *asset)
+	endChildSpan(updateSpan, err)
+	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to update media asset", correlationID)
+		failSpan(span, err)
 		m.writeErrorDef(w, err)
 		return
 	}
 
 	// Publish media finalized event
-	if err := m.p.PublishMediaFinalized(ctx, *asset); err != nil {
+	publishCtx, publishSpan := startChildSpan(ctx, "event.PublishMediaFinalized")
+	err = m.p.PublishMediaFinalized(publishCtx, *asset)
+	endChildSpan(publishSpan, err)
+	if err != nil {
 		slog.Warn("failed to publish media finalized event", "error", err)
 	}
 
 	m.writeSuccess(w, http.StatusOK, asset)
 }
 
-// handleGetMediaMeta handles GET /v1/media/:assetId/meta
-func (m *Mux) handleGetMediaMeta(w http.ResponseWriter, r *http.Request) {
-	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetMediaMeta")
+// handleListMediaAssets handles GET /v1/media/list
+func (m *Mux) handleListMediaAssets(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleListMediaAssets")
 	defer span.End()
 	
-	// Extract assetId from path
-	path := strings.TrimPrefix(r.URL.Path, "/v1/media/")
-	assetID := strings.TrimSuffix(path, "/meta")
-
-	if assetID == "" {
+	// Assets are only ever listed for the authenticated DID
+	did := ctx.Value(ContextKeyDID).(string)
+	span.SetAttributes(
+		attribute.String("did", did),
+	)
+	
+	limit := DefaultListLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if v, err := strconv.Atoi(limitStr); err == nil {
+			if v > 0 && v <= MaxListLimit {
+				limit = v
+			} else if v > MaxListLimit {
+				limit = MaxListLimit
+			}
+		}
+	}
+	
+	query := model.ListMediaAssetsQuery{
+		DID:    did,
+		Limit:  limit,
+		Cursor: r.URL.Query().Get("cursor"),
+		CursorIssuedAfter: m.cursorIssuedAfter(),
+	}
+	
+	// Only a whole-type wildcard (image/*) is supported; */* is the same as no filter
+	if mimeType := r.URL.Query().Get("mimeType"); mimeType != "" && mimeType != "*/*" {
+		prefix, wildcard := strings.CutSuffix(mimeType, "/*")
+		if strings.Contains(prefix, "*") || (!wildcard && !strings.Contains(mimeType, "/")) {
+			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+			err := errordefs.New(errordefs.CDV_VALIDATION, "mimeType must be a MIME type or a type wildcard such as image/*", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		query.MimeType = mimeType
+		span.SetAttributes(attribute.String("mimeType", mimeType))
+	}
+	if finalizedStr := r.URL.Query().Get("finalized"); finalizedStr != "" {
+		finalized, err := strconv.ParseBool(finalizedStr)
+		if err != nil {
+			correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+			err := errordefs.New(errordefs.CDV_VALIDATION, "finalized must be true or false", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		query.Finalized = &finalized
+		span.SetAttributes(attribute.Bool("finalized", finalized))
+	}
+	
+	var err error
+	if query.Cursor, err = m.openCursor(query.Cursor); err != nil {
+		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+		err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
+		return
+	}
+	
+	listCtx, listSpan := startChildSpan(ctx, "storage.ListMediaAssets")
+	assets, nextCursor, err := m.s.ListMediaAssets(listCtx, query)
+	endChildSpan(listSpan, err)
+	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
-		span.SetStatus(codes.Error, "assetId is required")
-		m.writeError(w, http.StatusBadRequest, "CDV_VALIDATION", "assetId is required", correlationID, nil)
+		if strings.Contains(err.Error(), "invalid cursor") {
+			err := errordefs.New(errordefs.CDV_CURSOR_INVALID, err.Error(), correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
+			return
+		}
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to list media assets", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	m.writeSuccess(w, http.StatusOK, model.ListMediaAssetsResult{
+		Assets:     assets,
+		NextCursor: m.signCursor(nextCursor),
+	})
+}
+
+// mediaDownloadURLTTL is how long presigned download URLs stay valid
+const mediaDownloadURLTTL = 15 * time.Minute
+
+// handleDownloadMedia handles GET /v1/media/{assetId}/download, redirecting the asset's
+// owner to a presigned URL for the object
+func (m *Mux) handleDownloadMedia(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleDownloadMedia")
+	defer span.End()
+	correlationID := ctx.Value(ContextKeyCorrelationID).(string)
+	
+	assetID := r.PathValue("assetId")
+	span.SetAttributes(attribute.String("assetId", assetID))
+	
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.GetMediaAsset")
+	asset, err := m.s.GetMediaAsset(assetCtx, assetID)
+	endChildSpan(assetSpan, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to get media asset", correlationID)
+		if errors.Is(err, storage.ErrNotFound) {
+			errDef = errordefs.New(errordefs.CDV_NOT_FOUND, "asset not found", correlationID)
+		}
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	// Media may be private, so only its owner may download it
+	if asset.DID != ctx.Value(ContextKeyDID).(string) {
+		errDef := errordefs.New(errordefs.CDV_DID_MISMATCH, "DID must match JWT subject", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	// Until finalize verifies the checksum the object may be missing or incomplete
+	if asset.Checksum == "" {
+		errDef := errordefs.New(errordefs.CDV_CONFLICT, "asset is not finalized", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	if m.mediaClient == nil {
+		errDef := errordefs.New(errordefs.CDV_NOT_IMPLEMENTED, "media downloads require S3 storage", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
+		return
+	}
+	
+	objectKey := mediaObjectKey(asset)
+	presignStart := time.Now()
+	presignCtx, presignSpan := startChildSpan(ctx, "media.GenerateDownloadURL")
+	downloadURL, err := m.mediaClient.GenerateDownloadURL(presignCtx, objectKey, asset.MimeType, mediaDownloadURLTTL)
+	endChildSpan(presignSpan, err)
+	m.observeMediaOperation("presign_download", presignStart, err)
+	if err != nil {
+		errDef := errordefs.New(errordefs.CDV_INTERNAL, "failed to generate download URL", correlationID)
+		failSpan(span, errDef)
+		m.writeErrorDef(w, errDef)
 		return
 	}
 	
+	// The redirect may be cached only while the presigned URL it points to is valid
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int((mediaDownloadURLTTL-time.Minute).Seconds())))
+	if asset.MimeType != "" {
+		w.Header().Set("Content-Type", asset.MimeType)
+	}
+	http.Redirect(w, r, downloadURL, http.StatusFound)
+}
+
+// handleGetMediaMeta handles GET /v1/media/{assetId}/meta
+func (m *Mux) handleGetMediaMeta(w http.ResponseWriter, r *http.Request) {
+	ctx, span := otel.Tracer("cdv-service").Start(r.Context(), "handleGetMediaMeta")
+	defer span.End()
+	
+	// The route pattern guarantees a non-empty assetId
+	assetID := r.PathValue("assetId")
+	
 	// Add request attributes to span
 	span.SetAttributes(
 		attribute.String("assetId", assetID),
 	)
 
 	// Get the media asset
-	asset, err := m.s.GetMediaAsset(ctx, assetID)
+	assetCtx, assetSpan := startChildSpan(ctx, "storage.GetMediaAsset")
+	asset, err := m.s.GetMediaAsset(assetCtx, assetID)
+	endChildSpan(assetSpan, err)
 	if err != nil {
 		correlationID := ctx.Value(ContextKeyCorrelationID).(string)
 		if errors.Is(err, storage.ErrNotFound) {
-			m.writeError(w, http.StatusNotFound, "CDV_NOT_FOUND", "asset not found", correlationID, nil)
+			err := errordefs.New(errordefs.CDV_NOT_FOUND, "asset not found", correlationID)
+			failSpan(span, err)
+			m.writeErrorDef(w, err)
 			return
 		}
-		m.writeError(w, http.StatusInternalServerError, "CDV_INTERNAL", "failed to get media asset", correlationID, nil)
+		err := errordefs.New(errordefs.CDV_INTERNAL, "failed to get media asset", correlationID)
+		failSpan(span, err)
+		m.writeErrorDef(w, err)
 		return
 	}
 
+	// Finalized assets are content-addressed and never change; pending ones change on finalize.
+	// The response is authenticated, so only the client (not shared caches) may keep it.
+	if asset.Checksum != "" {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
 	m.writeSuccess(w, http.StatusOK, asset)
 }
@@ -0,0 +1,280 @@
+// internal/server/openapi.go
+// OpenAPI 3 document generation for the CDV HTTP API.
+// The document is built from the same route table used to register handlers,
+// and request/response schemas are derived from the model package by reflection,
+// so the published spec cannot silently drift from the running service. The copy
+// checked in as api/openapi.json is regenerated with go generate.
+package server
+
+//go:generate go test -run TestOpenAPISpecFile -args -update-openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+)
+
+// apiParam describes a path or query parameter of an API route
+type apiParam struct {
+	Name     string // Parameter name
+	In       string // "path" or "query"
+	Type     string // JSON schema type (string, integer)
+	Required bool   // Whether the parameter is required
+	Desc     string // Human-readable description
+}
+
+// apiRoute describes a registered API endpoint for routing and the OpenAPI document
+type apiRoute struct {
+	Method   string      // HTTP method
+	Pattern  string      // Path pattern, used both for http.ServeMux (with Method) and as the OpenAPI path template
+	Summary  string      // Short description of the operation
+	Auth     bool        // Whether a bearer JWT is required
+	OptionalAuth bool    // Whether a bearer JWT is accepted, identifying the caller, but not required
+	Params   []apiParam  // Path and query parameters
+	Request  interface{} // Request body model (nil for none)
+	Response interface{} // Success response data model, wrapped in {"data": ...}
+}
+
+// handleAPI registers an API handler for its method and pattern with middleware,
+// and records the route so it is included in the OpenAPI document
+func (m *Mux) handleAPI(rt apiRoute, h http.HandlerFunc) {
+	if rt.Auth {
+		m.requiredAuthRoutes[rt.Method+" "+rt.Pattern] = true
+	}
+	if rt.OptionalAuth {
+		m.optionalAuthPaths[rt.Pattern] = true
+		// Document public reads as secured when the deployment requires authenticated reads
+		if m.requireAuthReads {
+			rt.Auth, rt.OptionalAuth = true, false
+		}
+	}
+	m.routes = append(m.routes, rt)
+	if rt.Request != nil {
+		h = m.requireContentType(h)
+	}
+	m.route(rt.Method, rt.Pattern, m.withMiddleware(h))
+}
+
+// handleOpenAPI handles GET /openapi.json
+func (m *Mux) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(m.openAPISpec)
+}
+
+// openAPIDocument builds the OpenAPI 3 document for the registered routes
+func (m *Mux) openAPIDocument() map[string]interface{} {
+	schemas := map[string]interface{}{
+		"Error": errorSchema(),
+	}
+	paths := map[string]interface{}{}
+
+	for _, rt := range m.routes {
+		op := map[string]interface{}{
+			"summary":     rt.Summary,
+			"operationId": operationID(rt),
+		}
+
+		params := make([]interface{}, 0, len(rt.Params))
+		for _, p := range rt.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required || p.In == "path",
+				"description": p.Desc,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaRef(reflect.TypeOf(rt.Request), schemas),
+					},
+				},
+			}
+		}
+
+		success := map[string]interface{}{"type": "object"}
+		if rt.Response != nil {
+			success["properties"] = map[string]interface{}{
+				"data": schemaRef(reflect.TypeOf(rt.Response), schemas),
+			}
+		}
+		op["responses"] = map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Success",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": success},
+				},
+			},
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		}
+
+		if rt.Auth {
+			op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []interface{}{}}}
+		} else if rt.OptionalAuth {
+			op["security"] = []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []interface{}{}}}
+		}
+
+		path := rt.Pattern
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "RegistryAccord Creator Data Vault API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+// operationID derives a stable operation ID from the route's method and path
+func operationID(rt apiRoute) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(rt.Method))
+	for _, part := range strings.Split(rt.Pattern, "/") {
+		part = strings.Trim(part, "{}")
+		if part == "" || part == "v1" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// errorSchema describes the standard error envelope, enumerating every CDV error code
+func errorSchema() map[string]interface{} {
+	codes := make([]interface{}, 0, len(errordefs.Codes))
+	for _, code := range errordefs.Codes {
+		codes = append(codes, string(code))
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"code", "message", "correlationId"},
+				"properties": map[string]interface{}{
+					"code":          map[string]interface{}{"type": "string", "enum": codes},
+					"message":       map[string]interface{}{"type": "string"},
+					"correlationId": map[string]interface{}{"type": "string"},
+					"details":       map[string]interface{}{},
+				},
+			},
+		},
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRef returns a JSON schema for t, registering named struct types
+// under components/schemas and referencing them by $ref
+func schemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map, reflect.Interface:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		name := t.Name()
+		if _, exists := schemas[name]; !exists {
+			// Reserve the name first so self-referencing types terminate
+			schemas[name] = map[string]interface{}{}
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema builds an object schema from a struct's exported, JSON-tagged fields.
+// Fields without omitempty are listed as required.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaRef(field.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,111 @@
+// internal/server/openapi_test.go
+// Tests that the served OpenAPI document stays in sync with the registered handlers.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	errordefs "github.com/RegistryAccord/registryaccord-cdv-go/internal/errors"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/jwks"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/storage"
+)
+
+// updateOpenAPI rewrites the checked-in spec from the route table; go generate sets it
+var updateOpenAPI = flag.Bool("update-openapi", false, "rewrite api/openapi.json from the route table")
+
+// openAPISpecFile is the checked-in copy of the document served at /openapi.json
+const openAPISpecFile = "../../api/openapi.json"
+
+// TestOpenAPIDocument tests that every registered route is documented and served,
+// that every schema reference resolves, and that all error codes are enumerated.
+func TestOpenAPIDocument(t *testing.T) {
+	m := newMux(storage.NewMemory(), &mockPublisher{}, nil, "test-issuer", "test-audience", 10*1024*1024, []string{"image/jpeg"}, jwks.NewTestClient(), "https://raw.githubusercontent.com/RegistryAccord/registryaccord-specs/main/schemas", false)
+
+	rr := httptest.NewRecorder()
+	m.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid OpenAPI JSON: %v", err)
+	}
+
+	// Every registered route must be documented and reachable through the mux
+	documented := 0
+	for _, rt := range m.routes {
+		path := rt.Pattern
+		if _, ok := doc.Paths[path][strings.ToLower(rt.Method)]; !ok {
+			t.Errorf("route %s %s missing from OpenAPI document", rt.Method, path)
+		}
+		documented++
+
+		target := strings.NewReplacer("{", "", "}", "").Replace(path)
+		rr := httptest.NewRecorder()
+		m.mux.ServeHTTP(rr, httptest.NewRequest(rt.Method, target, strings.NewReader("{}")))
+		if rr.Code == http.StatusNotFound && strings.HasPrefix(rr.Body.String(), "404 page not found") {
+			t.Errorf("documented route %s %s is not served", rt.Method, path)
+		}
+	}
+
+	// The document must not describe operations that have no registered handler
+	operations := 0
+	for _, item := range doc.Paths {
+		operations += len(item)
+	}
+	if operations != documented {
+		t.Errorf("OpenAPI document has %d operations, but %d routes are registered", operations, documented)
+	}
+
+	// Every $ref must point at a defined schema
+	for _, ref := range strings.Split(rr.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("unresolved schema reference %q", name)
+		}
+	}
+
+	for _, code := range errordefs.Codes {
+		if !strings.Contains(string(doc.Components.Schemas["Error"]), `"`+string(code)+`"`) {
+			t.Errorf("error code %s missing from Error schema", code)
+		}
+	}
+}
+
+// TestOpenAPISpecFile tests that the checked-in api/openapi.json matches the document
+// generated from the route table. Run go generate ./internal/server after changing routes.
+func TestOpenAPISpecFile(t *testing.T) {
+	m := newMux(storage.NewMemory(), &mockPublisher{}, nil, "test-issuer", "test-audience", 10*1024*1024, []string{"image/jpeg"}, jwks.NewTestClient(), "https://raw.githubusercontent.com/RegistryAccord/registryaccord-specs/main/schemas", false)
+	want, err := json.MarshalIndent(m.openAPIDocument(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, '\n')
+
+	if *updateOpenAPI {
+		if err := os.WriteFile(openAPISpecFile, want, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	got, err := os.ReadFile(openAPISpecFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("api/openapi.json is out of date with the route table; run go generate ./internal/server")
+	}
+}
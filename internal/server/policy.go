@@ -0,0 +1,49 @@
+// internal/server/policy.go
+// Request policy settings that can be changed on a running server (e.g. on SIGHUP)
+// without reconnecting to storage or the event backend.
+package server
+
+import "sync"
+
+// Policy holds the reloadable request policy
+type Policy struct {
+	MaxMediaSize            int64    // Maximum media size in bytes
+	AllowedMimeTypes        []string // Allowed MIME types for media uploads
+	CORSAllowedOrigins      []string // Allowed origins for CORS (empty means deny all)
+	RejectDeprecatedSchemas bool     // Whether records using a deprecated schema version are rejected
+}
+
+// PolicyHolder shares the current Policy between the server and whoever reloads it
+type PolicyHolder struct {
+	mu     sync.RWMutex
+	policy Policy
+}
+
+// NewPolicyHolder creates a holder with an initial policy
+func NewPolicyHolder(p Policy) *PolicyHolder {
+	return &PolicyHolder{policy: p}
+}
+
+// Load returns the current policy. Callers must not modify its slices.
+func (h *PolicyHolder) Load() Policy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.policy
+}
+
+// Store replaces the policy; requests already in flight keep the policy they loaded
+func (h *PolicyHolder) Store(p Policy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.policy = p
+}
+
+// WithPolicy makes the mux read its policy from h, so it can be updated at runtime.
+// It replaces the policy built from the NewMux arguments.
+func WithPolicy(h *PolicyHolder) Option {
+	return func(m *Mux) {
+		if h != nil {
+			m.policy = h
+		}
+	}
+}
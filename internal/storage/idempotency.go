@@ -0,0 +1,56 @@
+// internal/storage/idempotency.go
+// Package storage provides the IdempotencyStore interface, which keeps cached idempotent
+// responses either in the primary Store or in a separate backend such as Redis.
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyStore keeps the responses of requests made with an idempotency key
+type IdempotencyStore interface {
+	// Reserve claims an unused or expired key for a request about to run, with a pending
+	// entry that lasts until expiresAt. It returns nil when the caller now holds the key,
+	// and the existing entry otherwise, which may itself be pending.
+	Reserve(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error)
+	// Release drops a pending entry claimed for requestHash, so a failed request can be retried
+	Release(ctx context.Context, keyHash, requestHash string) error
+	// Store caches a response until expiresAt; ErrConflict if an unexpired response for the
+	// key was stored for a different request. A finished response is never overwritten, so
+	// every backend replays the first one.
+	Store(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error
+	// Get returns the cached response for a key; ErrNotFound if missing or expired
+	Get(ctx context.Context, keyHash string) (*IdempotentResponse, error)
+}
+
+// storeIdempotency adapts the idempotency methods of a Store to IdempotencyStore
+type storeIdempotency struct {
+	s Store // Store holding the responses
+}
+
+// StoreIdempotency returns an IdempotencyStore keeping responses in s, the default when no
+// separate idempotency backend is configured
+func StoreIdempotency(s Store) IdempotencyStore {
+	return storeIdempotency{s: s}
+}
+
+// Reserve implements IdempotencyStore
+func (i storeIdempotency) Reserve(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error) {
+	return i.s.ReserveIdempotencyKey(ctx, keyHash, requestHash, expiresAt)
+}
+
+// Release implements IdempotencyStore
+func (i storeIdempotency) Release(ctx context.Context, keyHash, requestHash string) error {
+	return i.s.ReleaseIdempotencyKey(ctx, keyHash, requestHash)
+}
+
+// Store implements IdempotencyStore
+func (i storeIdempotency) Store(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
+	return i.s.StoreIdempotentResponse(ctx, keyHash, requestHash, responseBody, statusCode, expiresAt)
+}
+
+// Get implements IdempotencyStore
+func (i storeIdempotency) Get(ctx context.Context, keyHash string) (*IdempotentResponse, error) {
+	return i.s.GetIdempotentResponse(ctx, keyHash)
+}
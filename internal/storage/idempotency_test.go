@@ -0,0 +1,158 @@
+// internal/storage/idempotency_test.go
+// Package storage provides tests that every IdempotencyStore implementation must pass.
+// Redis runs when CDV_TEST_REDIS_URL is set.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testIdempotencyStores returns the idempotency stores to run the shared tests against
+func testIdempotencyStores(t *testing.T) map[string]IdempotencyStore {
+	t.Helper()
+	stores := map[string]IdempotencyStore{}
+	for name, store := range testStores(t) {
+		stores[name] = StoreIdempotency(store)
+	}
+	if url := os.Getenv("CDV_TEST_REDIS_URL"); url != "" {
+		r, err := NewRedisIdempotency(context.Background(), url)
+		if err != nil {
+			t.Fatalf("redis: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		stores["redis"] = r
+	}
+	return stores
+}
+
+// TestIdempotencyStore tests that every idempotency store returns the stored response,
+// reports missing keys as ErrNotFound, rejects a different request for the same key and
+// keeps the first response when the same request is stored again.
+func TestIdempotencyStore(t *testing.T) {
+	for name, store := range testIdempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// A fresh key per run keeps reruns against a shared backend independent
+			keyHash := fmt.Sprintf("key%d", time.Now().UnixNano())
+			expiresAt := time.Now().UTC().Add(time.Hour)
+
+			if _, err := store.Get(ctx, keyHash); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get before Store: expected ErrNotFound, got %v", err)
+			}
+			if err := store.Store(ctx, keyHash, "request-a", []byte(`{"data":1}`), 200, expiresAt); err != nil {
+				t.Fatal(err)
+			}
+			cached, err := store.Get(ctx, keyHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached.RequestHash != "request-a" || string(cached.ResponseBody) != `{"data":1}` || cached.StatusCode != 200 {
+				t.Errorf("unexpected cached response: %+v", cached)
+			}
+
+			if err := store.Store(ctx, keyHash, "request-b", []byte(`{"data":2}`), 200, expiresAt); !errors.Is(err, ErrConflict) {
+				t.Errorf("Store with a different request: expected ErrConflict, got %v", err)
+			}
+			if err := store.Store(ctx, keyHash, "request-a", []byte(`{"data":3}`), 201, expiresAt); err != nil {
+				t.Errorf("Store with the same request: %v", err)
+			}
+			cached, err = store.Get(ctx, keyHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached.RequestHash != "request-a" || string(cached.ResponseBody) != `{"data":1}` || cached.StatusCode != 200 {
+				t.Errorf("got cached %q %s %d, want the first response", cached.RequestHash, cached.ResponseBody, cached.StatusCode)
+			}
+		})
+	}
+}
+
+// TestIdempotencyReserve tests that every idempotency store lets one request claim a key,
+// reports the pending claim to the next one, frees it on Release and replaces it on Store.
+func TestIdempotencyReserve(t *testing.T) {
+	for name, store := range testIdempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			keyHash := fmt.Sprintf("reserve%d", time.Now().UnixNano())
+			expiresAt := time.Now().UTC().Add(time.Hour)
+
+			if existing, err := store.Reserve(ctx, keyHash, "request-a", expiresAt); err != nil || existing != nil {
+				t.Fatalf("first Reserve: got %+v, %v want nil, nil", existing, err)
+			}
+			existing, err := store.Reserve(ctx, keyHash, "request-a", expiresAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if existing == nil || !existing.Pending() || existing.RequestHash != "request-a" {
+				t.Fatalf("second Reserve: got %+v want the pending claim", existing)
+			}
+
+			// Releasing for another request leaves the claim in place
+			if err := store.Release(ctx, keyHash, "request-b"); err != nil {
+				t.Fatal(err)
+			}
+			if existing, _ := store.Reserve(ctx, keyHash, "request-b", expiresAt); existing == nil {
+				t.Fatal("Reserve after releasing another request: claim was dropped")
+			}
+			if err := store.Release(ctx, keyHash, "request-a"); err != nil {
+				t.Fatal(err)
+			}
+			if existing, err := store.Reserve(ctx, keyHash, "request-a", expiresAt); err != nil || existing != nil {
+				t.Fatalf("Reserve after Release: got %+v, %v want nil, nil", existing, err)
+			}
+
+			if err := store.Store(ctx, keyHash, "request-a", []byte(`{"data":1}`), 200, expiresAt); err != nil {
+				t.Fatal(err)
+			}
+			existing, err = store.Reserve(ctx, keyHash, "request-a", expiresAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if existing == nil || existing.Pending() || string(existing.ResponseBody) != `{"data":1}` {
+				t.Errorf("Reserve after Store: got %+v want the stored response", existing)
+			}
+			// A finished response is never released
+			if err := store.Release(ctx, keyHash, "request-a"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(ctx, keyHash); err != nil {
+				t.Errorf("Get after releasing a finished response: %v", err)
+			}
+		})
+	}
+}
+
+// TestIdempotencyStoreExpired tests that every idempotency store lets a key be reused
+// with a different request once its entry has expired.
+func TestIdempotencyStoreExpired(t *testing.T) {
+	for name, store := range testIdempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			keyHash := fmt.Sprintf("expired%d", time.Now().UnixNano())
+
+			if err := store.Store(ctx, keyHash, "request-a", []byte(`{"data":1}`), 200, time.Now().UTC().Add(100*time.Millisecond)); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(200 * time.Millisecond)
+
+			if _, err := store.Get(ctx, keyHash); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after expiry: expected ErrNotFound, got %v", err)
+			}
+			if err := store.Store(ctx, keyHash, "request-b", []byte(`{"data":2}`), 200, time.Now().UTC().Add(time.Hour)); err != nil {
+				t.Fatalf("Store with a different request after expiry: %v", err)
+			}
+			cached, err := store.Get(ctx, keyHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached.RequestHash != "request-b" || string(cached.ResponseBody) != `{"data":2}` {
+				t.Errorf("got cached %q %s, want the new response", cached.RequestHash, cached.ResponseBody)
+			}
+		})
+	}
+}
@@ -9,7 +9,9 @@
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,44 +25,102 @@
 	ErrConflict  = errors.New("conflict")   // Returned when a record already exists
 )
 
+// Conflicting fields reported by ConflictError for records
+const (
+	ConflictFieldURI  = "uri"                  // Another record already has the same URI
+	ConflictFieldRKey = "did,collection,rkey" // Another record already has the same (did, collection, rkey)
+	ConflictFieldID   = "id"                   // Another record already has the same ID
+)
+
+// ConflictError is a conflict that identifies which unique field collided.
+// It matches ErrConflict with errors.Is, so existing conflict checks keep working.
+type ConflictError struct {
+	Field string // Name of the conflicting field or field tuple
+}
+
+// Error implements the error interface.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict on %s", e.Field)
+}
+
+// Is reports whether target is ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // Store interface defines the storage operations required by the CDV service.
 // This interface is implemented by both in-memory and PostgreSQL storage backends.
 type Store interface {
 	// Record operations for managing user-generated content
 	CreateRecord(ctx context.Context, record model.Record) error                    // Create a new record
 	ListRecords(ctx context.Context, query model.ListRecordsQuery) (*model.ListRecordsResult, error) // List records with filtering
+	CountRecords(ctx context.Context, query model.ListRecordsQuery) (int64, error)  // Count records matching the filters; Limit and Cursor are ignored
+	CollectionStats(ctx context.Context, did string, includePrivate bool) ([]model.CollectionStats, error) // Count a DID's records per collection with their schema versions, sorted by collection
 	GetRecordByURI(ctx context.Context, uri string) (*model.Record, error)         // Get a record by its URI
+	GetRecordsByURIs(ctx context.Context, uris []string) (map[string]*model.Record, error) // Get the records that exist among uris, keyed by URI
+	UpdateRecord(ctx context.Context, record model.Record) error                    // Replace the value, CID, indexed time, schema version and visibility of the record at record.URI
+	DeleteRecord(ctx context.Context, uri string) error                            // Delete a record by its URI; ErrNotFound if absent
+	ListBacklinks(ctx context.Context, query model.BacklinksQuery) (*model.ListRecordsResult, error) // List public records of any DID referring to a subject, newest first
+	ListOperations(ctx context.Context, query model.ListOperationsQuery) (*model.ListOperationsResult, error) // List a DID's operation log entries in sequence order
+	AppendOperation(ctx context.Context, entry model.OperationLogEntry) error     // Append an operation log entry; record and media mutations log their own
 	
 	// Media operations for managing media assets
 	CreateMediaAsset(ctx context.Context, asset model.MediaAsset) error            // Create a new media asset
 	GetMediaAsset(ctx context.Context, assetId string) (*model.MediaAsset, error)  // Get a media asset by ID
 	UpdateMediaAsset(ctx context.Context, asset model.MediaAsset) error            // Update an existing media asset
+	DeleteMediaAsset(ctx context.Context, assetID string) error                    // Delete a media asset; ErrNotFound if missing
+	CountPendingMediaAssets(ctx context.Context, did string) (int64, error)        // Count a DID's unfinalized media assets
+	ListMediaAssets(ctx context.Context, query model.ListMediaAssetsQuery) ([]model.MediaAsset, string, error) // List a DID's media assets, newest first
 	
 	// Account operations for managing user accounts
 	CreateAccount(ctx context.Context, did string) error                           // Create a new account
+	CreateAccounts(ctx context.Context, dids []string) ([]bool, error)              // Create accounts atomically; reports per DID whether it was created or already existed
 	GetAccount(ctx context.Context, did string) (*model.Account, error)            // Get an account by DID
+	GetAccountSettings(ctx context.Context, did string) (map[string]interface{}, error) // Get an account's settings; ErrNotFound if the account is missing
+	UpdateAccountSettings(ctx context.Context, did string, settings map[string]interface{}) error // Replace an account's settings; ErrNotFound if the account is missing
 	
 	// Idempotency operations
-	StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error // Store idempotent response
-	GetIdempotentResponse(ctx context.Context, keyHash string) ([]byte, int, error) // Get cached idempotent response
+	ReserveIdempotencyKey(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error) // Claim an unused or expired key until expiresAt; otherwise return its entry
+	ReleaseIdempotencyKey(ctx context.Context, keyHash, requestHash string) error // Drop a pending claim made for requestHash
+	StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error // Store idempotent response; ErrConflict if an unexpired one exists for a different request, a finished one is kept
+	GetIdempotentResponse(ctx context.Context, keyHash string) (*IdempotentResponse, error) // Get cached idempotent response
+	DeleteExpiredIdempotentResponses(ctx context.Context) (int64, error) // Delete expired idempotency entries, returning how many were deleted
+	
+	// Token replay operations
+	MarkTokenUsed(ctx context.Context, tokenHash string, expiresAt time.Time) error // Record a token ID as used; ErrConflict if already used and unexpired
+	DeleteExpiredTokens(ctx context.Context) (int64, error) // Delete expired used token IDs, returning how many were deleted
+	
+	// Integrity operations
+	CheckConsistency(ctx context.Context, sampleLimit int) (*model.ConsistencyReport, error) // Find records and media assets without an account
 }
 
 // IdempotentResponse represents a cached idempotent response
 type IdempotentResponse struct {
+	RequestHash  string    // Hash of the request payload the response was stored for
 	ResponseBody []byte    // Cached response body
 	StatusCode   int       // HTTP status code
 	ExpiresAt    time.Time // When the entry expires
 }
 
+// Pending reports whether the entry is a claim whose request has not finished yet
+func (r *IdempotentResponse) Pending() bool {
+	return r.StatusCode == 0
+}
+
 // memory implements the Store interface using in-memory storage.
 // It's intended for development and testing purposes.
 type memory struct {
 	mu         sync.RWMutex              // Protects concurrent access to maps
 	accounts   map[string]*model.Account // Map of DID to account
+	accountSettings map[string]map[string]interface{} // Map of DID to account settings
 	records    map[string]*model.Record  // Map of URI to record
+	recordIDs  map[string]bool           // IDs of stored records, unique like the postgres primary key
 	mediaAssets map[string]*model.MediaAsset // Map of asset ID to media asset
 	recordsByDID map[string][]*model.Record // Map of DID to records for efficient listing
 	idempotency map[string]*IdempotentResponse // Map of key hash to idempotent responses
+	usedTokens  map[string]time.Time           // Map of token ID hash to expiry
+	opLog       []model.OperationLogEntry      // Append-only operation log; an entry's sequence is its position plus one
+	opLogFault  func(model.OperationLogEntry) error // Fails operation log appends in tests; nil otherwise
 }
 
 // NewMemory creates a new in-memory storage implementation.
@@ -68,10 +128,13 @@ type memory struct {
 func NewMemory() Store {
 	return &memory{
 		accounts:     make(map[string]*model.Account),
+		accountSettings: make(map[string]map[string]interface{}),
 		records:      make(map[string]*model.Record),
+		recordIDs:    make(map[string]bool),
 		mediaAssets:  make(map[string]*model.MediaAsset),
 		recordsByDID: make(map[string][]*model.Record),
 		idempotency:  make(map[string]*IdempotentResponse),
+		usedTokens:   make(map[string]time.Time),
 	}
 }
 
@@ -90,6 +153,51 @@ func (m *memory) CreateAccount(ctx context.Context, did string) error {
 	return nil
 }
 
+func (m *memory) CreateAccounts(ctx context.Context, dids []string) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	created := make([]bool, len(dids))
+	now := time.Now().UTC()
+	for i, did := range dids {
+		if _, exists := m.accounts[did]; exists {
+			continue
+		}
+		m.accounts[did] = &model.Account{DID: did, CreatedAt: now}
+		created[i] = true
+	}
+	return created, nil
+}
+
+func (m *memory) GetAccountSettings(ctx context.Context, did string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	if _, exists := m.accounts[did]; !exists {
+		return nil, ErrNotFound
+	}
+	settings := make(map[string]interface{}, len(m.accountSettings[did]))
+	for k, v := range m.accountSettings[did] {
+		settings[k] = v
+	}
+	return settings, nil
+}
+
+func (m *memory) UpdateAccountSettings(ctx context.Context, did string, settings map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if _, exists := m.accounts[did]; !exists {
+		return ErrNotFound
+	}
+	stored := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		stored[k] = v
+	}
+	m.accountSettings[did] = stored
+	return nil
+}
+
 func (m *memory) GetAccount(ctx context.Context, did string) (*model.Account, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -110,14 +218,29 @@ func (m *memory) CreateRecord(ctx context.Context, record model.Record) error {
 		return errors.New("account not found")
 	}
 	
-	// Check if record already exists
+	// Check if record already exists, mirroring the postgres unique constraints in the
+	// order postgres checks them, so both stores report the same conflicting field
+	if m.recordIDs[record.ID] {
+		return &ConflictError{Field: ConflictFieldID}
+	}
 	if _, exists := m.records[record.URI]; exists {
-		return ErrConflict
+		return &ConflictError{Field: ConflictFieldURI}
+	}
+	for _, existing := range m.recordsByDID[record.DID] {
+		if existing.Collection == record.Collection && existing.RKey == record.RKey {
+			return &ConflictError{Field: ConflictFieldRKey}
+		}
+	}
+	
+	// Log the operation first, so a failure leaves nothing stored, like a rolled back transaction
+	if err := m.appendOp(recordOperation("record.create", record.URI, record.DID, record.Collection, record.CID, record.SchemaVersion)); err != nil {
+		return err
 	}
 	
 	// Store the record
 	recordCopy := record
 	m.records[record.URI] = &recordCopy
+	m.recordIDs[record.ID] = true
 	m.recordsByDID[record.DID] = append(m.recordsByDID[record.DID], &recordCopy)
 	return nil
 }
@@ -126,31 +249,48 @@ func (m *memory) CreateRecord(ctx context.Context, record model.Record) error {
 type memoryCursorData struct {
 	LastIndexedAt time.Time // Timestamp of the last record
 	LastRKey      string    // RKey of the last record
+	Filters       string    `json:",omitempty"` // Filter hash of the query the cursor was issued for
+	IssuedAt      time.Time `json:",omitempty"` // When the cursor was issued
+}
+
+// errCursorFilters is returned when a cursor is replayed against a query with different filters
+var errCursorFilters = errors.New("invalid cursor: issued for a query with different filters")
+
+// errCursorExpired is returned for cursors issued before the query's CursorIssuedAfter
+var errCursorExpired = errors.New("invalid cursor: expired; restart paging without a cursor")
+
+// cursorExpired reports whether a cursor issued at issuedAt is too old for a query that
+// accepts cursors issued after notBefore. Cursors from before issue times were recorded
+// have a zero issuedAt and expire whenever expiry is enabled.
+func cursorExpired(issuedAt, notBefore time.Time) bool {
+	return !notBefore.IsZero() && issuedAt.Before(notBefore)
 }
 
 // encodeMemoryCursor encodes cursor data into a base64 string
-func encodeMemoryCursor(lastIndexedAt time.Time, lastRKey string) string {
+func encodeMemoryCursor(lastIndexedAt time.Time, lastRKey, filters string) string {
 	data := memoryCursorData{
 		LastIndexedAt: lastIndexedAt,
 		LastRKey:      lastRKey,
+		Filters:       filters,
+		IssuedAt:      time.Now().UTC(),
 	}
 	jsonBytes, _ := json.Marshal(data)
 	return base64.URLEncoding.EncodeToString(jsonBytes)
 }
 
 // decodeMemoryCursor decodes a base64 cursor string into cursor data
-func decodeMemoryCursor(cursor string) (time.Time, string, error) {
+func decodeMemoryCursor(cursor string) (*memoryCursorData, error) {
 	dataBytes, err := base64.URLEncoding.DecodeString(cursor)
 	if err != nil {
-		return time.Time{}, "", fmt.Errorf("invalid cursor format: %w", err)
+		return nil, fmt.Errorf("invalid cursor format: %w", err)
 	}
 	
 	var data memoryCursorData
 	if err := json.Unmarshal(dataBytes, &data); err != nil {
-		return time.Time{}, "", fmt.Errorf("invalid cursor data: %w", err)
+		return nil, fmt.Errorf("invalid cursor data: %w", err)
 	}
 	
-	return data.LastIndexedAt, data.LastRKey, nil
+	return &data, nil
 }
 
 func (m *memory) ListRecords(ctx context.Context, query model.ListRecordsQuery) (*model.ListRecordsResult, error) {
@@ -165,9 +305,22 @@ func (m *memory) ListRecords(ctx context.Context, query model.ListRecordsQuery)
 	// Filter by collection if specified
 	filtered := make([]*model.Record, 0)
 	for _, record := range records {
-		if query.Collection == "" || record.Collection == query.Collection {
-			filtered = append(filtered, record)
+		if query.Collection != "" && record.Collection != query.Collection {
+			continue
+		}
+		if !query.IncludePrivate && record.Visibility == model.VisibilityPrivate {
+			continue
+		}
+		if !query.Since.IsZero() && record.IndexedAt.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && record.IndexedAt.After(query.Until) {
+			continue
 		}
+		if !matchesValueFilters(record, query.ValueFilters) {
+			continue
+		}
+		filtered = append(filtered, record)
 	}
 	// Sort by indexedAt descending, then by RKey ascending for stable ordering
 	sort.Slice(filtered, func(i, j int) bool {
@@ -177,18 +330,25 @@ func (m *memory) ListRecords(ctx context.Context, query model.ListRecordsQuery)
 		return filtered[i].IndexedAt.After(filtered[j].IndexedAt)
 	})
 	
-	// Apply cursor if provided
+	// Apply cursor if provided; the page starts at the first record after the cursor position
 	startIndex := 0
 	if query.Cursor != "" {
-		lastIndexedAt, lastRKey, err := decodeMemoryCursor(query.Cursor)
-		if err == nil {
-			// Find the starting position based on cursor
-			for i, record := range filtered {
-				if record.IndexedAt.Before(lastIndexedAt) || 
-				   (record.IndexedAt.Equal(lastIndexedAt) && record.RKey > lastRKey) {
-					startIndex = i + 1
-					break
-				}
+		cursor, err := decodeMemoryCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if cursor.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(cursor.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
+		}
+		startIndex = len(filtered)
+		for i, record := range filtered {
+			if record.IndexedAt.Before(cursor.LastIndexedAt) || 
+			   (record.IndexedAt.Equal(cursor.LastIndexedAt) && record.RKey > cursor.LastRKey) {
+				startIndex = i
+				break
 			}
 		}
 	}
@@ -202,9 +362,10 @@ func (m *memory) ListRecords(ctx context.Context, query model.ListRecordsQuery)
 	}
 	
 	// Calculate end index
+	total := len(filtered)
 	endIndex := startIndex + limit
-	if endIndex > len(filtered) {
-		endIndex = len(filtered)
+	if endIndex > total {
+		endIndex = total
 	}
 	
 	// Extract the page of records
@@ -221,14 +382,141 @@ func (m *memory) ListRecords(ctx context.Context, query model.ListRecordsQuery)
 	}
 	
 	// Add next cursor if there are more records
-	if endIndex < len(records) && len(resultRecords) > 0 {
+	if endIndex < total && len(resultRecords) > 0 {
 		lastRecord := resultRecords[len(resultRecords)-1]
-		result.NextCursor = encodeMemoryCursor(lastRecord.IndexedAt, lastRecord.RKey)
+		result.NextCursor = encodeMemoryCursor(lastRecord.IndexedAt, lastRecord.RKey, query.FilterHash())
 	}
 	
 	return result, nil
 }
 
+// ListBacklinks scans all records for public ones of the collection whose value.subject
+// matches, ordered newest first and then by URI since rkeys are only unique per DID
+func (m *memory) ListBacklinks(ctx context.Context, query model.BacklinksQuery) (*model.ListRecordsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	var cursor *memoryCursorData
+	if query.Cursor != "" {
+		c, err := decodeMemoryCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if c.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(c.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
+		}
+		cursor = c
+	}
+	
+	matched := make([]*model.Record, 0)
+	for _, record := range m.records {
+		if record.Collection != query.Collection || record.Visibility == model.VisibilityPrivate {
+			continue
+		}
+		if subject, _ := record.Value["subject"].(string); subject != query.Subject {
+			continue
+		}
+		// Backlink cursors carry the URI in LastRKey
+		if cursor != nil && !(record.IndexedAt.Before(cursor.LastIndexedAt) ||
+			(record.IndexedAt.Equal(cursor.LastIndexedAt) && record.URI > cursor.LastRKey)) {
+			continue
+		}
+		matched = append(matched, record)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].IndexedAt.Equal(matched[j].IndexedAt) {
+			return matched[i].URI < matched[j].URI
+		}
+		return matched[i].IndexedAt.After(matched[j].IndexedAt)
+	})
+	
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	
+	result := &model.ListRecordsResult{Records: make([]model.Record, 0, min(limit, len(matched)))}
+	for _, record := range matched[:min(limit, len(matched))] {
+		result.Records = append(result.Records, *record)
+	}
+	if len(matched) > limit {
+		last := result.Records[len(result.Records)-1]
+		result.NextCursor = encodeMemoryCursor(last.IndexedAt, last.URI, query.FilterHash())
+	}
+	return result, nil
+}
+
+// matchesValueFilters reports whether each filtered top-level value key holds the given string
+func matchesValueFilters(record *model.Record, filters map[string]string) bool {
+	for key, want := range filters {
+		if got, ok := record.Value[key].(string); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// CountRecords counts a DID's records matching the collection and time filters
+func (m *memory) CountRecords(ctx context.Context, query model.ListRecordsQuery) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	var count int64
+	for _, record := range m.recordsByDID[query.DID] {
+		if query.Collection != "" && record.Collection != query.Collection {
+			continue
+		}
+		if !query.IncludePrivate && record.Visibility == model.VisibilityPrivate {
+			continue
+		}
+		if !query.Since.IsZero() && record.IndexedAt.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && record.IndexedAt.After(query.Until) {
+			continue
+		}
+		if !matchesValueFilters(record, query.ValueFilters) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// CollectionStats groups a DID's records by collection, collecting schema versions
+func (m *memory) CollectionStats(ctx context.Context, did string, includePrivate bool) ([]model.CollectionStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	byCollection := make(map[string]*model.CollectionStats)
+	var stats []model.CollectionStats
+	for _, record := range m.recordsByDID[did] {
+		if !includePrivate && record.Visibility == model.VisibilityPrivate {
+			continue
+		}
+		s, exists := byCollection[record.Collection]
+		if !exists {
+			s = &model.CollectionStats{Collection: record.Collection, SchemaVersions: []string{}}
+			byCollection[record.Collection] = s
+		}
+		s.Count++
+		if !slices.Contains(s.SchemaVersions, record.SchemaVersion) {
+			s.SchemaVersions = append(s.SchemaVersions, record.SchemaVersion)
+		}
+	}
+	for _, s := range byCollection {
+		sort.Strings(s.SchemaVersions)
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Collection < stats[j].Collection })
+	return stats, nil
+}
+
 func (m *memory) GetRecordByURI(ctx context.Context, uri string) (*model.Record, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -240,6 +528,136 @@ func (m *memory) GetRecordByURI(ctx context.Context, uri string) (*model.Record,
 	return record, nil
 }
 
+// GetRecordsByURIs returns copies of the stored records among uris, keyed by URI
+func (m *memory) GetRecordsByURIs(ctx context.Context, uris []string) (map[string]*model.Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	found := make(map[string]*model.Record, len(uris))
+	for _, uri := range uris {
+		if record, exists := m.records[uri]; exists {
+			recordCopy := *record
+			found[uri] = &recordCopy
+		}
+	}
+	return found, nil
+}
+
+// UpdateRecord replaces the mutable fields of an existing record. The stored record is
+// swapped for an updated copy rather than modified, since callers may hold the old one.
+func (m *memory) UpdateRecord(ctx context.Context, record model.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	existing, exists := m.records[record.URI]
+	if !exists {
+		return ErrNotFound
+	}
+	updated := *existing
+	updated.CID = record.CID
+	updated.Value = record.Value
+	updated.IndexedAt = record.IndexedAt
+	updated.SchemaVersion = record.SchemaVersion
+	updated.Visibility = record.Visibility
+	
+	if err := m.appendOp(recordOperation("record.update", record.URI, existing.DID, existing.Collection, record.CID, record.SchemaVersion)); err != nil {
+		return err
+	}
+	m.records[record.URI] = &updated
+	if i := slices.Index(m.recordsByDID[existing.DID], existing); i >= 0 {
+		m.recordsByDID[existing.DID][i] = &updated
+	}
+	return nil
+}
+
+// DeleteRecord removes a record from both the URI and per-DID indexes
+func (m *memory) DeleteRecord(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	record, exists := m.records[uri]
+	if !exists {
+		return ErrNotFound
+	}
+	if err := m.appendOp(recordOperation("record.delete", uri, record.DID, record.Collection, record.CID, record.SchemaVersion)); err != nil {
+		return err
+	}
+	delete(m.records, uri)
+	delete(m.recordIDs, record.ID)
+	m.recordsByDID[record.DID] = slices.DeleteFunc(m.recordsByDID[record.DID], func(r *model.Record) bool {
+		return r.URI == uri
+	})
+	return nil
+}
+
+// AppendOperation appends an operation log entry
+func (m *memory) AppendOperation(ctx context.Context, entry model.OperationLogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	return m.appendOp(entry)
+}
+
+// appendOp assigns an entry the next sequence and appends it to the operation log;
+// the caller must hold the write lock. Mutations append before changing anything, so
+// under the lock the change and its entry are applied together or not at all.
+func (m *memory) appendOp(entry model.OperationLogEntry) error {
+	if m.opLogFault != nil {
+		if err := m.opLogFault(entry); err != nil {
+			return fmt.Errorf("failed to append op_log entry: %w", err)
+		}
+	}
+	entry.Sequence = int64(len(m.opLog) + 1)
+	entry.OccurredAt = time.Now().UTC()
+	m.opLog = append(m.opLog, entry)
+	return nil
+}
+
+// ListOperations lists a DID's operation log entries in ascending sequence order
+func (m *memory) ListOperations(ctx context.Context, query model.ListOperationsQuery) (*model.ListOperationsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	var after int64
+	if query.Cursor != "" {
+		c, err := decodeMemoryCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if c.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(c.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
+		}
+		// Op log cursors carry the last sequence in LastRKey
+		if after, err = strconv.ParseInt(c.LastRKey, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	
+	result := &model.ListOperationsResult{Operations: []model.OperationLogEntry{}}
+	for _, op := range m.opLog[min(after, int64(len(m.opLog))):] {
+		if op.DID != query.DID || (!query.Since.IsZero() && op.OccurredAt.Before(query.Since)) || (!query.Until.IsZero() && op.OccurredAt.After(query.Until)) {
+			continue
+		}
+		if len(result.Operations) == limit {
+			last := result.Operations[limit-1]
+			result.NextCursor = encodeMemoryCursor(time.Time{}, strconv.FormatInt(last.Sequence, 10), query.FilterHash())
+			break
+		}
+		result.Operations = append(result.Operations, op)
+	}
+	return result, nil
+}
+
 func (m *memory) CreateMediaAsset(ctx context.Context, asset model.MediaAsset) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -268,7 +686,23 @@ func (m *memory) GetMediaAsset(ctx context.Context, assetId string) (*model.Medi
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return asset, nil
+	// Callers update the returned asset before saving it, so hand out a copy
+	assetCopy := *asset
+	return &assetCopy, nil
+}
+
+// CountPendingMediaAssets counts a DID's media assets that have not been finalized
+func (m *memory) CountPendingMediaAssets(ctx context.Context, did string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	var count int64
+	for _, asset := range m.mediaAssets {
+		if asset.DID == did && asset.Checksum == "" {
+			count++
+		}
+	}
+	return count, nil
 }
 
 func (m *memory) UpdateMediaAsset(ctx context.Context, asset model.MediaAsset) error {
@@ -276,40 +710,151 @@ func (m *memory) UpdateMediaAsset(ctx context.Context, asset model.MediaAsset) e
 	defer m.mu.Unlock()
 	
 	// Check if asset exists
-	if _, exists := m.mediaAssets[asset.AssetID]; !exists {
+	existing, exists := m.mediaAssets[asset.AssetID]
+	if !exists {
 		return ErrNotFound
 	}
 	
-	// Update the asset
+	// Update the asset, logging the update that finalizes it
+	if existing.Checksum == "" && asset.Checksum != "" {
+		if err := m.appendOp(mediaFinalizeOperation(asset)); err != nil {
+			return err
+		}
+	}
 	assetCopy := asset
 	m.mediaAssets[asset.AssetID] = &assetCopy
 	return nil
 }
 
-// StoreIdempotentResponse stores an idempotent response in memory
-func (m *memory) StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
+// DeleteMediaAsset removes a media asset, returning ErrNotFound if it does not exist
+func (m *memory) DeleteMediaAsset(ctx context.Context, assetID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
-	// First, check if there are existing entries with the same key_hash but different request_hash
-	for compositeKey := range m.idempotency {
-		// Check if this entry has the same keyHash but different requestHash
-		if strings.HasPrefix(compositeKey, keyHash+":") {
-			parts := strings.SplitN(compositeKey, ":", 2)
-			if len(parts) == 2 && parts[1] != requestHash {
-				// Found an entry with same key_hash but different request_hash - this is a conflict
-				return ErrConflict
+	if _, exists := m.mediaAssets[assetID]; !exists {
+		return ErrNotFound
+	}
+	delete(m.mediaAssets, assetID)
+	return nil
+}
+
+// ListMediaAssets lists a DID's media assets ordered by creation time (newest first),
+// with asset ID as a tiebreaker, returning the cursor for the next page if any
+func (m *memory) ListMediaAssets(ctx context.Context, query model.ListMediaAssetsQuery) ([]model.MediaAsset, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	mimePrefix, mimeWildcard := strings.CutSuffix(query.MimeType, "*")
+	assets := make([]model.MediaAsset, 0)
+	for _, asset := range m.mediaAssets {
+		if asset.DID != query.DID {
+			continue
+		}
+		if mimeWildcard && !strings.HasPrefix(asset.MimeType, mimePrefix) {
+			continue
+		}
+		if !mimeWildcard && query.MimeType != "" && asset.MimeType != query.MimeType {
+			continue
+		}
+		if query.Finalized != nil && (asset.Checksum != "") != *query.Finalized {
+			continue
+		}
+		assets = append(assets, *asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].CreatedAt.Equal(assets[j].CreatedAt) {
+			return assets[i].AssetID < assets[j].AssetID
+		}
+		return assets[i].CreatedAt.After(assets[j].CreatedAt)
+	})
+	
+	// Skip everything up to and including the cursor position
+	if query.Cursor != "" {
+		cursor, err := decodeMemoryCursor(query.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor: %w", err)
+		}
+		if cursor.Filters != query.FilterHash() {
+			return nil, "", errCursorFilters
+		}
+		if cursorExpired(cursor.IssuedAt, query.CursorIssuedAfter) {
+			return nil, "", errCursorExpired
+		}
+		start := len(assets)
+		for i, asset := range assets {
+			if asset.CreatedAt.Before(cursor.LastIndexedAt) ||
+				(asset.CreatedAt.Equal(cursor.LastIndexedAt) && asset.AssetID > cursor.LastRKey) {
+				start = i
+				break
 			}
 		}
+		assets = assets[start:]
+	}
+	
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	
+	nextCursor := ""
+	if len(assets) > limit {
+		assets = assets[:limit]
+		last := assets[len(assets)-1]
+		nextCursor = encodeMemoryCursor(last.CreatedAt, last.AssetID, query.FilterHash())
+	}
+	return assets, nextCursor, nil
+}
+
+// ReserveIdempotencyKey claims keyHash for requestHash with a pending entry, unless an
+// unexpired entry already exists, in which case a copy of that entry is returned.
+func (m *memory) ReserveIdempotencyKey(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if existing, ok := m.idempotency[keyHash]; ok && time.Now().UTC().Before(existing.ExpiresAt) {
+		responseCopy := *existing
+		responseCopy.ResponseBody = slices.Clone(existing.ResponseBody)
+		return &responseCopy, nil
+	}
+	m.idempotency[keyHash] = &IdempotentResponse{RequestHash: requestHash, ExpiresAt: expiresAt}
+	return nil, nil
+}
+
+// ReleaseIdempotencyKey drops the pending entry for keyHash if requestHash claimed it
+func (m *memory) ReleaseIdempotencyKey(ctx context.Context, keyHash, requestHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if existing, ok := m.idempotency[keyHash]; ok && existing.RequestHash == requestHash && existing.Pending() {
+		delete(m.idempotency, keyHash)
+	}
+	return nil
+}
+
+// StoreIdempotentResponse stores an idempotent response in memory.
+// Like postgres, a key already stored for a different request hash is a conflict
+// until that entry expires, and a finished response for the same request is kept.
+func (m *memory) StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// An expired entry is treated as missing, as in GetIdempotentResponse
+	if existing, ok := m.idempotency[keyHash]; ok && time.Now().UTC().Before(existing.ExpiresAt) {
+		if existing.RequestHash != requestHash {
+			return ErrConflict
+		}
+		if !existing.Pending() {
+			return nil
+		}
 	}
 	
 	responseCopy := make([]byte, len(responseBody))
 	copy(responseCopy, responseBody)
 	
-	// Create a composite key using both keyHash and requestHash
-	compositeKey := keyHash + ":" + requestHash
-	
-	m.idempotency[compositeKey] = &IdempotentResponse{
+	m.idempotency[keyHash] = &IdempotentResponse{
+		RequestHash:  requestHash,
 		ResponseBody: responseCopy,
 		StatusCode:   statusCode,
 		ExpiresAt:    expiresAt,
@@ -318,28 +863,92 @@ func (m *memory) StoreIdempotentResponse(ctx context.Context, keyHash, requestHa
 }
 
 // GetIdempotentResponse retrieves a cached idempotent response from memory
-func (m *memory) GetIdempotentResponse(ctx context.Context, keyHash string) ([]byte, int, error) {
+func (m *memory) GetIdempotentResponse(ctx context.Context, keyHash string) (*IdempotentResponse, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
-	// Look for any entry with the given keyHash
-	// In a real implementation, we would check for conflicts with different requestHash values
-	// For now, we'll just return the first match
-	for compositeKey, response := range m.idempotency {
-		// Extract keyHash from composite key
-		if strings.HasPrefix(compositeKey, keyHash+":") {
-			// Check if the response has expired
-			if time.Now().UTC().After(response.ExpiresAt) {
-				// Skip expired entries
-				continue
-			}
-			
-			responseCopy := make([]byte, len(response.ResponseBody))
-			copy(responseCopy, response.ResponseBody)
-			
-			return responseCopy, response.StatusCode, nil
+	response, ok := m.idempotency[keyHash]
+	if !ok || time.Now().UTC().After(response.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	
+	responseCopy := *response
+	responseCopy.ResponseBody = make([]byte, len(response.ResponseBody))
+	copy(responseCopy.ResponseBody, response.ResponseBody)
+	return &responseCopy, nil
+}
+
+// DeleteExpiredIdempotentResponses deletes expired idempotency entries from memory
+func (m *memory) DeleteExpiredIdempotentResponses(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	var deleted int64
+	now := time.Now().UTC()
+	for keyHash, response := range m.idempotency {
+		if !now.Before(response.ExpiresAt) {
+			delete(m.idempotency, keyHash)
+			deleted++
 		}
 	}
+	return deleted, nil
+}
+
+// MarkTokenUsed records a token ID hash in memory, returning ErrConflict if it
+// was already used and has not yet expired. An expired entry is taken over.
+func (m *memory) MarkTokenUsed(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	
-	return nil, 0, ErrNotFound
+	if expiry, exists := m.usedTokens[tokenHash]; exists && time.Now().UTC().Before(expiry) {
+		return ErrConflict
+	}
+	m.usedTokens[tokenHash] = expiresAt
+	return nil
+}
+
+// DeleteExpiredTokens deletes expired used token IDs from memory
+func (m *memory) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	var deleted int64
+	now := time.Now().UTC()
+	for hash, expiry := range m.usedTokens {
+		if !now.Before(expiry) {
+			delete(m.usedTokens, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *memory) CheckConsistency(ctx context.Context, sampleLimit int) (*model.ConsistencyReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	report := &model.ConsistencyReport{OrphanedRecords: []string{}, OrphanedMedia: []string{}}
+	for uri, record := range m.records {
+		if _, exists := m.accounts[record.DID]; !exists {
+			report.OrphanedRecordCount++
+			report.OrphanedRecords = append(report.OrphanedRecords, uri)
+		}
+	}
+	for assetID, asset := range m.mediaAssets {
+		if _, exists := m.accounts[asset.DID]; !exists {
+			report.OrphanedMediaCount++
+			report.OrphanedMedia = append(report.OrphanedMedia, assetID)
+		}
+	}
+	
+	// Sort so samples are stable, matching the postgres ordering
+	sort.Strings(report.OrphanedRecords)
+	sort.Strings(report.OrphanedMedia)
+	if len(report.OrphanedRecords) > sampleLimit {
+		report.OrphanedRecords = report.OrphanedRecords[:sampleLimit]
+	}
+	if len(report.OrphanedMedia) > sampleLimit {
+		report.OrphanedMedia = report.OrphanedMedia[:sampleLimit]
+	}
+	return report, nil
 }
@@ -9,8 +9,12 @@
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
 	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -20,16 +24,57 @@
 // It provides persistent storage for accounts, records, and media assets.
 type postgres struct {
 	db *pgxpool.Pool // Connection pool to PostgreSQL database
+	metrics *metrics.Metrics // Metrics for data integrity problems
+	opLogFault func(model.OperationLogEntry) error // Fails op_log appends in tests; nil otherwise
+}
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and rolling it back
+// when fn or the commit fails. Mutations use it to write their op_log entry atomically.
+func (p *postgres) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// PostgresOption configures NewPostgres
+type PostgresOption func(*postgresOptions)
+
+// postgresOptions holds the settings of NewPostgres
+type postgresOptions struct {
+	slowQueryThreshold time.Duration // Minimum duration of a logged query (0 disables the log)
+}
+
+// WithSlowQueryThreshold logs queries that take at least d at warn level; 0 disables the log
+func WithSlowQueryThreshold(d time.Duration) PostgresOption {
+	return func(o *postgresOptions) {
+		o.slowQueryThreshold = d
+	}
 }
 
 // NewPostgres creates a new PostgreSQL storage implementation.
 // It establishes a connection pool to the database and initializes the schema.
 // Parameters:
 //   - dsn: Database connection string in PostgreSQL format
+//   - opts: Optional settings applied over the defaults
 // Returns:
 //   - Store: Implementation of the storage interface
 //   - error: Any error that occurred during initialization
-func NewPostgres(dsn string) (Store, error) {
+func NewPostgres(dsn string, opts ...PostgresOption) (Store, error) {
+	options := postgresOptions{slowQueryThreshold: DefaultSlowQueryThreshold}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	
 	// Parse the database connection string
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
@@ -47,6 +92,8 @@ func NewPostgres(dsn string) (Store, error) {
 	config.MaxConnIdleTime = time.Minute * 30
 	// How often to check connection health
 	config.HealthCheckPeriod = time.Minute
+	// Trace and time each query, and log slow ones with the correlation ID of the request
+	config.ConnConfig.Tracer = &queryTracer{threshold: options.slowQueryThreshold, metrics: metrics.NewMetrics()}
 
 	// Establish connection with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -68,7 +115,20 @@ func NewPostgres(dsn string) (Store, error) {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	return &postgres{db: pool}, nil
+	return &postgres{db: pool, metrics: metrics.NewMetrics()}, nil
+}
+
+// PingPostgres checks that the database at dsn is reachable, without initializing the schema
+func PingPostgres(ctx context.Context, dsn string) error {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("invalid database DSN: %w", err)
+	}
+	defer pool.Close()
+	if err := pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
 }
 
 // initSchema initializes the database schema.
@@ -82,6 +142,8 @@ func initSchema(ctx context.Context, db *pgxpool.Pool) error {
 		    did TEXT PRIMARY KEY,                    -- Decentralized Identifier
 		    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()  -- Account creation time
 		);
+		-- Per-account settings, added after the initial schema
+		ALTER TABLE accounts ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
 
 		-- Records table for storing user-generated content
 		CREATE TABLE IF NOT EXISTS records (
@@ -96,11 +158,15 @@ func initSchema(ctx context.Context, db *pgxpool.Pool) error {
 		    schema_version TEXT NOT NULL,            -- Schema version for validation
 		    UNIQUE(did, collection, rkey)            -- Prevent duplicate records
 		);
+		-- Record visibility, added after the initial schema
+		ALTER TABLE records ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
 
 		-- Indexes for records table to improve query performance
 		CREATE INDEX IF NOT EXISTS idx_records_did_collection_indexed_at ON records(did, collection, indexed_at DESC);
 		CREATE INDEX IF NOT EXISTS idx_records_cid ON records(cid);
 		CREATE INDEX IF NOT EXISTS idx_records_indexed_at ON records(indexed_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_records_collection_subject ON records(collection, (value->>'subject'), indexed_at DESC, uri);
+		CREATE INDEX IF NOT EXISTS idx_records_value ON records USING GIN (value jsonb_path_ops);
 
 		-- Media assets table for storing media metadata
 		CREATE TABLE IF NOT EXISTS media_assets (
@@ -113,6 +179,12 @@ func initSchema(ctx context.Context, db *pgxpool.Pool) error {
 		    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),  -- Creation time
 		    UNIQUE(did, asset_id)                    -- Prevent duplicate assets
 		);
+		-- Object key and multipart upload ID, added after the initial schema
+		ALTER TABLE media_assets ADD COLUMN IF NOT EXISTS object_key TEXT NOT NULL DEFAULT '';
+		ALTER TABLE media_assets ADD COLUMN IF NOT EXISTS upload_id TEXT NOT NULL DEFAULT '';
+
+		-- Index for listing a DID's media assets newest first
+		CREATE INDEX IF NOT EXISTS idx_media_assets_did_created_at ON media_assets(did, created_at DESC, asset_id);
 
 		-- Idempotency table for storing idempotency keys
 		CREATE TABLE IF NOT EXISTS idempotency (
@@ -128,6 +200,15 @@ func initSchema(ctx context.Context, db *pgxpool.Pool) error {
 
 		-- Index for idempotency table to improve query performance
 		CREATE INDEX IF NOT EXISTS idx_idempotency_expires_at ON idempotency(expires_at);
+		-- One entry per key, so a key can be claimed before its request runs
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_key_hash ON idempotency(key_hash);
+
+		-- Used JWT IDs for replay detection, kept until the token expires
+		CREATE TABLE IF NOT EXISTS used_tokens (
+		    token_hash TEXT PRIMARY KEY,             -- Hash of the token issuer and jti
+		    expires_at TIMESTAMP WITH TIME ZONE NOT NULL  -- When the token expires
+		);
+		CREATE INDEX IF NOT EXISTS idx_used_tokens_expires_at ON used_tokens(expires_at);
 
 		-- Operation log table (append-only) for audit trail
 		CREATE TABLE IF NOT EXISTS op_log (
@@ -143,6 +224,7 @@ func initSchema(ctx context.Context, db *pgxpool.Pool) error {
 		CREATE INDEX IF NOT EXISTS idx_op_log_did ON op_log(did);
 		CREATE INDEX IF NOT EXISTS idx_op_log_type ON op_log(type);
 		CREATE INDEX IF NOT EXISTS idx_op_log_occurred_at ON op_log(occurred_at);
+		CREATE INDEX IF NOT EXISTS idx_op_log_did_seq ON op_log(did, seq);
 	`
 
 	// Execute the schema creation SQL
@@ -169,6 +251,27 @@ func (p *postgres) CreateAccount(ctx context.Context, did string) error {
 	return nil
 }
 
+// CreateAccounts creates accounts in one transaction, skipping DIDs that already have one.
+// created[i] reports whether dids[i] was created.
+func (p *postgres) CreateAccounts(ctx context.Context, dids []string) ([]bool, error) {
+	created := make([]bool, len(dids))
+	now := time.Now().UTC()
+	err := p.WithTx(ctx, func(tx pgx.Tx) error {
+		for i, did := range dids {
+			tag, err := tx.Exec(ctx, `INSERT INTO accounts (did, created_at) VALUES ($1, $2) ON CONFLICT (did) DO NOTHING`, did, now)
+			if err != nil {
+				return fmt.Errorf("failed to create account %s: %w", did, err)
+			}
+			created[i] = tag.RowsAffected() == 1
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
 // GetAccount retrieves an account by DID
 func (p *postgres) GetAccount(ctx context.Context, did string) (*model.Account, error) {
 	query := `SELECT did, created_at FROM accounts WHERE did = $1`
@@ -185,6 +288,35 @@ func (p *postgres) GetAccount(ctx context.Context, did string) (*model.Account,
 	return &account, nil
 }
 
+// GetAccountSettings returns an account's settings
+func (p *postgres) GetAccountSettings(ctx context.Context, did string) (map[string]interface{}, error) {
+	var settings map[string]interface{}
+	err := p.db.QueryRow(ctx, `SELECT settings FROM accounts WHERE did = $1`, did).Scan(&settings)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get account settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateAccountSettings replaces an account's settings
+func (p *postgres) UpdateAccountSettings(ctx context.Context, did string, settings map[string]interface{}) error {
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account settings: %w", err)
+	}
+	tag, err := p.db.Exec(ctx, `UPDATE accounts SET settings = $2 WHERE did = $1`, did, value)
+	if err != nil {
+		return fmt.Errorf("failed to update account settings: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // CreateRecord creates a new record in the database
 func (p *postgres) CreateRecord(ctx context.Context, record model.Record) error {
 	// First check if account exists
@@ -201,42 +333,109 @@ func (p *postgres) CreateRecord(ctx context.Context, record model.Record) error
 		return fmt.Errorf("failed to marshal record value: %w", err)
 	}
 
-	query := `INSERT INTO records (id, did, collection, rkey, uri, cid, value, indexed_at, schema_version) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	
-	_, err = p.db.Exec(ctx, query, 
-		record.ID, 
-		record.DID, 
-		record.Collection, 
-		record.RKey, 
-		record.URI, 
-		record.CID, 
-		valueJSON, 
-		record.IndexedAt, 
-		record.SchemaVersion)
-	
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return ErrConflict
+	visibility := record.Visibility
+	if visibility == "" {
+		visibility = model.VisibilityPublic
+	}
+
+	// The record and its op_log entry are written in one transaction so the log cannot diverge
+	return p.WithTx(ctx, func(tx pgx.Tx) error {
+		query := `INSERT INTO records (id, did, collection, rkey, uri, cid, value, indexed_at, schema_version, visibility) 
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		
+		_, err := tx.Exec(ctx, query, 
+			record.ID, 
+			record.DID, 
+			record.Collection, 
+			record.RKey, 
+			record.URI, 
+			record.CID, 
+			valueJSON, 
+			record.IndexedAt, 
+			record.SchemaVersion,
+			visibility)
+		
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return recordConflict(pgErr.ConstraintName)
+			}
+			return fmt.Errorf("failed to create record: %w", err)
 		}
-		return fmt.Errorf("failed to create record: %w", err)
+		
+		return p.insertOperation(ctx, tx, recordOperation("record.create", record.URI, record.DID, record.Collection, record.CID, record.SchemaVersion))
+	})
+}
+
+// recordOperation builds the op_log entry for a record mutation
+func recordOperation(opType, uri, did, collection, cid, schemaVersion string) model.OperationLogEntry {
+	return model.OperationLogEntry{
+		Type:      opType,
+		Reference: uri,
+		DID:       did,
+		Payload:   map[string]interface{}{"collection": collection, "cid": cid, "schemaVersion": schemaVersion},
+	}
+}
+
+// execer is satisfied by both the connection pool and a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// insertOperation appends an entry to the op_log; seq and occurred_at are assigned by the database
+func (p *postgres) insertOperation(ctx context.Context, db execer, entry model.OperationLogEntry) error {
+	if p.opLogFault != nil {
+		if err := p.opLogFault(entry); err != nil {
+			return fmt.Errorf("failed to append op_log entry: %w", err)
+		}
+	}
+	payload, err := json.Marshal(entry.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal op_log payload: %w", err)
+	}
+	_, err = db.Exec(ctx, `INSERT INTO op_log (type, ref, did, payload) VALUES ($1, $2, $3, $4)`, entry.Type, entry.Reference, entry.DID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to append op_log entry: %w", err)
 	}
-	
 	return nil
 }
 
+// AppendOperation appends an entry to the op_log for an operation that has no record or
+// media mutation of its own; those log their entries in their own transactions
+func (p *postgres) AppendOperation(ctx context.Context, entry model.OperationLogEntry) error {
+	return p.insertOperation(ctx, p.db, entry)
+}
+
+// recordConflict maps a records unique constraint name to a ConflictError.
+// Constraint names are the postgres defaults generated by initSchema.
+func recordConflict(constraint string) error {
+	switch constraint {
+	case "records_uri_key":
+		return &ConflictError{Field: ConflictFieldURI}
+	case "records_did_collection_rkey_key":
+		return &ConflictError{Field: ConflictFieldRKey}
+	case "records_pkey":
+		return &ConflictError{Field: ConflictFieldID}
+	default:
+		return ErrConflict
+	}
+}
+
 // cursorData represents the data encoded in a pagination cursor
 type cursorData struct {
 	LastIndexedAt time.Time // Timestamp of the last record
 	LastRKey      string    // RKey of the last record
+	Filters       string    `json:",omitempty"` // Filter hash of the query the cursor was issued for
+	IssuedAt      time.Time `json:",omitempty"` // When the cursor was issued
 }
 
 // encodeCursor encodes cursor data into a base64 string
-func encodeCursor(lastIndexedAt time.Time, lastRKey string) string {
+func encodeCursor(lastIndexedAt time.Time, lastRKey, filters string) string {
 	data := cursorData{
 		LastIndexedAt: lastIndexedAt,
 		LastRKey:      lastRKey,
+		Filters:       filters,
+		IssuedAt:      time.Now().UTC(),
 	}
 	jsonBytes, _ := json.Marshal(data)
 	return base64.URLEncoding.EncodeToString(jsonBytes)
@@ -260,7 +459,7 @@ func decodeCursor(cursor string) (*cursorData, error) {
 // ListRecords lists records with optional filtering and cursor-based pagination
 func (p *postgres) ListRecords(ctx context.Context, query model.ListRecordsQuery) (*model.ListRecordsResult, error) {
 	// Build the query
-	baseQuery := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version 
+	baseQuery := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version, visibility 
 	              FROM records WHERE did = $1`
 	args := []interface{}{query.DID}
 	argIndex := 2
@@ -272,6 +471,10 @@ func (p *postgres) ListRecords(ctx context.Context, query model.ListRecordsQuery
 		argIndex++
 	}
 
+	if !query.IncludePrivate {
+		baseQuery += " AND visibility = 'public'"
+	}
+
 	// Add time range filters
 	if !query.Since.IsZero() {
 		baseQuery += fmt.Sprintf(" AND indexed_at >= $%d", argIndex)
@@ -285,12 +488,29 @@ func (p *postgres) ListRecords(ctx context.Context, query model.ListRecordsQuery
 		argIndex++
 	}
 
+	// Value filters use containment so the GIN index on value serves them
+	if len(query.ValueFilters) > 0 {
+		filterJSON, err := json.Marshal(query.ValueFilters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value filters: %w", err)
+		}
+		baseQuery += fmt.Sprintf(" AND value @> $%d::jsonb", argIndex)
+		args = append(args, string(filterJSON))
+		argIndex++
+	}
+
 	// Add cursor condition if provided
 	if query.Cursor != "" {
 		cursorData, err := decodeCursor(query.Cursor)
 		if err != nil {
 			return nil, fmt.Errorf("invalid cursor: %w", err)
 		}
+		if cursorData.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(cursorData.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
+		}
 		
 		// Add condition to fetch records before the cursor position
 		baseQuery += fmt.Sprintf(" AND (indexed_at < $%d OR (indexed_at = $%d AND rkey > $%d))", argIndex, argIndex, argIndex+1)
@@ -316,66 +536,217 @@ func (p *postgres) ListRecords(ctx context.Context, query model.ListRecordsQuery
 	}
 	defer rows.Close()
 
-	var records []model.Record
-	recordCount := 0
-	var lastRecord *model.Record
+	records, next, err := p.scanRecordPage(rows, limit, false)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating records: %w", err)
+	}
+
+	result := &model.ListRecordsResult{
+		Records: records,
+	}
 	
+	// If we fetched more records than requested, there are more results available
+	if next != nil {
+		// Generate cursor from the last row of this page, including skipped ones
+		result.NextCursor = encodeCursor(next.indexedAt, next.key, query.FilterHash())
+	}
+
+	return result, nil
+}
+
+// recordRows is the part of pgx.Rows that scanRecordPage reads
+type recordRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// pageCursor holds the keys of the last row of a page, which the next page starts after
+type pageCursor struct {
+	indexedAt time.Time // indexed_at of the row
+	key       string    // rkey, or uri where rkeys are not unique across the page
+}
+
+// scanRecordPage reads a page of records from rows selected as id, did, collection, rkey,
+// uri, cid, value, indexed_at, schema_version, visibility with one row beyond limit. Rows that
+// cannot be decoded are logged, counted and skipped. The cursor keys of every row are read
+// before the rest of it is decoded, so the cursor moves past corrupt rows even when a whole
+// page is corrupt. next is nil when there is no further page; its key is the row's rkey, or
+// its uri when byURI is set.
+func (p *postgres) scanRecordPage(rows recordRows, limit int, byURI bool) (records []model.Record, next *pageCursor, err error) {
+	records = []model.Record{}
+	rowCount := 0
+	var last pageCursor // Last row within the limit, even if skipped
 	for rows.Next() {
+		rowCount++
+		if rowCount > limit {
+			continue
+		}
+		
+		var rkey, uri string
+		var indexedAt time.Time
+		if err := rows.Scan(nil, nil, nil, &rkey, &uri, nil, nil, &indexedAt, nil, nil); err != nil {
+			return nil, nil, err
+		}
+		last = pageCursor{indexedAt: indexedAt, key: rkey}
+		if byURI {
+			last.key = uri
+		}
+		
 		var record model.Record
 		var valueJSON []byte
+		if err := rows.Scan(&record.ID, &record.DID, &record.Collection, &record.RKey, &record.URI, &record.CID,
+			&valueJSON, &record.IndexedAt, &record.SchemaVersion, &record.Visibility); err != nil {
+			// A single unreadable row must not hide the rest of the page
+			p.skipCorruptRecord("scan", uri, err)
+			continue
+		}
+		if err := json.Unmarshal(valueJSON, &record.Value); err != nil {
+			p.skipCorruptRecord("unmarshal", uri, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if rowCount > limit {
+		next = &last
+	}
+	return records, next, nil
+}
 
-		err := rows.Scan(
-			&record.ID,
-			&record.DID,
-			&record.Collection,
-			&record.RKey,
-			&record.URI,
-			&record.CID,
-			&valueJSON,
-			&record.IndexedAt,
-			&record.SchemaVersion,
-		)
+// skipCorruptRecord logs and counts a record row that cannot be decoded
+func (p *postgres) skipCorruptRecord(stage, uri string, err error) {
+	slog.Error("skipping corrupt record", "stage", stage, "uri", uri, "error", err)
+	p.metrics.CorruptRecordsTotal.WithLabelValues(stage).Inc()
+}
+
+// ListBacklinks lists the public records of a collection whose value.subject matches,
+// across all DIDs, using the collection/subject expression index. Records are ordered
+// newest first and then by URI since rkeys are only unique per DID.
+func (p *postgres) ListBacklinks(ctx context.Context, query model.BacklinksQuery) (*model.ListRecordsResult, error) {
+	sqlQuery := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version, visibility 
+	             FROM records WHERE collection = $1 AND value->>'subject' = $2 AND visibility = 'public'`
+	args := []interface{}{query.Collection, query.Subject}
+	
+	if query.Cursor != "" {
+		cursorData, err := decodeCursor(query.Cursor)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan record: %w", err)
+			return nil, fmt.Errorf("invalid cursor: %w", err)
 		}
-
-		// Unmarshal JSON value
-		if err := json.Unmarshal(valueJSON, &record.Value); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal record value: %w", err)
+		if cursorData.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(cursorData.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
 		}
+		// Backlink cursors carry the URI in LastRKey
+		sqlQuery += " AND (indexed_at < $3 OR (indexed_at = $3 AND uri > $4))"
+		args = append(args, cursorData.LastIndexedAt, cursorData.LastRKey)
+	}
+	
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	args = append(args, limit+1) // One extra row tells whether there are more results
+	sqlQuery += fmt.Sprintf(" ORDER BY indexed_at DESC, uri ASC LIMIT $%d", len(args))
+	
+	rows, err := p.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backlinks: %w", err)
+	}
+	defer rows.Close()
+	
+	records, next, err := p.scanRecordPage(rows, limit, true)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating backlinks: %w", err)
+	}
+	
+	result := &model.ListRecordsResult{Records: records}
+	if next != nil {
+		result.NextCursor = encodeCursor(next.indexedAt, next.key, query.FilterHash())
+	}
+	return result, nil
+}
 
-		lastRecord = &record
-		recordCount++
-		
-		// Only add records up to the requested limit
-		if recordCount <= limit {
-			records = append(records, record)
+// CountRecords counts a DID's records matching the collection and time filters
+func (p *postgres) CountRecords(ctx context.Context, query model.ListRecordsQuery) (int64, error) {
+	countQuery := `SELECT COUNT(*) FROM records WHERE did = $1`
+	args := []interface{}{query.DID}
+	if query.Collection != "" {
+		args = append(args, query.Collection)
+		countQuery += fmt.Sprintf(" AND collection = $%d", len(args))
+	}
+	if !query.IncludePrivate {
+		countQuery += " AND visibility = 'public'"
+	}
+	if !query.Since.IsZero() {
+		args = append(args, query.Since)
+		countQuery += fmt.Sprintf(" AND indexed_at >= $%d", len(args))
+	}
+	if !query.Until.IsZero() {
+		args = append(args, query.Until)
+		countQuery += fmt.Sprintf(" AND indexed_at <= $%d", len(args))
+	}
+	if len(query.ValueFilters) > 0 {
+		filterJSON, err := json.Marshal(query.ValueFilters)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode value filters: %w", err)
 		}
+		args = append(args, string(filterJSON))
+		countQuery += fmt.Sprintf(" AND value @> $%d::jsonb", len(args))
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating records: %w", err)
+	var count int64
+	if err := p.db.QueryRow(ctx, countQuery, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
 	}
+	return count, nil
+}
 
-	result := &model.ListRecordsResult{
-		Records: records,
+// CollectionStats counts a DID's records per collection and schema version in one
+// grouped query, served by the (did, collection, indexed_at) index
+func (p *postgres) CollectionStats(ctx context.Context, did string, includePrivate bool) ([]model.CollectionStats, error) {
+	query := `SELECT collection, schema_version, COUNT(*) FROM records WHERE did = $1`
+	if !includePrivate {
+		query += " AND visibility = 'public'"
 	}
+	query += " GROUP BY collection, schema_version ORDER BY collection, schema_version"
 	
-	// If we fetched more records than requested, there are more results available
-	if recordCount > limit && lastRecord != nil {
-		// Generate cursor from the last record we actually returned
-		if len(records) > 0 {
-			lastReturnedRecord := records[len(records)-1]
-			result.NextCursor = encodeCursor(lastReturnedRecord.IndexedAt, lastReturnedRecord.RKey)
+	rows, err := p.db.Query(ctx, query, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count collections: %w", err)
+	}
+	defer rows.Close()
+	
+	var stats []model.CollectionStats
+	for rows.Next() {
+		var collection, schemaVersion string
+		var count int64
+		if err := rows.Scan(&collection, &schemaVersion, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan collection count: %w", err)
+		}
+		// Rows are ordered by collection, so each collection's versions are adjacent
+		if len(stats) == 0 || stats[len(stats)-1].Collection != collection {
+			stats = append(stats, model.CollectionStats{Collection: collection, SchemaVersions: []string{}})
 		}
+		s := &stats[len(stats)-1]
+		s.Count += count
+		s.SchemaVersions = append(s.SchemaVersions, schemaVersion)
 	}
-
-	return result, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating collection counts: %w", err)
+	}
+	return stats, nil
 }
 
 // GetRecordByURI retrieves a record by its URI
 func (p *postgres) GetRecordByURI(ctx context.Context, uri string) (*model.Record, error) {
-	query := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version 
+	query := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version, visibility 
 	          FROM records WHERE uri = $1`
 	
 	var record model.Record
@@ -391,6 +762,7 @@ func (p *postgres) GetRecordByURI(ctx context.Context, uri string) (*model.Recor
 		&valueJSON,
 		&record.IndexedAt,
 		&record.SchemaVersion,
+		&record.Visibility,
 	)
 	
 	if err != nil {
@@ -408,6 +780,175 @@ func (p *postgres) GetRecordByURI(ctx context.Context, uri string) (*model.Recor
 	return &record, nil
 }
 
+// GetRecordsByURIs retrieves the records among uris in a single query, keyed by URI.
+// Rows that cannot be decoded are skipped like in ListRecords.
+func (p *postgres) GetRecordsByURIs(ctx context.Context, uris []string) (map[string]*model.Record, error) {
+	query := `SELECT id, did, collection, rkey, uri, cid, value, indexed_at, schema_version, visibility 
+	          FROM records WHERE uri = ANY($1)`
+	
+	rows, err := p.db.Query(ctx, query, uris)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	defer rows.Close()
+	
+	found := make(map[string]*model.Record, len(uris))
+	for rows.Next() {
+		var record model.Record
+		var valueJSON []byte
+		err := rows.Scan(
+			&record.ID,
+			&record.DID,
+			&record.Collection,
+			&record.RKey,
+			&record.URI,
+			&record.CID,
+			&valueJSON,
+			&record.IndexedAt,
+			&record.SchemaVersion,
+			&record.Visibility,
+		)
+		if err != nil {
+			p.skipCorruptRecord("scan", record.URI, err)
+			continue
+		}
+		if err := json.Unmarshal(valueJSON, &record.Value); err != nil {
+			p.skipCorruptRecord("unmarshal", record.URI, err)
+			continue
+		}
+		found[record.URI] = &record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating records: %w", err)
+	}
+	return found, nil
+}
+
+// UpdateRecord replaces the value, CID, indexed time, schema version and visibility of
+// the record at record.URI; its ID, owner, collection and rkey never change
+func (p *postgres) UpdateRecord(ctx context.Context, record model.Record) error {
+	valueJSON, err := json.Marshal(record.Value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record value: %w", err)
+	}
+	
+	visibility := record.Visibility
+	if visibility == "" {
+		visibility = model.VisibilityPublic
+	}
+	
+	return p.WithTx(ctx, func(tx pgx.Tx) error {
+		query := `UPDATE records SET cid = $1, value = $2, indexed_at = $3, schema_version = $4, visibility = $5 
+		          WHERE uri = $6 RETURNING did, collection`
+		
+		var did, collection string
+		err := tx.QueryRow(ctx, query,
+			record.CID,
+			valueJSON,
+			record.IndexedAt,
+			record.SchemaVersion,
+			visibility,
+			record.URI).Scan(&did, &collection)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to update record: %w", err)
+		}
+		
+		return p.insertOperation(ctx, tx, recordOperation("record.update", record.URI, did, collection, record.CID, record.SchemaVersion))
+	})
+}
+
+// DeleteRecord deletes a record and appends a record.delete entry to the op_log in the
+// same transaction, so the audit trail cannot miss a deletion
+func (p *postgres) DeleteRecord(ctx context.Context, uri string) error {
+	return p.WithTx(ctx, func(tx pgx.Tx) error {
+		var did, collection, cid, schemaVersion string
+		err := tx.QueryRow(ctx, `DELETE FROM records WHERE uri = $1 RETURNING did, collection, cid, schema_version`, uri).Scan(&did, &collection, &cid, &schemaVersion)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to delete record: %w", err)
+		}
+		
+		return p.insertOperation(ctx, tx, recordOperation("record.delete", uri, did, collection, cid, schemaVersion))
+	})
+}
+
+// ListOperations lists a DID's op_log entries in ascending sequence order
+func (p *postgres) ListOperations(ctx context.Context, query model.ListOperationsQuery) (*model.ListOperationsResult, error) {
+	sqlQuery := `SELECT seq, type, ref, did, payload, occurred_at FROM op_log WHERE did = $1`
+	args := []interface{}{query.DID}
+	
+	if !query.Since.IsZero() {
+		args = append(args, query.Since)
+		sqlQuery += fmt.Sprintf(" AND occurred_at >= $%d", len(args))
+	}
+	if !query.Until.IsZero() {
+		args = append(args, query.Until)
+		sqlQuery += fmt.Sprintf(" AND occurred_at <= $%d", len(args))
+	}
+	if query.Cursor != "" {
+		cursorData, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if cursorData.Filters != query.FilterHash() {
+			return nil, errCursorFilters
+		}
+		if cursorExpired(cursorData.IssuedAt, query.CursorIssuedAfter) {
+			return nil, errCursorExpired
+		}
+		// Op log cursors carry the last sequence in LastRKey
+		after, err := strconv.ParseInt(cursorData.LastRKey, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		args = append(args, after)
+		sqlQuery += fmt.Sprintf(" AND seq > $%d", len(args))
+	}
+	
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	args = append(args, limit+1) // One extra row tells whether there are more results
+	sqlQuery += fmt.Sprintf(" ORDER BY seq ASC LIMIT $%d", len(args))
+	
+	rows, err := p.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list operations: %w", err)
+	}
+	defer rows.Close()
+	
+	result := &model.ListOperationsResult{Operations: []model.OperationLogEntry{}}
+	for rows.Next() {
+		var op model.OperationLogEntry
+		var payloadJSON []byte
+		if err := rows.Scan(&op.Sequence, &op.Type, &op.Reference, &op.DID, &payloadJSON, &op.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan operation: %w", err)
+		}
+		if err := json.Unmarshal(payloadJSON, &op.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode operation payload: %w", err)
+		}
+		result.Operations = append(result.Operations, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list operations: %w", err)
+	}
+	
+	if len(result.Operations) > limit {
+		result.Operations = result.Operations[:limit]
+		last := result.Operations[limit-1]
+		result.NextCursor = encodeCursor(time.Time{}, strconv.FormatInt(last.Sequence, 10), query.FilterHash())
+	}
+	return result, nil
+}
+
 // CreateMediaAsset creates a new media asset in the database
 func (p *postgres) CreateMediaAsset(ctx context.Context, asset model.MediaAsset) error {
 	// First check if account exists
@@ -418,8 +959,8 @@ func (p *postgres) CreateMediaAsset(ctx context.Context, asset model.MediaAsset)
 		return fmt.Errorf("failed to check account: %w", err)
 	}
 
-	query := `INSERT INTO media_assets (asset_id, did, uri, mime_type, size, checksum, created_at) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	query := `INSERT INTO media_assets (asset_id, did, uri, mime_type, size, checksum, created_at, object_key, upload_id) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 	
 	_, err := p.db.Exec(ctx, query, 
 		asset.AssetID, 
@@ -428,7 +969,9 @@ func (p *postgres) CreateMediaAsset(ctx context.Context, asset model.MediaAsset)
 		asset.MimeType, 
 		asset.Size, 
 		asset.Checksum, 
-		asset.CreatedAt)
+		asset.CreatedAt,
+		asset.ObjectKey,
+		asset.UploadID)
 	
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -443,7 +986,7 @@ func (p *postgres) CreateMediaAsset(ctx context.Context, asset model.MediaAsset)
 
 // GetMediaAsset retrieves a media asset by its ID
 func (p *postgres) GetMediaAsset(ctx context.Context, assetId string) (*model.MediaAsset, error) {
-	query := `SELECT asset_id, did, uri, mime_type, size, checksum, created_at 
+	query := `SELECT asset_id, did, uri, mime_type, size, checksum, created_at, object_key, upload_id 
 	          FROM media_assets WHERE asset_id = $1`
 	
 	var asset model.MediaAsset
@@ -456,6 +999,8 @@ func (p *postgres) GetMediaAsset(ctx context.Context, assetId string) (*model.Me
 		&asset.Size,
 		&asset.Checksum,
 		&asset.CreatedAt,
+		&asset.ObjectKey,
+		&asset.UploadID,
 	)
 	
 	if err != nil {
@@ -468,78 +1013,328 @@ func (p *postgres) GetMediaAsset(ctx context.Context, assetId string) (*model.Me
 	return &asset, nil
 }
 
-// UpdateMediaAsset updates an existing media asset
-func (p *postgres) UpdateMediaAsset(ctx context.Context, asset model.MediaAsset) error {
-	query := `UPDATE media_assets SET did = $1, uri = $2, mime_type = $3, size = $4, checksum = $5, created_at = $6 
-	          WHERE asset_id = $7`
+// likeEscaper escapes LIKE pattern metacharacters (with the default backslash escape)
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListMediaAssets lists a DID's media assets ordered by creation time (newest first),
+// with asset ID as a tiebreaker, returning the cursor for the next page if any
+func (p *postgres) ListMediaAssets(ctx context.Context, q model.ListMediaAssetsQuery) ([]model.MediaAsset, string, error) {
+	query := `SELECT asset_id, did, uri, mime_type, size, checksum, created_at, object_key, upload_id 
+	          FROM media_assets WHERE did = $1`
+	args := []interface{}{q.DID}
 	
-	result, err := p.db.Exec(ctx, query, 
-		asset.DID, 
-		asset.URI, 
-		asset.MimeType, 
-		asset.Size, 
-		asset.Checksum, 
-		asset.CreatedAt,
-		asset.AssetID)
+	// A trailing wildcard ("image/*") matches by prefix; LIKE metacharacters in the prefix are escaped
+	if prefix, wildcard := strings.CutSuffix(q.MimeType, "*"); wildcard {
+		args = append(args, likeEscaper.Replace(prefix)+"%")
+		query += fmt.Sprintf(` AND mime_type LIKE $%d`, len(args))
+	} else if q.MimeType != "" {
+		args = append(args, q.MimeType)
+		query += fmt.Sprintf(` AND mime_type = $%d`, len(args))
+	}
 	
+	// Finalized assets carry the checksum verified at finalize
+	if q.Finalized != nil {
+		if *q.Finalized {
+			query += ` AND checksum <> ''`
+		} else {
+			query += ` AND checksum = ''`
+		}
+	}
+	
+	if q.Cursor != "" {
+		data, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor: %w", err)
+		}
+		if data.Filters != q.FilterHash() {
+			return nil, "", errCursorFilters
+		}
+		if cursorExpired(data.IssuedAt, q.CursorIssuedAfter) {
+			return nil, "", errCursorExpired
+		}
+		args = append(args, data.LastIndexedAt, data.LastRKey)
+		query += fmt.Sprintf(` AND (created_at < $%d OR (created_at = $%d AND asset_id > $%d))`, len(args)-1, len(args)-1, len(args))
+	}
+	
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 25
+	} else if limit > 100 {
+		limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, asset_id ASC LIMIT $%d", len(args)+1)
+	args = append(args, limit+1) // Fetch one extra asset to determine if there are more results
+	
+	rows, err := p.db.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to update media asset: %w", err)
+		return nil, "", fmt.Errorf("failed to list media assets: %w", err)
 	}
+	defer rows.Close()
 	
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrNotFound
+	assets := make([]model.MediaAsset, 0, limit)
+	for rows.Next() {
+		var asset model.MediaAsset
+		if err := rows.Scan(&asset.AssetID, &asset.DID, &asset.URI, &asset.MimeType, &asset.Size, &asset.Checksum, &asset.CreatedAt, &asset.ObjectKey, &asset.UploadID); err != nil {
+			return nil, "", fmt.Errorf("failed to scan media asset: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list media assets: %w", err)
 	}
 	
-	return nil
+	nextCursor := ""
+	if len(assets) > limit {
+		assets = assets[:limit]
+		last := assets[len(assets)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.AssetID, q.FilterHash())
+	}
+	return assets, nextCursor, nil
 }
 
-// StoreIdempotentResponse stores an idempotent response in the database
-func (p *postgres) StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
-	// First, check if there are existing entries with the same key_hash but different request_hash
-	var existingRequestHash string
-	query := `SELECT request_hash FROM idempotency WHERE key_hash = $1 AND request_hash != $2 LIMIT 1`
-	
-	err := p.db.QueryRow(ctx, query, keyHash, requestHash).Scan(&existingRequestHash)
+// CountPendingMediaAssets counts a DID's media assets that have not been finalized
+func (p *postgres) CountPendingMediaAssets(ctx context.Context, did string) (int64, error) {
+	var count int64
+	err := p.db.QueryRow(ctx, `SELECT COUNT(*) FROM media_assets WHERE did = $1 AND checksum = ''`, did).Scan(&count)
 	if err != nil {
-		// If no rows found, that's fine - no conflict
-		if !errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("failed to check for idempotency conflicts: %w", err)
+		return 0, fmt.Errorf("failed to count pending media assets: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateMediaAsset updates an existing media asset. The update that first sets the checksum
+// finalizes the asset and appends a media.finalize entry to the op_log in the same transaction.
+func (p *postgres) UpdateMediaAsset(ctx context.Context, asset model.MediaAsset) error {
+	return p.WithTx(ctx, func(tx pgx.Tx) error {
+		var oldChecksum string
+		err := tx.QueryRow(ctx, `SELECT checksum FROM media_assets WHERE asset_id = $1 FOR UPDATE`, asset.AssetID).Scan(&oldChecksum)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to update media asset: %w", err)
 		}
-	} else {
-		// Found an entry with same key_hash but different request_hash - this is a conflict
-		return ErrConflict
+		
+		query := `UPDATE media_assets SET did = $1, uri = $2, mime_type = $3, size = $4, checksum = $5, created_at = $6, object_key = $7, upload_id = $8 
+		          WHERE asset_id = $9`
+		
+		_, err = tx.Exec(ctx, query, 
+			asset.DID, 
+			asset.URI, 
+			asset.MimeType, 
+			asset.Size, 
+			asset.Checksum, 
+			asset.CreatedAt,
+			asset.ObjectKey,
+			asset.UploadID,
+			asset.AssetID)
+		
+		if err != nil {
+			return fmt.Errorf("failed to update media asset: %w", err)
+		}
+		
+		if oldChecksum == "" && asset.Checksum != "" {
+			return p.insertOperation(ctx, tx, mediaFinalizeOperation(asset))
+		}
+		return nil
+	})
+}
+
+// mediaFinalizeOperation builds the op_log entry for finalizing a media asset
+func mediaFinalizeOperation(asset model.MediaAsset) model.OperationLogEntry {
+	return model.OperationLogEntry{
+		Type:      "media.finalize",
+		Reference: asset.AssetID,
+		DID:       asset.DID,
+		Payload:   map[string]interface{}{"mimeType": asset.MimeType, "size": asset.Size, "checksum": asset.Checksum},
+	}
+}
+
+// DeleteMediaAsset removes a media asset, returning ErrNotFound if it does not exist
+func (p *postgres) DeleteMediaAsset(ctx context.Context, assetID string) error {
+	result, err := p.db.Exec(ctx, `DELETE FROM media_assets WHERE asset_id = $1`, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to delete media asset: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
+
+// ReserveIdempotencyKey claims keyHash for requestHash with a pending row (status 0),
+// taking over an expired row. The claim is a single statement, so of concurrent replicas
+// only one gets nil back; the others get the existing row.
+func (p *postgres) ReserveIdempotencyKey(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error) {
+	query := `INSERT INTO idempotency (key_hash, request_hash, response_body, response_status, created_at, expires_at)
+	          VALUES ($1, $2, $3, 0, $4, $5)
+	          ON CONFLICT (key_hash) DO UPDATE
+	          SET request_hash = EXCLUDED.request_hash, response_body = EXCLUDED.response_body,
+	              response_status = 0, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+	          WHERE idempotency.expires_at <= NOW()`
 	
-	// Now try to insert or update
-	query = `INSERT INTO idempotency (key_hash, request_hash, response_body, response_status, created_at, expires_at)
+	// Retry once if the existing row expires or is released between INSERT and SELECT
+	for attempt := 0; attempt < 2; attempt++ {
+		tag, err := p.db.Exec(ctx, query, keyHash, requestHash, []byte{}, time.Now().UTC(), expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil, nil
+		}
+		existing, err := p.GetIdempotentResponse(ctx, keyHash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return existing, err
+	}
+	return nil, fmt.Errorf("failed to reserve idempotency key: key %s changed concurrently", keyHash)
+}
+
+// ReleaseIdempotencyKey deletes the pending row for keyHash if requestHash claimed it
+func (p *postgres) ReleaseIdempotencyKey(ctx context.Context, keyHash, requestHash string) error {
+	query := `DELETE FROM idempotency WHERE key_hash = $1 AND request_hash = $2 AND response_status = 0`
+	if _, err := p.db.Exec(ctx, query, keyHash, requestHash); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// StoreIdempotentResponse stores an idempotent response in the database.
+// The insert is a single statement on the unique key_hash, so concurrent replicas storing
+// different payloads for the same key cannot both succeed. It replaces a pending row for
+// the same request and any expired row, and keeps a finished response, so a replayed
+// request always gets the first one.
+func (p *postgres) StoreIdempotentResponse(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
+	query := `INSERT INTO idempotency (key_hash, request_hash, response_body, response_status, created_at, expires_at)
 	          VALUES ($1, $2, $3, $4, $5, $6)
-	          ON CONFLICT (key_hash, request_hash) DO UPDATE 
-	          SET response_body = $3, response_status = $4, created_at = $5, expires_at = $6`
+	          ON CONFLICT (key_hash) DO UPDATE
+	          SET request_hash = EXCLUDED.request_hash, response_body = EXCLUDED.response_body,
+	              response_status = EXCLUDED.response_status, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+	          WHERE idempotency.expires_at <= NOW()
+	             OR (idempotency.request_hash = EXCLUDED.request_hash AND idempotency.response_status = 0)`
 	
-	_, err = p.db.Exec(ctx, query, keyHash, requestHash, responseBody, statusCode, time.Now().UTC(), expiresAt)
+	// Retry once if the existing row expires between INSERT and SELECT
+	for attempt := 0; attempt < 2; attempt++ {
+		tag, err := p.db.Exec(ctx, query, keyHash, requestHash, responseBody, statusCode, time.Now().UTC(), expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to store idempotent response: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil
+		}
+		// No row was written: an unexpired entry is either finished or for another request
+		existing, err := p.GetIdempotentResponse(ctx, keyHash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if existing.RequestHash != requestHash {
+			return ErrConflict
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to store idempotent response: key %s changed concurrently", keyHash)
+}
+
+// DeleteExpiredIdempotentResponses deletes expired idempotency rows
+func (p *postgres) DeleteExpiredIdempotentResponses(ctx context.Context) (int64, error) {
+	tag, err := p.db.Exec(ctx, `DELETE FROM idempotency WHERE expires_at <= NOW()`)
 	if err != nil {
-		return fmt.Errorf("failed to store idempotent response: %w", err)
+		return 0, fmt.Errorf("failed to delete expired idempotent responses: %w", err)
 	}
-	
-	return nil
+	return tag.RowsAffected(), nil
 }
 
 // GetIdempotentResponse retrieves a cached idempotent response from the database
-func (p *postgres) GetIdempotentResponse(ctx context.Context, keyHash string) ([]byte, int, error) {
-	query := `SELECT response_body, response_status FROM idempotency 
+func (p *postgres) GetIdempotentResponse(ctx context.Context, keyHash string) (*IdempotentResponse, error) {
+	query := `SELECT request_hash, response_body, response_status, expires_at FROM idempotency 
 	          WHERE key_hash = $1 AND expires_at > $2`
 	
-	var responseBody []byte
-	var statusCode int
+	var response IdempotentResponse
 	
-	err := p.db.QueryRow(ctx, query, keyHash, time.Now().UTC()).Scan(&responseBody, &statusCode)
+	err := p.db.QueryRow(ctx, query, keyHash, time.Now().UTC()).Scan(&response.RequestHash, &response.ResponseBody, &response.StatusCode, &response.ExpiresAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, 0, ErrNotFound
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get idempotent response: %w", err)
+	}
+	
+	return &response, nil
+}
+
+// MarkTokenUsed records a token ID hash, returning ErrConflict if it was already used
+// and has not yet expired. The insert is a single statement, so concurrent replicas
+// racing on the same token cannot both succeed; an expired entry is taken over.
+func (p *postgres) MarkTokenUsed(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO used_tokens (token_hash, expires_at) VALUES ($1, $2)
+	          ON CONFLICT (token_hash) DO UPDATE SET expires_at = EXCLUDED.expires_at
+	          WHERE used_tokens.expires_at <= NOW()`
+	
+	tag, err := p.db.Exec(ctx, query, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record used token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// DeleteExpiredTokens deletes expired used token rows. It runs in the background rather
+// than on each MarkTokenUsed, so requests never wait on or fail because of the cleanup.
+func (p *postgres) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	tag, err := p.db.Exec(ctx, `DELETE FROM used_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired used tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CheckConsistency finds records and media assets whose DID has no account row.
+// The foreign keys normally prevent this, but they may have been dropped or bypassed.
+func (p *postgres) CheckConsistency(ctx context.Context, sampleLimit int) (*model.ConsistencyReport, error) {
+	report := &model.ConsistencyReport{OrphanedRecords: []string{}, OrphanedMedia: []string{}}
+	
+	checks := []struct {
+		table  string
+		column string
+		count  *int
+		sample *[]string
+	}{
+		{"records", "uri", &report.OrphanedRecordCount, &report.OrphanedRecords},
+		{"media_assets", "asset_id", &report.OrphanedMediaCount, &report.OrphanedMedia},
+	}
+	for _, check := range checks {
+		orphaned := fmt.Sprintf("FROM %s t LEFT JOIN accounts a ON a.did = t.did WHERE a.did IS NULL", check.table)
+		
+		if err := p.db.QueryRow(ctx, "SELECT COUNT(*) "+orphaned).Scan(check.count); err != nil {
+			return nil, fmt.Errorf("failed to count orphaned %s: %w", check.table, err)
+		}
+		if *check.count == 0 {
+			continue
+		}
+		
+		rows, err := p.db.Query(ctx, fmt.Sprintf("SELECT t.%s %s ORDER BY t.%s LIMIT $1", check.column, orphaned, check.column), sampleLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list orphaned %s: %w", check.table, err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan orphaned %s: %w", check.table, err)
+			}
+			*check.sample = append(*check.sample, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating orphaned %s: %w", check.table, err)
 		}
-		return nil, 0, fmt.Errorf("failed to get idempotent response: %w", err)
 	}
 	
-	return responseBody, statusCode, nil
+	return report, nil
 }
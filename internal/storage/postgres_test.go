@@ -0,0 +1,115 @@
+// internal/storage/postgres_test.go
+// Package storage provides tests for postgres row decoding that run without a database.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+)
+
+// fakeRow is one records row: id, did, collection, rkey, uri, cid, value, indexed_at,
+// schema_version, visibility. A non-nil scanErr fails scans of any column but the cursor keys.
+type fakeRow struct {
+	values  []any
+	scanErr error
+}
+
+// fakeRows serves fake rows to scanRecordPage
+type fakeRows struct {
+	rows []fakeRow
+	pos  int
+}
+
+func (f *fakeRows) Next() bool {
+	f.pos++
+	return f.pos <= len(f.rows)
+}
+
+func (f *fakeRows) Scan(dest ...any) error {
+	row := f.rows[f.pos-1]
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		if row.scanErr != nil && i != 3 && i != 4 && i != 7 {
+			return row.scanErr
+		}
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(row.values[i]))
+	}
+	return nil
+}
+
+func (f *fakeRows) Err() error { return nil }
+
+// TestScanRecordPageCorruptRows tests that corrupt rows are skipped without losing the
+// cursor, including when every row of the page is corrupt and the last one sits at the
+// page boundary.
+func TestScanRecordPageCorruptRows(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := func(i int, value string) fakeRow {
+		rkey := fmt.Sprintf("r%d", i)
+		return fakeRow{values: []any{"id" + rkey, "did:example:1", "post", rkey, "at://did:example:1/post/" + rkey,
+			"cid", []byte(value), base.Add(-time.Duration(i) * time.Second), "1.0.0", "public"}}
+	}
+	unreadable := func(i int) fakeRow {
+		r := row(i, "{}")
+		r.scanErr = errors.New("cannot scan")
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		rows        []fakeRow
+		limit       int
+		byURI       bool
+		wantRecords int
+		wantNext    *pageCursor
+	}{
+		{
+			name:        "corrupt row at the page boundary",
+			rows:        []fakeRow{row(1, "{}"), row(2, "[1]"), row(3, "{}")},
+			limit:       2,
+			wantRecords: 1,
+			wantNext:    &pageCursor{indexedAt: base.Add(-2 * time.Second), key: "r2"},
+		},
+		{
+			name:        "all rows corrupt",
+			rows:        []fakeRow{row(1, "not json"), unreadable(2), row(3, "{}")},
+			limit:       2,
+			wantRecords: 0,
+			wantNext:    &pageCursor{indexedAt: base.Add(-2 * time.Second), key: "r2"},
+		},
+		{
+			name:        "all rows corrupt, keyed by uri",
+			rows:        []fakeRow{unreadable(1), row(2, "\"text\""), row(3, "{}")},
+			limit:       2,
+			byURI:       true,
+			wantRecords: 0,
+			wantNext:    &pageCursor{indexedAt: base.Add(-2 * time.Second), key: "at://did:example:1/post/r2"},
+		},
+		{
+			name:        "last page",
+			rows:        []fakeRow{row(1, "{}"), row(2, "[]")},
+			limit:       2,
+			wantRecords: 1,
+		},
+	}
+	p := &postgres{metrics: metrics.NewMetrics()}
+	for _, tt := range tests {
+		records, next, err := p.scanRecordPage(&fakeRows{rows: tt.rows}, tt.limit, tt.byURI)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(records) != tt.wantRecords {
+			t.Errorf("%s: got %d records want %d", tt.name, len(records), tt.wantRecords)
+		}
+		if !reflect.DeepEqual(next, tt.wantNext) {
+			t.Errorf("%s: got next %+v want %+v", tt.name, next, tt.wantNext)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// internal/storage/redis.go
+// Package storage provides a Redis-backed IdempotencyStore, so idempotent responses are
+// shared between replicas without growing the primary database. Entries live under
+// idem:<keyHash> and expire through Redis TTLs instead of the expires_at column.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix prefixes idempotency keys in Redis
+const redisKeyPrefix = "idem:"
+
+// RedisIdempotency implements IdempotencyStore on Redis
+type RedisIdempotency struct {
+	client *redis.Client // Redis client
+}
+
+// redisValue is the JSON value stored for an idempotency key
+type redisValue struct {
+	RequestHash string    `json:"requestHash"` // Hash of the request the response belongs to
+	StatusCode  int       `json:"statusCode"`  // HTTP status code
+	Body        []byte    `json:"body"`        // Response body
+	ExpiresAt   time.Time `json:"expiresAt"`   // When the entry expires; Redis removes it then
+}
+
+// NewRedisIdempotency connects to the Redis server at rawURL
+// (redis://[user:password@]host[:port][/db], or rediss:// for TLS) and checks that it is
+// reachable.
+func NewRedisIdempotency(ctx context.Context, rawURL string) (*RedisIdempotency, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return &RedisIdempotency{client: client}, nil
+}
+
+// PingRedis checks that the Redis server at rawURL is reachable, for pre-flight checks
+func PingRedis(ctx context.Context, rawURL string) error {
+	r, err := NewRedisIdempotency(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// Client returns the underlying client, so other state shared between replicas can use
+// the same connection pool
+func (r *RedisIdempotency) Client() *redis.Client {
+	return r.client
+}
+
+// Close closes the connection pool
+func (r *RedisIdempotency) Close() error {
+	return r.client.Close()
+}
+
+// storeScript sets KEYS[1] to ARGV[1] for ARGV[3] milliseconds unless it holds an entry for
+// another request (returns 0) or a finished entry for the same one, which is kept (returns 1).
+// Running as a script makes the check and the SET atomic.
+var storeScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current then
+	local entry = cjson.decode(current)
+	if entry.requestHash ~= ARGV[2] then
+		return 0
+	end
+	if entry.statusCode ~= 0 then
+		return 1
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+return 1
+`)
+
+// releaseScript deletes KEYS[1] if it holds a pending entry for the request ARGV[1]
+var releaseScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current then
+	local entry = cjson.decode(current)
+	if entry.requestHash == ARGV[1] and entry.statusCode == 0 then
+		redis.call('DEL', KEYS[1])
+	end
+end
+return 0
+`)
+
+// Reserve implements IdempotencyStore. SET NX claims the key atomically, so of two replicas
+// reserving the same key only one gets nil back; the other gets the existing entry.
+func (r *RedisIdempotency) Reserve(ctx context.Context, keyHash, requestHash string, expiresAt time.Time) (*IdempotentResponse, error) {
+	value, err := json.Marshal(redisValue{RequestHash: requestHash, ExpiresAt: expiresAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency reservation: %w", err)
+	}
+
+	// Retry once if the existing entry expires or is released between SET and GET
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := r.client.SetNX(ctx, redisKeyPrefix+keyHash, value, time.Until(expiresAt)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if claimed {
+			return nil, nil
+		}
+		existing, err := r.Get(ctx, keyHash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return existing, err
+	}
+	return nil, fmt.Errorf("failed to reserve idempotency key: key %s changed concurrently", keyHash)
+}
+
+// Release implements IdempotencyStore
+func (r *RedisIdempotency) Release(ctx context.Context, keyHash, requestHash string) error {
+	if err := releaseScript.Run(ctx, r.client, []string{redisKeyPrefix + keyHash}, requestHash).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Store implements IdempotencyStore. It replaces a pending entry for the same request and
+// keeps a finished one, so a replayed request always gets the first response.
+func (r *RedisIdempotency) Store(ctx context.Context, keyHash, requestHash string, responseBody []byte, statusCode int, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(redisValue{RequestHash: requestHash, StatusCode: statusCode, Body: responseBody, ExpiresAt: expiresAt})
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+
+	stored, err := storeScript.Run(ctx, r.client, []string{redisKeyPrefix + keyHash}, value, requestHash, max(ttl.Milliseconds(), 1)).Int()
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	if stored == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Get implements IdempotencyStore
+func (r *RedisIdempotency) Get(ctx context.Context, keyHash string) (*IdempotentResponse, error) {
+	raw, err := r.client.Get(ctx, redisKeyPrefix+keyHash).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotent response: %w", err)
+	}
+	var value redisValue
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+	return &IdempotentResponse{
+		RequestHash:  value.RequestHash,
+		ResponseBody: value.Body,
+		StatusCode:   value.StatusCode,
+		ExpiresAt:    value.ExpiresAt,
+	}, nil
+}
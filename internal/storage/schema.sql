@@ -3,7 +3,8 @@
 -- Accounts table
 CREATE TABLE IF NOT EXISTS accounts (
     did TEXT PRIMARY KEY,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    settings JSONB NOT NULL DEFAULT '{}'
 );
 
 -- Records table
@@ -17,6 +18,7 @@ CREATE TABLE IF NOT EXISTS records (
     value JSONB NOT NULL,
     indexed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     schema_version TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'public',
     UNIQUE(did, collection, rkey)
 );
 
@@ -24,6 +26,8 @@ CREATE TABLE IF NOT EXISTS records (
 CREATE INDEX IF NOT EXISTS idx_records_did_collection_indexed_at ON records(did, collection, indexed_at DESC);
 CREATE INDEX IF NOT EXISTS idx_records_cid ON records(cid);
 CREATE INDEX IF NOT EXISTS idx_records_indexed_at ON records(indexed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_records_collection_subject ON records(collection, (value->>'subject'), indexed_at DESC, uri);
+CREATE INDEX IF NOT EXISTS idx_records_value ON records USING GIN (value jsonb_path_ops);
 
 -- Media assets table
 CREATE TABLE IF NOT EXISTS media_assets (
@@ -34,6 +38,8 @@ CREATE TABLE IF NOT EXISTS media_assets (
     size BIGINT NOT NULL,
     checksum TEXT NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    object_key TEXT NOT NULL DEFAULT '',
+    upload_id TEXT NOT NULL DEFAULT '',
     UNIQUE(did, asset_id)
 );
 
@@ -48,6 +54,8 @@ CREATE TABLE IF NOT EXISTS op_log (
 );
 
 -- Indexes for op_log table
+CREATE INDEX IF NOT EXISTS idx_media_assets_did_created_at ON media_assets(did, created_at DESC, asset_id);
 CREATE INDEX IF NOT EXISTS idx_op_log_did ON op_log(did);
 CREATE INDEX IF NOT EXISTS idx_op_log_type ON op_log(type);
 CREATE INDEX IF NOT EXISTS idx_op_log_occurred_at ON op_log(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_op_log_did_seq ON op_log(did, seq);
@@ -0,0 +1,392 @@
+// internal/storage/store_test.go
+// Package storage provides tests that every Store implementation must pass, so the
+// memory and postgres stores behave the same. Postgres runs when CDV_TEST_DB_DSN is set.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/model"
+)
+
+// testStores returns the stores to run the shared tests against
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{"memory": NewMemory()}
+	if dsn := os.Getenv("CDV_TEST_DB_DSN"); dsn != "" {
+		pg, err := NewPostgres(dsn)
+		if err != nil {
+			t.Fatalf("postgres: %v", err)
+		}
+		t.Cleanup(pg.(interface{ Close() }).Close)
+		stores["postgres"] = pg
+	}
+	return stores
+}
+
+// TestCreateRecordUniqueness tests that both stores reject the same duplicates and
+// report the same conflicting field for each.
+func TestCreateRecordUniqueness(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// A fresh DID per run keeps reruns against a shared database independent
+			did := fmt.Sprintf("did:example:unique%d", time.Now().UnixNano())
+			if err := store.CreateAccount(ctx, did); err != nil {
+				t.Fatal(err)
+			}
+			record := func(id, rkey, uri string) model.Record {
+				return model.Record{
+					ID: did + id, DID: did, Collection: "com.registryaccord.feed.post", RKey: rkey, URI: uri,
+					CID: "cid", Value: map[string]interface{}{"text": "hi"}, IndexedAt: time.Now(),
+					SchemaVersion: "1.0.0", Visibility: model.VisibilityPublic,
+				}
+			}
+			uri := "at://" + did + "/com.registryaccord.feed.post/"
+			if err := store.CreateRecord(ctx, record("1", "a", uri+"a")); err != nil {
+				t.Fatal(err)
+			}
+			
+			tests := []struct {
+				name   string
+				record model.Record
+				field  string
+			}{
+				{"same did, collection and rkey", record("2", "a", uri+"a-other"), ConflictFieldRKey},
+				{"same uri", record("3", "b", uri+"a"), ConflictFieldURI},
+				{"same id", record("1", "c", uri+"c"), ConflictFieldID},
+				{"duplicate of everything", record("1", "a", uri+"a"), ConflictFieldID},
+			}
+			for _, tt := range tests {
+				err := store.CreateRecord(ctx, tt.record)
+				var conflict *ConflictError
+				if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) || conflict.Field != tt.field {
+					t.Errorf("%s: got %v, want conflict on %s", tt.name, err, tt.field)
+				}
+			}
+			
+			// A deleted record's keys can be reused
+			if err := store.DeleteRecord(ctx, uri+"a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateRecord(ctx, record("1", "a", uri+"a")); err != nil {
+				t.Errorf("recreate after delete: %v", err)
+			}
+		})
+	}
+}
+
+// TestCreateAccounts tests that both stores create new accounts in bulk and report
+// existing ones, including DIDs repeated within the batch, as not created.
+func TestCreateAccounts(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// A fresh DID per run keeps reruns against a shared database independent
+			prefix := fmt.Sprintf("did:example:batch%d", time.Now().UnixNano())
+			if err := store.CreateAccount(ctx, prefix+"a"); err != nil {
+				t.Fatal(err)
+			}
+			created, err := store.CreateAccounts(ctx, []string{prefix + "a", prefix + "b", prefix + "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []bool{false, true, false}; fmt.Sprint(created) != fmt.Sprint(want) {
+				t.Errorf("got created %v want %v", created, want)
+			}
+			if _, err := store.GetAccount(ctx, prefix+"b"); err != nil {
+				t.Errorf("created account not found: %v", err)
+			}
+		})
+	}
+}
+
+// TestAccountSettings tests that both stores start accounts with empty settings, replace
+// them on update, and report a missing account as ErrNotFound.
+func TestAccountSettings(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			did := fmt.Sprintf("did:example:settings%d", time.Now().UnixNano())
+			if _, err := store.GetAccountSettings(ctx, did); !errors.Is(err, ErrNotFound) {
+				t.Errorf("missing account: expected ErrNotFound, got %v", err)
+			}
+			if err := store.UpdateAccountSettings(ctx, did, map[string]interface{}{"plan": "pro"}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("update missing account: expected ErrNotFound, got %v", err)
+			}
+			if err := store.CreateAccount(ctx, did); err != nil {
+				t.Fatal(err)
+			}
+			settings, err := store.GetAccountSettings(ctx, did)
+			if err != nil || len(settings) != 0 {
+				t.Fatalf("new account: got %v, %v want empty settings", settings, err)
+			}
+			if err := store.UpdateAccountSettings(ctx, did, map[string]interface{}{"plan": "pro", "beta": true}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpdateAccountSettings(ctx, did, map[string]interface{}{"plan": "free"}); err != nil {
+				t.Fatal(err)
+			}
+			settings, err = store.GetAccountSettings(ctx, did)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(settings) != "map[plan:free]" {
+				t.Errorf("got settings %v want map[plan:free]", settings)
+			}
+		})
+	}
+}
+
+// TestListMediaAssetsCursorFilters tests that both stores page media with a cursor only
+// under the filters it was issued for.
+func TestListMediaAssetsCursorFilters(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			did := fmt.Sprintf("did:example:media%d", time.Now().UnixNano())
+			if err := store.CreateAccount(ctx, did); err != nil {
+				t.Fatal(err)
+			}
+			base := time.Now().UTC().Truncate(time.Second)
+			for i, id := range []string{"a", "b", "c"} {
+				asset := model.MediaAsset{AssetID: did + id, DID: did, URI: "s3://bucket/" + id, MimeType: "image/png", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+				if err := store.CreateMediaAsset(ctx, asset); err != nil {
+					t.Fatal(err)
+				}
+			}
+			
+			query := model.ListMediaAssetsQuery{DID: did, MimeType: "image/*", Limit: 1}
+			_, cursor, err := store.ListMediaAssets(ctx, query)
+			if err != nil || cursor == "" {
+				t.Fatalf("first page: cursor %q, %v", cursor, err)
+			}
+			query.Cursor = cursor
+			if _, _, err := store.ListMediaAssets(ctx, query); err != nil {
+				t.Errorf("same filters: %v", err)
+			}
+			finalized := false
+			query.Finalized = &finalized
+			if _, _, err := store.ListMediaAssets(ctx, query); !errors.Is(err, errCursorFilters) {
+				t.Errorf("different filters: got %v want errCursorFilters", err)
+			}
+		})
+	}
+}
+
+// TestDeleteExpiredIdempotentResponses tests that both stores delete expired idempotency
+// entries and keep unexpired ones.
+func TestDeleteExpiredIdempotentResponses(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			prefix := fmt.Sprintf("prune%d", time.Now().UnixNano())
+			if err := store.StoreIdempotentResponse(ctx, prefix+"expired", "req1", []byte(`{}`), 200, time.Now().Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.StoreIdempotentResponse(ctx, prefix+"live", "req1", []byte(`{}`), 200, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			
+			deleted, err := store.DeleteExpiredIdempotentResponses(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A shared database may hold other expired entries too
+			if deleted < 1 {
+				t.Errorf("got %d deleted want at least 1", deleted)
+			}
+			if _, err := store.GetIdempotentResponse(ctx, prefix+"live"); err != nil {
+				t.Errorf("unexpired entry: %v", err)
+			}
+		})
+	}
+}
+
+// TestDeleteExpiredTokens tests that both stores delete expired used token IDs, keep
+// rejecting unexpired ones and let an expired ID be used again.
+func TestDeleteExpiredTokens(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			prefix := fmt.Sprintf("token%d", time.Now().UnixNano())
+			if err := store.MarkTokenUsed(ctx, prefix+"expired", time.Now().Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.MarkTokenUsed(ctx, prefix+"live", time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			
+			deleted, err := store.DeleteExpiredTokens(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A shared database may hold other expired tokens too
+			if deleted < 1 {
+				t.Errorf("got %d deleted want at least 1", deleted)
+			}
+			if err := store.MarkTokenUsed(ctx, prefix+"live", time.Now().Add(time.Hour)); !errors.Is(err, ErrConflict) {
+				t.Errorf("unexpired token: got %v want ErrConflict", err)
+			}
+			if err := store.MarkTokenUsed(ctx, prefix+"expired", time.Now().Add(time.Hour)); err != nil {
+				t.Errorf("expired token: got %v want nil", err)
+			}
+		})
+	}
+}
+
+// TestOperationLog tests that both stores log each record mutation and the finalizing
+// media update exactly once, with the URI or asset ID as the reference.
+func TestOperationLog(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			did := fmt.Sprintf("did:example:oplog%d", time.Now().UnixNano())
+			if err := store.CreateAccount(ctx, did); err != nil {
+				t.Fatal(err)
+			}
+			uri := "at://" + did + "/com.registryaccord.feed.post/a"
+			record := model.Record{
+				ID: did + "1", DID: did, Collection: "com.registryaccord.feed.post", RKey: "a", URI: uri,
+				CID: "cid1", Value: map[string]interface{}{"text": "hi"}, IndexedAt: time.Now(),
+				SchemaVersion: "1.0.0", Visibility: model.VisibilityPublic,
+			}
+			if err := store.CreateRecord(ctx, record); err != nil {
+				t.Fatal(err)
+			}
+			
+			ops := func() []model.OperationLogEntry {
+				result, err := store.ListOperations(ctx, model.ListOperationsQuery{DID: did, Limit: 100})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return result.Operations
+			}
+			got := ops()
+			if len(got) != 1 || got[0].Type != "record.create" || got[0].Reference != uri || got[0].Payload["schemaVersion"] != "1.0.0" {
+				t.Fatalf("after create: got %+v", got)
+			}
+			
+			record.CID = "cid2"
+			if err := store.UpdateRecord(ctx, record); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteRecord(ctx, uri); err != nil {
+				t.Fatal(err)
+			}
+			
+			asset := model.MediaAsset{AssetID: did + "-asset", DID: did, MimeType: "image/jpeg", Size: 10, CreatedAt: time.Now()}
+			if err := store.CreateMediaAsset(ctx, asset); err != nil {
+				t.Fatal(err)
+			}
+			asset.Checksum = "abc"
+			// Only the update that sets the checksum finalizes the asset
+			for i := 0; i < 2; i++ {
+				if err := store.UpdateMediaAsset(ctx, asset); err != nil {
+					t.Fatal(err)
+				}
+			}
+			
+			got = ops()
+			want := []struct{ typ, ref string }{
+				{"record.create", uri}, {"record.update", uri}, {"record.delete", uri}, {"media.finalize", asset.AssetID},
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d operations want %d: %+v", len(got), len(want), got)
+			}
+			for i, w := range want {
+				if got[i].Type != w.typ || got[i].Reference != w.ref || got[i].DID != did {
+					t.Errorf("operation %d: got %s %s want %s %s", i, got[i].Type, got[i].Reference, w.typ, w.ref)
+				}
+			}
+		})
+	}
+}
+
+// setOpLogFault makes a store's operation log appends fail with err, or succeed again when
+// err is nil
+func setOpLogFault(t *testing.T, store Store, err error) {
+	t.Helper()
+	var fault func(model.OperationLogEntry) error
+	if err != nil {
+		fault = func(model.OperationLogEntry) error { return err }
+	}
+	switch s := store.(type) {
+	case *memory:
+		s.mu.Lock()
+		s.opLogFault = fault
+		s.mu.Unlock()
+	case *postgres:
+		s.opLogFault = fault
+	default:
+		t.Fatalf("cannot inject op_log faults into %T", store)
+	}
+}
+
+// TestMutationRollsBackOnOpLogFailure tests that both stores leave a record untouched when
+// its op_log entry cannot be written, and still report conflicts unchanged.
+func TestMutationRollsBackOnOpLogFailure(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			did := fmt.Sprintf("did:example:rollback%d", time.Now().UnixNano())
+			if err := store.CreateAccount(ctx, did); err != nil {
+				t.Fatal(err)
+			}
+			uri := "at://" + did + "/com.registryaccord.feed.post/a"
+			record := model.Record{
+				ID: did + "1", DID: did, Collection: "com.registryaccord.feed.post", RKey: "a", URI: uri,
+				CID: "cid1", Value: map[string]interface{}{"text": "hi"}, IndexedAt: time.Now(),
+				SchemaVersion: "1.0.0", Visibility: model.VisibilityPublic,
+			}
+			opCount := func() int {
+				result, err := store.ListOperations(ctx, model.ListOperationsQuery{DID: did, Limit: 100})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(result.Operations)
+			}
+			
+			fault := errors.New("op_log unavailable")
+			setOpLogFault(t, store, fault)
+			if err := store.CreateRecord(ctx, record); !errors.Is(err, fault) {
+				t.Fatalf("create: got %v want the op_log failure", err)
+			}
+			if _, err := store.GetRecordByURI(ctx, uri); !errors.Is(err, ErrNotFound) {
+				t.Errorf("create: record stored despite the op_log failure: %v", err)
+			}
+			
+			setOpLogFault(t, store, nil)
+			if err := store.CreateRecord(ctx, record); err != nil {
+				t.Fatal(err)
+			}
+			
+			setOpLogFault(t, store, fault)
+			var conflict *ConflictError
+			if err := store.CreateRecord(ctx, record); !errors.As(err, &conflict) || conflict.Field != ConflictFieldID {
+				t.Errorf("duplicate: got %v want conflict on %s", err, ConflictFieldID)
+			}
+			updated := record
+			updated.CID = "cid2"
+			if err := store.UpdateRecord(ctx, updated); !errors.Is(err, fault) {
+				t.Errorf("update: got %v want the op_log failure", err)
+			}
+			if err := store.DeleteRecord(ctx, uri); !errors.Is(err, fault) {
+				t.Errorf("delete: got %v want the op_log failure", err)
+			}
+			if got, err := store.GetRecordByURI(ctx, uri); err != nil || got.CID != "cid1" {
+				t.Errorf("record changed despite op_log failures: %+v, %v", got, err)
+			}
+			
+			setOpLogFault(t, store, nil)
+			if n := opCount(); n != 1 {
+				t.Errorf("got %d operations want only the successful create", n)
+			}
+		})
+	}
+}
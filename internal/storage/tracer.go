@@ -0,0 +1,122 @@
+// internal/storage/tracer.go
+// Package storage provides a pgx query tracer that records a span and duration metric per
+// query, and ties slow queries back to the request that issued them through the
+// correlation ID in the query context.
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultSlowQueryThreshold is how long a query may take before it is logged as slow,
+// unless overridden with WithSlowQueryThreshold
+const DefaultSlowQueryThreshold = 250 * time.Millisecond
+
+// queryTracer implements pgx.QueryTracer. Each query gets a child span of the request span
+// and is counted and timed in the storage operation metrics; queries slower than threshold
+// are logged with the correlation ID of the request that issued them.
+type queryTracer struct {
+	threshold time.Duration    // Minimum duration of a logged query (0 disables the log)
+	metrics   *metrics.Metrics // Storage operation metrics
+}
+
+// queryTraceKey is the context key for the state of a query in progress
+type queryTraceKey struct{}
+
+// queryTrace is the state of a query in progress
+type queryTrace struct {
+	sql       string     // Query text, whitespace collapsed
+	operation string     // Metric and span label, see queryOperation
+	start     time.Time  // When the query was sent
+	span      trace.Span // Span covering the query
+}
+
+// TraceQueryStart implements pgx.QueryTracer by starting the query's span and timer
+func (t *queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	sql := strings.Join(strings.Fields(data.SQL), " ")
+	operation := queryOperation(sql)
+	ctx, span := otel.Tracer("cdv-service").Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient))
+	// Arguments are left out: they hold record values and tokens
+	span.SetAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", sql),
+	)
+	return context.WithValue(ctx, queryTraceKey{}, queryTrace{sql: sql, operation: operation, start: time.Now(), span: span})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer by ending the query's span, recording its
+// duration and logging it if it was slow
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	qt, ok := ctx.Value(queryTraceKey{}).(queryTrace)
+	if !ok {
+		return
+	}
+	duration := time.Since(qt.start)
+	
+	status := "success"
+	if data.Err != nil {
+		status = "error"
+		qt.span.RecordError(data.Err)
+		qt.span.SetStatus(codes.Error, data.Err.Error())
+	}
+	qt.span.End()
+	if t.metrics != nil {
+		t.metrics.StorageOperationTotal.WithLabelValues(qt.operation, status).Inc()
+		t.metrics.StorageOperationDuration.WithLabelValues(qt.operation, status).Observe(duration.Seconds())
+	}
+	
+	if t.threshold <= 0 || duration < t.threshold {
+		return
+	}
+	slog.Warn("slow database query",
+		"correlationId", telemetry.CorrelationID(ctx),
+		"operation", qt.operation,
+		"duration", duration,
+		"sql", qt.sql,
+		"error", data.Err)
+}
+
+// queryOperation labels a query by its statement and table, e.g. "select_records" or
+// "insert_op_log", keeping metric cardinality bounded by the schema rather than the queries.
+// Statements without a table, such as BEGIN and COMMIT, are labeled by the statement alone.
+func queryOperation(sql string) string {
+	fields := strings.Fields(strings.ToLower(sql))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := fields[0]
+	var table string
+	switch verb {
+	case "update":
+		if len(fields) > 1 {
+			table = fields[1]
+		}
+	case "insert", "delete", "select":
+		// The table follows the first INTO or FROM
+		for i, f := range fields[:len(fields)-1] {
+			if f == "into" || f == "from" {
+				table = fields[i+1]
+				break
+			}
+		}
+	}
+	table = strings.TrimFunc(table, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	if table == "" {
+		return verb
+	}
+	return verb + "_" + table
+}
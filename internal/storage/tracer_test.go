@@ -0,0 +1,111 @@
+// internal/storage/tracer_test.go
+// Package storage provides tests for the pgx query tracer.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/metrics"
+	"github.com/RegistryAccord/registryaccord-cdv-go/internal/telemetry"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestQueryOperation tests that queries are labeled by statement and table.
+func TestQueryOperation(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`SELECT did, created_at FROM accounts WHERE did = $1`, "select_accounts"},
+		{`INSERT INTO op_log (type, ref, did, payload) VALUES ($1, $2, $3, $4)`, "insert_op_log"},
+		{`UPDATE records SET cid = $1 WHERE uri = $2 RETURNING did`, "update_records"},
+		{`DELETE FROM media_assets WHERE asset_id = $1`, "delete_media_assets"},
+		{`select count(*) from "records"`, "select_records"},
+		{`begin`, "begin"},
+		{`SELECT 1`, "select"},
+		{``, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := queryOperation(tt.sql); got != tt.want {
+			t.Errorf("queryOperation(%q) = %q want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+// TestQueryTracerSpans tests that each query gets a span named after its operation, marked
+// as an error when the query fails.
+func TestQueryTracerSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+	
+	tracer := &queryTracer{threshold: DefaultSlowQueryThreshold, metrics: metrics.NewMetrics()}
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT *\n\tFROM records WHERE uri = $1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "INSERT INTO op_log (type) VALUES ($1)"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection reset")})
+	
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans want 2", len(spans))
+	}
+	if spans[0].Name() != "db.select_records" || spans[0].Status().Code == codes.Error {
+		t.Errorf("select: got span %q with status %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "db.insert_op_log" || spans[1].Status().Code != codes.Error {
+		t.Errorf("failed insert: got span %q with status %v", spans[1].Name(), spans[1].Status())
+	}
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "db.statement" && attr.Value.AsString() != "SELECT * FROM records WHERE uri = $1" {
+			t.Errorf("got statement %q", attr.Value.AsString())
+		}
+	}
+}
+
+// TestQueryTracerSlowQueryLog tests that queries reaching the threshold are logged with
+// their operation and correlation ID, and that a zero threshold disables the log.
+func TestQueryTracerSlowQueryLog(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+	
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		logged    bool
+	}{
+		{"over threshold", time.Nanosecond, true},
+		{"under threshold", time.Hour, false},
+		{"disabled", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			tracer := &queryTracer{threshold: tt.threshold}
+			ctx := context.WithValue(context.Background(), telemetry.CorrelationIDKey, "cid-123")
+			ctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM records WHERE did = $1"})
+			time.Sleep(time.Millisecond)
+			tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+			
+			out := logs.String()
+			if got := strings.Contains(out, "slow database query"); got != tt.logged {
+				t.Fatalf("got logged %v want %v: %s", got, tt.logged, out)
+			}
+			if tt.logged && (!strings.Contains(out, "operation=select_records") || !strings.Contains(out, "correlationId=cid-123")) {
+				t.Errorf("missing operation or correlation ID: %s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// internal/telemetry/correlation.go
+// Package telemetry carries the request correlation ID in context, so storage, media and
+// event calls made for a request can be tied back to it.
+package telemetry
+
+import "context"
+
+// contextKey is used for context values to avoid collisions with other packages
+type contextKey string
+
+// CorrelationIDKey is the context key for the request correlation ID. It is shared by every
+// package that reads the ID, since keys of different types never match.
+const CorrelationIDKey contextKey = "correlationId"
+
+// CorrelationID returns the correlation ID stored in ctx, or "" if there is none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(CorrelationIDKey).(string)
+	return id
+}
@@ -6,6 +6,7 @@
 	"log"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -16,8 +17,20 @@
 // TracerProvider is the global tracer provider
 var TracerProvider *sdktrace.TracerProvider
 
-// InitTracer initializes the OpenTelemetry tracer
-func InitTracer(serviceName string) (*sdktrace.TracerProvider, error) {
+// DeploymentEnvironment returns a resource attribute for the deployment environment (dev, staging, prod)
+func DeploymentEnvironment(env string) attribute.KeyValue {
+	return semconv.DeploymentEnvironment(env)
+}
+
+// InstanceID returns a resource attribute identifying this service instance
+func InstanceID(id string) attribute.KeyValue {
+	return semconv.ServiceInstanceID(id)
+}
+
+// InitTracer initializes the OpenTelemetry tracer.
+// The service version and any additional resource attributes (deployment environment,
+// instance ID) are attached to every span for filtering traces across environments.
+func InitTracer(serviceName, serviceVersion string, attrs ...attribute.KeyValue) (*sdktrace.TracerProvider, error) {
 	// Create a stdout exporter for development
 	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
 	if err != nil {
@@ -25,13 +38,13 @@ func InitTracer(serviceName string) (*sdktrace.TracerProvider, error) {
 	}
 
 	// Create a resource with service information
+	resourceAttrs := append([]attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(serviceVersion),
+	}, attrs...)
 	res, err := resource.Merge(
 		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
+		resource.NewWithAttributes(semconv.SchemaURL, resourceAttrs...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)